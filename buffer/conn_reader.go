@@ -0,0 +1,360 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultBlockSize = 8 * 1024
+
+var (
+	errNegativeCount = errors.New("buffer.ConnReader: negative count")
+	errReaderClosed  = errors.New("buffer.ConnReader: reader closed")
+)
+
+// NocopyReader 无拷贝读取器，打包器读取消息时优先使用
+type NocopyReader interface {
+	// Next returns a slice containing the next n bytes from the buffer,
+	// advancing the buffer as if the bytes had been returned by Read.
+	Next(n int) (p []byte, err error)
+
+	// Peek returns the next n bytes without advancing the reader.
+	Peek(n int) (buf []byte, err error)
+
+	// Release the memory space occupied by all read slices.
+	Release() (err error)
+
+	Slice(n int) (r NocopyReader, err error)
+}
+
+var (
+	blockPools  sync.Map // map[int]*sync.Pool
+	blocksInUse int64    // 已取出未归还的内存块数量
+)
+
+// BlocksInUse 获取从内存池取出且尚未归还的内存块数量，可用于观测切片读取器是否及时释放
+func BlocksInUse() int64 {
+	return atomic.LoadInt64(&blocksInUse)
+}
+
+// 内存块，被切片读取器引用时通过引用计数延迟归还
+type block struct {
+	buf  []byte
+	w    int
+	refs int32
+	pool *sync.Pool
+	next *block
+}
+
+func getBlock(size int) *block {
+	p, ok := blockPools.Load(size)
+	if !ok {
+		pool := &sync.Pool{}
+		pool.New = func() any { return &block{buf: make([]byte, size), pool: pool} }
+		p, _ = blockPools.LoadOrStore(size, pool)
+	}
+
+	b := p.(*sync.Pool).Get().(*block)
+	b.refs = 1
+	atomic.AddInt64(&blocksInUse, 1)
+
+	return b
+}
+
+func (b *block) retain() {
+	atomic.AddInt32(&b.refs, 1)
+}
+
+func (b *block) release() {
+	if atomic.AddInt32(&b.refs, -1) == 0 {
+		b.w = 0
+		b.next = nil
+		b.pool.Put(b)
+		atomic.AddInt64(&blocksInUse, -1)
+	}
+}
+
+var (
+	_ NocopyReader = &ConnReader{}
+	_ io.Reader    = &ConnReader{}
+)
+
+// ConnReader 基于池化内存块链表的连接读取器
+// Peek、Next返回的数据在Release前有效，Slice返回的读取器在其自身Release前有效
+// 非并发安全，应仅在连接的读协程中使用
+type ConnReader struct {
+	reader    io.Reader
+	blockSize int
+	head      *block
+	tail      *block
+	off       int
+	length    int
+	consumed  []*block
+	closed    bool
+}
+
+// NewConnReader 创建连接读取器，blockSize为内存块大小，默认8KB
+func NewConnReader(reader io.Reader, blockSize ...int) *ConnReader {
+	r := &ConnReader{reader: reader, blockSize: defaultBlockSize}
+
+	if len(blockSize) > 0 && blockSize[0] > 0 {
+		r.blockSize = blockSize[0]
+	}
+
+	return r
+}
+
+// Len 获取已缓冲未读取的字节数
+func (r *ConnReader) Len() int {
+	return r.length
+}
+
+// Peek 读取n个字节但不推进读取位置
+func (r *ConnReader) Peek(n int) ([]byte, error) {
+	if err := r.fill(n); err != nil {
+		return nil, err
+	}
+
+	if r.head == nil || r.head.w-r.off >= n {
+		return r.contiguous(n), nil
+	}
+
+	return r.copy(n), nil
+}
+
+// Next 读取n个字节并推进读取位置
+func (r *ConnReader) Next(n int) ([]byte, error) {
+	p, err := r.Peek(n)
+	if err != nil {
+		return nil, err
+	}
+
+	r.skip(n)
+
+	return p, nil
+}
+
+// Read 实现io.Reader接口，兼容仅支持io.Reader的打包器
+func (r *ConnReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.length == 0 {
+		if err := r.fill(1); err != nil {
+			return 0, err
+		}
+	}
+
+	n := len(p)
+	if n > r.length {
+		n = r.length
+	}
+
+	b, off := r.head, r.off
+	for i := 0; i < n; {
+		i += copy(p[i:n], b.buf[off:b.w])
+		b, off = b.next, 0
+	}
+
+	r.skip(n)
+
+	return n, r.Release()
+}
+
+// Slice 截取n个字节作为新的读取器并推进读取位置，数据位于同一内存块时不拷贝
+func (r *ConnReader) Slice(n int) (NocopyReader, error) {
+	if err := r.fill(n); err != nil {
+		return nil, err
+	}
+
+	var s *sliceReader
+
+	if r.head == nil || r.head.w-r.off >= n {
+		s = &sliceReader{buf: r.contiguous(n), block: r.head}
+		if s.block != nil {
+			s.block.retain()
+		}
+	} else {
+		s = &sliceReader{buf: r.copy(n)}
+	}
+
+	r.skip(n)
+
+	return s, nil
+}
+
+// Release 释放已读取的内存块
+func (r *ConnReader) Release() error {
+	for i, b := range r.consumed {
+		b.release()
+		r.consumed[i] = nil
+	}
+	r.consumed = r.consumed[:0]
+
+	// 内存块已读完且未被切片读取器引用时复用
+	if r.head != nil && r.off == r.head.w && atomic.LoadInt32(&r.head.refs) == 1 {
+		r.head.w = 0
+		r.off = 0
+	}
+
+	return nil
+}
+
+// Close 关闭读取器并释放所有内存块，不会关闭底层连接
+func (r *ConnReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	_ = r.Release()
+
+	for b := r.head; b != nil; {
+		next := b.next
+		b.release()
+		b = next
+	}
+
+	r.head, r.tail, r.off, r.length = nil, nil, 0, 0
+
+	return nil
+}
+
+// 从底层读取直到缓冲数据不少于n个字节
+func (r *ConnReader) fill(n int) error {
+	if n < 0 {
+		return errNegativeCount
+	}
+
+	if r.closed {
+		return errReaderClosed
+	}
+
+	for r.length < n {
+		if r.tail == nil || r.tail.w == len(r.tail.buf) {
+			b := getBlock(r.blockSize)
+			if r.tail == nil {
+				r.head, r.off = b, 0
+			} else {
+				r.tail.next = b
+			}
+			r.tail = b
+		}
+
+		m, err := r.reader.Read(r.tail.buf[r.tail.w:])
+		r.tail.w += m
+		r.length += m
+
+		if err != nil {
+			if r.length >= n {
+				return nil
+			}
+
+			if err == io.EOF && r.length > 0 {
+				return io.ErrUnexpectedEOF
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 引用头部内存块中连续的n个字节
+func (r *ConnReader) contiguous(n int) []byte {
+	if r.head == nil {
+		return []byte{}
+	}
+
+	return r.head.buf[r.off : r.off+n : r.off+n]
+}
+
+// 拷贝跨越多个内存块的n个字节
+func (r *ConnReader) copy(n int) []byte {
+	p := make([]byte, n)
+
+	b, off := r.head, r.off
+	for i := 0; i < n; {
+		c := copy(p[i:], b.buf[off:b.w])
+		i += c
+		b, off = b.next, 0
+	}
+
+	return p
+}
+
+// 推进读取位置，读完的内存块在Release时归还
+func (r *ConnReader) skip(n int) {
+	r.length -= n
+
+	for r.head != nil {
+		avail := r.head.w - r.off
+		if n < avail || r.head == r.tail {
+			r.off += n
+			return
+		}
+
+		n -= avail
+		r.consumed = append(r.consumed, r.head)
+		r.head, r.off = r.head.next, 0
+	}
+}
+
+// 切片读取器
+type sliceReader struct {
+	buf   []byte
+	block *block
+}
+
+// Next 读取n个字节并推进读取位置
+func (s *sliceReader) Next(n int) ([]byte, error) {
+	p, err := s.Peek(n)
+	if err != nil {
+		return nil, err
+	}
+
+	s.buf = s.buf[n:]
+
+	return p, nil
+}
+
+// Peek 读取n个字节但不推进读取位置
+func (s *sliceReader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errNegativeCount
+	}
+
+	if n > len(s.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return s.buf[:n:n], nil
+}
+
+// Release 释放引用的内存块
+func (s *sliceReader) Release() error {
+	if s.block != nil {
+		s.block.release()
+		s.block = nil
+	}
+
+	return nil
+}
+
+// Slice 截取n个字节作为新的读取器并推进读取位置
+func (s *sliceReader) Slice(n int) (NocopyReader, error) {
+	p, err := s.Next(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.block != nil {
+		s.block.retain()
+	}
+
+	return &sliceReader{buf: p, block: s.block}, nil
+}
@@ -0,0 +1,124 @@
+package buffer_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// 读取长度前缀帧
+func readFrame(reader buffer.NocopyReader) ([]byte, error) {
+	buf, err := reader.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 4 + int(binary.BigEndian.Uint32(buf))
+
+	r, err := reader.Slice(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if buf, err = r.Next(n); err != nil {
+		return nil, err
+	}
+
+	if err = reader.Release(); err != nil {
+		return nil, err
+	}
+
+	return buf[4:], nil
+}
+
+func frames(payloads ...[]byte) []byte {
+	var stream []byte
+	for _, payload := range payloads {
+		stream = binary.BigEndian.AppendUint32(stream, uint32(len(payload)))
+		stream = append(stream, payload...)
+	}
+
+	return stream
+}
+
+func TestConnReader_Straddle(t *testing.T) {
+	payloads := [][]byte{
+		[]byte("a"),
+		bytes.Repeat([]byte("b"), 11),
+		bytes.Repeat([]byte("c"), 40),
+		{},
+		[]byte("hello world"),
+		bytes.Repeat([]byte("d"), 7),
+	}
+
+	for name, reader := range map[string]io.Reader{
+		"OneByte":  iotest.OneByteReader(bytes.NewReader(frames(payloads...))),
+		"HalfRead": iotest.HalfReader(bytes.NewReader(frames(payloads...))),
+		"DataErr":  iotest.DataErrReader(bytes.NewReader(frames(payloads...))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := buffer.NewConnReader(reader, 16)
+			defer r.Close()
+
+			var got [][]byte
+			for range payloads {
+				payload, err := readFrame(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, payload)
+			}
+
+			// 已读取的帧在后续读取后仍然有效
+			for i, payload := range payloads {
+				if !bytes.Equal(got[i], payload) {
+					t.Fatalf("frame %d = %q, want %q", i, got[i], payload)
+				}
+			}
+
+			if _, err := readFrame(r); err != io.EOF {
+				t.Fatalf("read after last frame = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestConnReader_UnexpectedEOF(t *testing.T) {
+	stream := frames([]byte("truncated"))
+
+	r := buffer.NewConnReader(iotest.OneByteReader(bytes.NewReader(stream[:len(stream)-1])), 8)
+	defer r.Close()
+
+	if _, err := readFrame(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("read truncated frame = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestConnReader_Read(t *testing.T) {
+	stream := frames([]byte("first"), []byte("second"))
+
+	r := buffer.NewConnReader(iotest.HalfReader(bytes.NewReader(stream)), 4)
+	defer r.Close()
+
+	payload, err := readFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(payload) != "first" {
+		t.Fatalf("frame = %q, want %q", payload, "first")
+	}
+
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rest, frames([]byte("second"))) {
+		t.Fatalf("rest = %v, want %v", rest, frames([]byte("second")))
+	}
+}
@@ -3,7 +3,7 @@ package buffer_test
 import (
 	"bytes"
 	"encoding/binary"
-	"go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/buffer"
 	"io"
 	"testing"
 )
@@ -1,14 +1,20 @@
 package encoding
 
 import (
+	"errors"
+	"fmt"
 	"github.com/cute-angelia/go-game-utils/encoding/json"
 	"github.com/cute-angelia/go-game-utils/encoding/msgpack"
 	"github.com/cute-angelia/go-game-utils/encoding/proto"
 	"github.com/cute-angelia/go-game-utils/encoding/xml"
-	"log"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"sync"
 )
 
-var codecs = make(map[string]Codec)
+var (
+	rw     sync.RWMutex
+	codecs = make(map[string]Codec)
+)
 
 func init() {
 	Register(json.DefaultCodec)
@@ -26,31 +32,63 @@ type Codec interface {
 	Unmarshal(data []byte, v interface{}) error
 }
 
-// Register 注册编解码器
+// Register 注册编解码器，编解码器不合法时退出进程
 func Register(codec Codec) {
+	if err := RegisterE(codec); err != nil {
+		logger.Fatal(logger.Default(), "register codec failed", "error", err)
+	}
+}
+
+// RegisterE 注册编解码器，编解码器不合法时返回错误
+func RegisterE(codec Codec) error {
 	if codec == nil {
-		log.Fatal("can't register a invalid codec")
+		return errors.New("can't register a invalid codec")
 	}
 
 	name := codec.Name()
 
 	if name == "" {
-		log.Fatal("can't register a codec without name")
+		return errors.New("can't register a codec without name")
 	}
 
+	rw.Lock()
+	defer rw.Unlock()
+
 	if _, ok := codecs[name]; ok {
-		log.Printf("the old %s codec will be overwritten", name)
+		logger.Default().Warn("the old codec will be overwritten", "codec", name)
 	}
 
 	codecs[name] = codec
+
+	return nil
 }
 
-// Invoke 调用编解码器
-func Invoke(name string) Codec {
+// Lookup 查找编解码器
+func Lookup(name string) (Codec, bool) {
+	rw.RLock()
+	defer rw.RUnlock()
+
 	codec, ok := codecs[name]
-	if !ok {
-		log.Fatalf("%s codec is not registered", name)
+
+	return codec, ok
+}
+
+// Invoke 调用编解码器，编解码器未注册时退出进程
+func Invoke(name string) Codec {
+	codec, err := InvokeE(name)
+	if err != nil {
+		logger.Fatal(logger.Default(), "invoke codec failed", "error", err)
 	}
 
 	return codec
 }
+
+// InvokeE 调用编解码器，编解码器未注册时返回错误
+func InvokeE(name string) (Codec, error) {
+	codec, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("codec %q is not registered", name)
+	}
+
+	return codec, nil
+}
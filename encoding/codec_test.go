@@ -0,0 +1,32 @@
+package encoding_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/encoding"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	if codec, ok := encoding.Lookup("json"); !ok || codec.Name() != "json" {
+		t.Fatal("json codec should be registered")
+	}
+
+	if _, ok := encoding.Lookup("jsno"); ok {
+		t.Fatal("unknown codec should not be found")
+	}
+}
+
+func TestRegisterE(t *testing.T) {
+	if err := encoding.RegisterE(nil); err == nil {
+		t.Fatal("register nil codec should fail")
+	}
+}
+
+func TestInvokeE(t *testing.T) {
+	if codec, err := encoding.InvokeE("msgpack"); err != nil || codec.Name() != "msgpack" {
+		t.Fatalf("invoke msgpack codec: %v", err)
+	}
+
+	if _, err := encoding.InvokeE("jsno"); err == nil {
+		t.Fatal("invoke unknown codec should fail")
+	}
+}
@@ -0,0 +1,85 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 连接相关错误
+var (
+	ErrConnectionClosed       = errors.New("ErrConnectionClosed")       // 连接已关闭
+	ErrConnectionHanged       = errors.New("ErrConnectionHanged")       // 连接已挂起
+	ErrConnectionNotOpened    = errors.New("ErrConnectionNotOpened")    // 连接未打开
+	ErrConnectionNotHanged    = errors.New("ErrConnectionNotHanged")    // 连接未挂起
+	ErrConnectionReconnecting = errors.New("ErrConnectionReconnecting") // 连接重连中
+	ErrConnectionNotFound     = errors.New("ErrConnectionNotFound")     // 连接不存在
+	ErrTooManyConnection      = errors.New("ErrTooManyConnection")      // 连接数超过上限
+	ErrUserNotFound           = errors.New("ErrUserNotFound")           // 用户不存在
+	ErrInvalidUID             = errors.New("ErrInvalidUID")             // 用户ID不合法
+	ErrWriteQueueFull         = errors.New("ErrWriteQueueFull")         // 写入队列已满
+)
+
+// 打包器相关错误
+var (
+	ErrNoPacker          = errors.New("ErrNoPacker")          // 未配置打包器
+	ErrInvalidReader     = errors.New("ErrInvalidReader")     // 不支持的读取器
+	ErrInvalidMessage    = errors.New("ErrInvalidMessage")    // 消息格式不合法
+	ErrInvalidData       = errors.New("ErrInvalidData")       // 消息负载不合法
+	ErrMessageTooLarge   = errors.New("ErrMessageTooLarge")   // 消息过大
+	ErrFrameTooLarge     = errors.New("ErrFrameTooLarge")     // 数据帧长度超过上限
+	ErrFrameTooSmall     = errors.New("ErrFrameTooSmall")     // 数据帧长度小于头部长度
+	ErrRouteOverflow     = errors.New("ErrRouteOverflow")     // 路由超出范围
+	ErrSeqOverflow       = errors.New("ErrSeqOverflow")       // 序列号超出范围
+	ErrReplyNotSupported = errors.New("ErrReplyNotSupported") // 打包器不支持回复
+)
+
+// 调用相关错误
+var (
+	ErrSeqDisabled         = errors.New("ErrSeqDisabled")         // 未启用序列号
+	ErrTooManyPendingCalls = errors.New("ErrTooManyPendingCalls") // 等待响应的调用过多
+)
+
+// SizeError 长度错误，包含实际长度与限制长度
+type SizeError struct {
+	Err   error // 哨兵错误
+	Size  int   // 实际长度
+	Limit int   // 限制长度
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%v: size %d, limit %d", e.Err, e.Size, e.Limit)
+}
+
+func (e *SizeError) Unwrap() error {
+	return e.Err
+}
+
+// RangeError 取值超出范围错误，包含实际值与允许范围，如路由、序列号
+type RangeError struct {
+	Err   error // 哨兵错误
+	Value int64 // 实际值
+	Min   int64 // 最小值
+	Max   int64 // 最大值
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%v: value %d out of range [%d, %d]", e.Err, e.Value, e.Min, e.Max)
+}
+
+func (e *RangeError) Unwrap() error {
+	return e.Err
+}
+
+// StateError 连接状态错误，包含连接当前状态
+type StateError struct {
+	Err   error // 哨兵错误
+	State int32 // 连接当前状态，取值同network.ConnState
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("%v: state %d", e.Err, e.State)
+}
+
+func (e *StateError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,273 @@
+package group
+
+import (
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"hash/fnv"
+	"sync"
+)
+
+const defaultPartitionNum = 256
+
+type group struct {
+	rw      sync.RWMutex
+	members map[int64]network.Conn // 连接ID -> 连接
+}
+
+type member struct {
+	id   int64        // 加入分组时的连接ID
+	conn network.Conn // 连接
+}
+
+// 连接是否仍是加入分组时的连接，池化的连接关闭后会以新的连接ID复用
+func (m member) valid() bool {
+	return m.conn.ID() == m.id
+}
+
+// 获取成员快照
+func (g *group) snapshot() []member {
+	g.rw.RLock()
+	defer g.rw.RUnlock()
+
+	members := make([]member, 0, len(g.members))
+	for id, conn := range g.members {
+		members = append(members, member{id: id, conn: conn})
+	}
+
+	return members
+}
+
+// Manager 分组管理器，分组与连接按分片加锁，不存在全局锁
+// 需在服务器的OnDisconnect中调用Remove，使关闭的连接退出所有分组
+// 成员按加入时的连接ID记录，连接ID变化的成员视为已关闭，不会收到推送
+type Manager struct {
+	groups []*groupPartition // 分组名 -> 分组
+	conns  []*connPartition  // 连接ID -> 所在分组
+}
+
+func NewManager() *Manager {
+	m := &Manager{
+		groups: make([]*groupPartition, defaultPartitionNum),
+		conns:  make([]*connPartition, defaultPartitionNum),
+	}
+
+	for i := 0; i < defaultPartitionNum; i++ {
+		m.groups[i] = &groupPartition{groups: make(map[string]*group)}
+		m.conns[i] = &connPartition{conns: make(map[int64]map[string]struct{})}
+	}
+
+	return m
+}
+
+// Join 连接加入分组，分组不存在时自动创建
+func (m *Manager) Join(name string, conn network.Conn) {
+	cid := conn.ID()
+	p := m.groupPartition(name)
+
+	// 持有分组分片锁时同时更新分组与连接所在分组，加锁顺序为分组分片、分组、连接分片
+	p.rw.Lock()
+	defer p.rw.Unlock()
+
+	g, ok := p.groups[name]
+	if !ok {
+		g = &group{members: make(map[int64]network.Conn)}
+		p.groups[name] = g
+	}
+	g.rw.Lock()
+	g.members[cid] = conn
+	g.rw.Unlock()
+
+	m.connPartition(cid).add(cid, name)
+}
+
+// Leave 连接离开分组，分组为空时自动删除
+func (m *Manager) Leave(name string, conn network.Conn) {
+	m.leave(name, conn.ID())
+}
+
+// Remove 连接离开所有分组
+func (m *Manager) Remove(conn network.Conn) {
+	m.remove(conn.ID())
+}
+
+// Groups 获取连接所在的分组
+func (m *Manager) Groups(conn network.Conn) []string {
+	return m.connPartition(conn.ID()).load(conn.ID())
+}
+
+// Members 获取分组成员，不包含连接ID已变化的成员
+func (m *Manager) Members(name string) []network.Conn {
+	g, ok := m.load(name)
+	if !ok {
+		return nil
+	}
+
+	members := g.snapshot()
+	conns := make([]network.Conn, 0, len(members))
+	for _, mb := range members {
+		if mb.valid() {
+			conns = append(conns, mb.conn)
+		}
+	}
+
+	return conns
+}
+
+// Count 分组成员数，与Members一致不包含连接ID已变化的成员
+func (m *Manager) Count(name string) int {
+	g, ok := m.load(name)
+	if !ok {
+		return 0
+	}
+
+	g.rw.RLock()
+	defer g.rw.RUnlock()
+
+	n := 0
+	for id, conn := range g.members {
+		if (member{id: id, conn: conn}).valid() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Broadcast 推送已打包的消息给分组内所有连接，返回推送成功的数量
+// 已关闭或连接ID已变化的连接会被移出分组
+func (m *Manager) Broadcast(name string, msg []byte) int {
+	g, ok := m.load(name)
+	if !ok {
+		return 0
+	}
+
+	n := 0
+	for _, mb := range g.snapshot() {
+		if mb.conn.State() == network.ConnClosed {
+			m.remove(mb.id)
+			continue
+		}
+
+		// 由连接按加入时的连接ID推送，避免校验后连接被复用
+		switch err := network.PushID(mb.conn, mb.id, msg); {
+		case err == nil:
+			n++
+		case errors.Is(err, errs.ErrConnectionClosed):
+			m.remove(mb.id)
+		}
+	}
+
+	return n
+}
+
+// BroadcastMessage 打包一次消息并推送给分组内所有连接
+func (m *Manager) BroadcastMessage(name string, packer ipacket.Packer, message ipacket.Message) (int, error) {
+	msg, err := packer.PackMessage(message)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.Broadcast(name, msg), nil
+}
+
+// 加载分组
+func (m *Manager) load(name string) (*group, bool) {
+	p := m.groupPartition(name)
+
+	p.rw.RLock()
+	g, ok := p.groups[name]
+	p.rw.RUnlock()
+
+	return g, ok
+}
+
+// 从分组中移除连接
+func (m *Manager) leave(name string, cid int64) {
+	p := m.groupPartition(name)
+
+	p.rw.Lock()
+	defer p.rw.Unlock()
+
+	m.connPartition(cid).delete(cid, name)
+
+	g, ok := p.groups[name]
+	if !ok {
+		return
+	}
+
+	g.rw.Lock()
+	delete(g.members, cid)
+	empty := len(g.members) == 0
+	g.rw.Unlock()
+
+	if empty {
+		delete(p.groups, name)
+	}
+}
+
+// 从所有分组中移除连接
+func (m *Manager) remove(cid int64) {
+	for _, name := range m.connPartition(cid).load(cid) {
+		m.leave(name, cid)
+	}
+}
+
+func (m *Manager) groupPartition(name string) *groupPartition {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return m.groups[h.Sum32()%uint32(len(m.groups))]
+}
+
+func (m *Manager) connPartition(cid int64) *connPartition {
+	return m.conns[uint64(cid)%uint64(len(m.conns))]
+}
+
+type groupPartition struct {
+	rw     sync.RWMutex
+	groups map[string]*group
+}
+
+type connPartition struct {
+	rw    sync.RWMutex
+	conns map[int64]map[string]struct{}
+}
+
+// 记录连接所在分组
+func (p *connPartition) add(cid int64, name string) {
+	p.rw.Lock()
+	names, ok := p.conns[cid]
+	if !ok {
+		names = make(map[string]struct{})
+		p.conns[cid] = names
+	}
+	names[name] = struct{}{}
+	p.rw.Unlock()
+}
+
+// 删除连接所在分组
+func (p *connPartition) delete(cid int64, name string) {
+	p.rw.Lock()
+	if names, ok := p.conns[cid]; ok {
+		delete(names, name)
+		if len(names) == 0 {
+			delete(p.conns, cid)
+		}
+	}
+	p.rw.Unlock()
+}
+
+// 加载连接所在分组
+func (p *connPartition) load(cid int64) []string {
+	p.rw.RLock()
+	defer p.rw.RUnlock()
+
+	names := p.conns[cid]
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+
+	return list
+}
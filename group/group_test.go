@@ -0,0 +1,167 @@
+package group_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/group"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type conn struct {
+	network.Conn
+	id     int64
+	state  network.ConnState
+	pushed int64
+}
+
+func (c *conn) ID() int64                { return c.id }
+func (c *conn) State() network.ConnState { return c.state }
+func (c *conn) Push(_ []byte) error      { atomic.AddInt64(&c.pushed, 1); return nil }
+
+func TestManager_Broadcast(t *testing.T) {
+	m := group.NewManager()
+	packer := due.NewPacker()
+
+	c1 := &conn{id: 1, state: network.ConnOpened}
+	c2 := &conn{id: 2, state: network.ConnOpened}
+	c3 := &conn{id: 3, state: network.ConnOpened}
+
+	m.Join("table-42", c1)
+	m.Join("table-42", c2)
+	m.Join("table-43", c2)
+	m.Join("table-43", c3)
+
+	n, err := m.BroadcastMessage("table-42", packer, &due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 || c1.pushed != 1 || c2.pushed != 1 || c3.pushed != 0 {
+		t.Fatalf("unexpected broadcast result: %d %d %d %d", n, c1.pushed, c2.pushed, c3.pushed)
+	}
+
+	c3.state = network.ConnClosed
+
+	if n = m.Broadcast("table-43", []byte("hello")); n != 1 {
+		t.Fatalf("broadcast pushed %d, want 1", n)
+	}
+
+	if m.Count("table-43") != 1 {
+		t.Fatal("closed conn should be removed from group")
+	}
+
+	m.Remove(c2)
+
+	if m.Count("table-43") != 0 || len(m.Groups(c2)) != 0 {
+		t.Fatal("removed conn should leave all groups")
+	}
+
+	m.Leave("table-42", c1)
+
+	if m.Members("table-42") != nil {
+		t.Fatal("empty group should be deleted")
+	}
+}
+
+func TestManager_Concurrent(t *testing.T) {
+	m := group.NewManager()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			c := &conn{id: int64(i + 1), state: network.ConnOpened}
+			name := strconv.Itoa(i % 100)
+
+			m.Join(name, c)
+			m.Broadcast(name, []byte("hello"))
+			m.Remove(c)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 100; i++ {
+		if m.Count(strconv.Itoa(i)) != 0 {
+			t.Fatalf("group %d should be empty", i)
+		}
+	}
+}
+
+func TestManager_ReusedConn(t *testing.T) {
+	m := group.NewManager()
+
+	c := &conn{id: 1, state: network.ConnOpened}
+	m.Join("table-42", c)
+
+	// 连接关闭后未调用Remove，池化的连接以新的连接ID复用
+	c.id = 2
+
+	if members := m.Members("table-42"); len(members) != 0 {
+		t.Fatalf("reused conn should not be a member, got %d members", len(members))
+	}
+
+	if n := m.Count("table-42"); n != 0 {
+		t.Fatalf("reused conn should not be counted, got %d", n)
+	}
+
+	if n := m.Broadcast("table-42", []byte("hello")); n != 0 || c.pushed != 0 {
+		t.Fatalf("reused conn should not receive broadcast, pushed %d", c.pushed)
+	}
+
+	if m.Count("table-42") != 0 {
+		t.Fatal("stale member should be removed from group")
+	}
+
+	c.id = 1
+	if len(m.Groups(c)) != 0 {
+		t.Fatal("stale member should be removed from conn groups")
+	}
+}
+
+func TestManager_JoinRemoveConsistent(t *testing.T) {
+	m := group.NewManager()
+
+	for i := 0; i < 200; i++ {
+		c := &conn{id: int64(i + 1), state: network.ConnOpened}
+
+		var wg sync.WaitGroup
+		for _, name := range []string{"a", "b", "c"} {
+			wg.Add(2)
+			go func(name string) {
+				defer wg.Done()
+				m.Join(name, c)
+			}(name)
+			go func() {
+				defer wg.Done()
+				m.Remove(c)
+			}()
+		}
+		wg.Wait()
+
+		// 分组成员与连接所在分组保持一致
+		joined := make(map[string]bool)
+		for _, name := range m.Groups(c) {
+			joined[name] = true
+		}
+
+		for _, name := range []string{"a", "b", "c"} {
+			member := false
+			for _, mc := range m.Members(name) {
+				if mc.ID() == c.ID() {
+					member = true
+				}
+			}
+
+			if member != joined[name] {
+				t.Fatalf("conn %d member of %s = %v, but groups record %v", c.ID(), name, member, joined[name])
+			}
+		}
+
+		m.Remove(c)
+	}
+}
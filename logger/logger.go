@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+// Logger 分级日志接口，args为键值对形式的字段，例如 "cid", 1, "uid", 2
+type Logger interface {
+	// Debug 调试日志
+	Debug(msg string, args ...interface{})
+	// Info 信息日志
+	Info(msg string, args ...interface{})
+	// Warn 警告日志
+	Warn(msg string, args ...interface{})
+	// Error 错误日志
+	Error(msg string, args ...interface{})
+	// With 返回附带固定字段的日志器
+	With(args ...interface{}) Logger
+}
+
+var global atomic.Value
+
+func init() {
+	global.Store(holder{NewSlog(slog.Default())})
+}
+
+type holder struct {
+	Logger
+}
+
+// SetDefault 设置全局默认日志器，未通过选项注入日志器的组件均使用该日志器
+func SetDefault(l Logger) {
+	if l == nil {
+		l = Nop()
+	}
+
+	global.Store(holder{l})
+}
+
+// Default 获取全局默认日志器，返回的日志器始终跟随SetDefault的最新设置
+func Default() Logger {
+	return deferred{}
+}
+
+// Fatal 输出错误日志后退出进程
+func Fatal(l Logger, msg string, args ...interface{}) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
+
+func current() Logger {
+	return global.Load().(holder).Logger
+}
+
+// 延迟到输出时才解析全局日志器
+type deferred struct {
+	args []interface{}
+}
+
+func (d deferred) resolve() Logger {
+	if len(d.args) == 0 {
+		return current()
+	}
+
+	return current().With(d.args...)
+}
+
+func (d deferred) Debug(msg string, args ...interface{}) { d.resolve().Debug(msg, args...) }
+
+func (d deferred) Info(msg string, args ...interface{}) { d.resolve().Info(msg, args...) }
+
+func (d deferred) Warn(msg string, args ...interface{}) { d.resolve().Warn(msg, args...) }
+
+func (d deferred) Error(msg string, args ...interface{}) { d.resolve().Error(msg, args...) }
+
+func (d deferred) With(args ...interface{}) Logger {
+	return deferred{args: append(append([]interface{}(nil), d.args...), args...)}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlog 基于log/slog创建日志器
+func NewSlog(l *slog.Logger) Logger {
+	return &slogLogger{logger: l}
+}
+
+func (l *slogLogger) Debug(msg string, args ...interface{}) {
+	l.logger.Debug(msg, args...)
+}
+
+func (l *slogLogger) Info(msg string, args ...interface{}) {
+	l.logger.Info(msg, args...)
+}
+
+func (l *slogLogger) Warn(msg string, args ...interface{}) {
+	l.logger.Warn(msg, args...)
+}
+
+func (l *slogLogger) Error(msg string, args ...interface{}) {
+	l.logger.Error(msg, args...)
+}
+
+func (l *slogLogger) With(args ...interface{}) Logger {
+	return &slogLogger{logger: l.logger.With(args...)}
+}
+
+type nopLogger struct{}
+
+// Nop 创建不输出任何日志的日志器
+func Nop() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+
+func (nopLogger) Info(msg string, args ...interface{}) {}
+
+func (nopLogger) Warn(msg string, args ...interface{}) {}
+
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+func (n nopLogger) With(args ...interface{}) Logger { return n }
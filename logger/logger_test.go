@@ -0,0 +1,34 @@
+package logger_test
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDefault_FollowsSetDefault(t *testing.T) {
+	l := logger.Default().With("cid", 1)
+
+	var buf bytes.Buffer
+	logger.SetDefault(logger.NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	defer logger.SetDefault(logger.NewSlog(slog.Default()))
+
+	l.With("uid", 2).Debug("hello", "route", 3)
+
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG", "msg=hello", "cid=1", "uid=2", "route=3"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output %q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestSetDefault_Nil(t *testing.T) {
+	logger.SetDefault(nil)
+	defer logger.SetDefault(logger.NewSlog(slog.Default()))
+
+	// 设置为nil时退化为不输出日志的日志器
+	logger.Default().With("cid", 1).Error("ignored")
+}
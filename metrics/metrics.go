@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"sync/atomic"
+)
+
+// 内置指标名称
+const (
+	ConnectionsActive   = "network_connections_active"          // 当前连接数，标签protocol、addr
+	ConnectionsAccepted = "network_connections_accepted_total"  // 接受的连接数，标签protocol、addr
+	ConnectionsRejected = "network_connections_rejected_total"  // 超出最大连接数被拒绝的连接数，标签protocol、addr
+	BytesIn             = "network_bytes_in_total"              // 读取的字节数，标签protocol、addr
+	BytesOut            = "network_bytes_out_total"             // 写入的字节数，标签protocol、addr
+	FramesIn            = "network_frames_in_total"             // 读取的帧数，标签protocol、addr
+	FramesOut           = "network_frames_out_total"            // 写入的帧数，标签protocol、addr
+	HeartbeatTimeouts   = "network_heartbeat_timeouts_total"    // 心跳超时断开的连接数，标签protocol、addr
+	WriteQueueDepth     = "network_write_queue_depth"           // 写入队列中待发送的消息数，标签protocol、addr
+	WriteQueueOverflows = "network_write_queue_overflows_total" // 写入队列溢出丢弃的消息数，标签protocol、addr、policy
+	HandlerLatency      = "network_handler_latency_seconds"     // 接收消息hook函数耗时，标签protocol、addr
+	PackErrors          = "packet_errors_total"                 // 打包解包错误数，标签packer、op
+)
+
+// Metrics 指标采集接口，labels为键值对形式的标签，例如 "protocol", "tcp"
+type Metrics interface {
+	// Add 计数器累加
+	Add(name string, delta float64, labels ...string)
+	// Observe 直方图采样
+	Observe(name string, value float64, labels ...string)
+	// Gauge 注册仪表盘，采集时调用fn获取当前值，返回注销函数
+	Gauge(name string, fn func() float64, labels ...string) (unregister func())
+}
+
+var global atomic.Value
+
+func init() {
+	global.Store(holder{Nop()})
+}
+
+type holder struct {
+	Metrics
+}
+
+// SetDefault 设置全局默认采集器，未通过选项注入采集器的组件均使用该采集器
+func SetDefault(m Metrics) {
+	if m == nil {
+		m = Nop()
+	}
+
+	global.Store(holder{m})
+}
+
+// Default 获取全局默认采集器，返回的采集器始终跟随SetDefault的最新设置
+func Default() Metrics {
+	return deferred{}
+}
+
+func current() Metrics {
+	return global.Load().(holder).Metrics
+}
+
+// 延迟到采集时才解析全局采集器
+type deferred struct{}
+
+func (deferred) Add(name string, delta float64, labels ...string) {
+	current().Add(name, delta, labels...)
+}
+
+func (deferred) Observe(name string, value float64, labels ...string) {
+	current().Observe(name, value, labels...)
+}
+
+func (deferred) Gauge(name string, fn func() float64, labels ...string) func() {
+	return current().Gauge(name, fn, labels...)
+}
+
+type nopMetrics struct{}
+
+// Nop 创建不采集任何指标的采集器
+func Nop() Metrics {
+	return nopMetrics{}
+}
+
+func (nopMetrics) Add(name string, delta float64, labels ...string) {}
+
+func (nopMetrics) Observe(name string, value float64, labels ...string) {}
+
+func (nopMetrics) Gauge(name string, fn func() float64, labels ...string) func() {
+	return func() {}
+}
@@ -0,0 +1,436 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// DefaultBuckets 默认直方图分桶，单位秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 内置指标说明
+var builtinHelps = map[string]string{
+	ConnectionsActive:   "Number of currently open connections.",
+	ConnectionsAccepted: "Total number of accepted connections.",
+	ConnectionsRejected: "Total number of connections rejected by the connection limit.",
+	BytesIn:             "Total number of bytes read from connections.",
+	BytesOut:            "Total number of bytes written to connections.",
+	FramesIn:            "Total number of frames read from connections.",
+	FramesOut:           "Total number of frames written to connections.",
+	HeartbeatTimeouts:   "Total number of connections closed by heartbeat timeout.",
+	WriteQueueDepth:     "Number of messages waiting in connection write queues.",
+	WriteQueueOverflows: "Total number of messages dropped by write queue overflow.",
+	HandlerLatency:      "Latency of the receive handler in seconds.",
+	PackErrors:          "Total number of pack and unpack errors.",
+}
+
+var _ Metrics = &Prometheus{}
+
+var _ http.Handler = &Prometheus{}
+
+// Prometheus 以Prometheus文本格式输出指标的采集器
+// 已存在的指标序列按标签哈希查找，仅持有读锁，计数与采样使用原子操作，不分配内存
+type Prometheus struct {
+	mu       sync.RWMutex
+	buckets  []float64
+	families map[string]*family
+}
+
+type family struct {
+	typ    string
+	help   string
+	series map[uint64][]*series // 标签哈希 -> 指标序列，哈希冲突时按标签区分
+}
+
+type series struct {
+	value   uint64         // 计数器的值，按位存储的float64
+	count   uint64         // 直方图采样数
+	sum     uint64         // 直方图采样值之和，按位存储的float64
+	counts  []uint64       // 直方图各分桶的采样数
+	hash    uint64         // 标签哈希
+	raw     []string       // 标签键值对
+	labels  string         // 格式化后的标签
+	fn      func() float64 // 仪表盘取值函数
+	gaugeID int64
+}
+
+// NewPrometheus 创建Prometheus采集器，buckets为直方图分桶，为空时使用DefaultBuckets
+func NewPrometheus(buckets ...float64) *Prometheus {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Prometheus{buckets: buckets, families: make(map[string]*family)}
+}
+
+// Describe 设置指标说明
+func (p *Prometheus) Describe(name, help string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if f, ok := p.families[name]; ok {
+		f.help = help
+	} else {
+		p.families[name] = &family{help: help, series: make(map[uint64][]*series)}
+	}
+}
+
+// Add 计数器累加
+func (p *Prometheus) Add(name string, delta float64, labels ...string) {
+	if s, ok := p.series(name, counterType, labels); ok {
+		addFloat(&s.value, delta)
+	}
+}
+
+// Observe 直方图采样
+func (p *Prometheus) Observe(name string, value float64, labels ...string) {
+	s, ok := p.series(name, histogramType, labels)
+	if !ok {
+		return
+	}
+
+	for i, bound := range p.buckets {
+		if value <= bound {
+			atomic.AddUint64(&s.counts[i], 1)
+		}
+	}
+
+	atomic.AddUint64(&s.count, 1)
+	addFloat(&s.sum, value)
+}
+
+// Gauge 注册仪表盘，相同名称与标签重复注册时覆盖之前的注册
+func (p *Prometheus) Gauge(name string, fn func() float64, labels ...string) func() {
+	p.mu.Lock()
+	s, ok := p.create(name, gaugeType, hashLabels(labels), labels)
+	if !ok {
+		p.mu.Unlock()
+		return func() {}
+	}
+	s.fn = fn
+	s.gaugeID++
+	id := s.gaugeID
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if f, ok := p.families[name]; ok && s.gaugeID == id {
+			f.remove(s)
+		}
+	}
+}
+
+// ServeHTTP 输出Prometheus文本格式的指标
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(p.Expose())
+}
+
+// Expose 生成Prometheus文本格式的指标
+func (p *Prometheus) Expose() []byte {
+	type sample struct {
+		labels string
+		value  float64
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+
+	type gauge struct {
+		labels string
+		fn     func() float64
+	}
+
+	type snapshot struct {
+		name    string
+		typ     string
+		help    string
+		samples []sample
+		gauges  []gauge
+	}
+
+	p.mu.RLock()
+	snapshots := make([]snapshot, 0, len(p.families))
+	for name, f := range p.families {
+		if f.typ == "" {
+			continue
+		}
+
+		snap := snapshot{name: name, typ: f.typ, help: f.help}
+		for _, list := range f.series {
+			for _, s := range list {
+				switch f.typ {
+				case gaugeType:
+					snap.gauges = append(snap.gauges, gauge{labels: s.labels, fn: s.fn})
+				case histogramType:
+					counts := make([]uint64, len(s.counts))
+					for i := range s.counts {
+						counts[i] = atomic.LoadUint64(&s.counts[i])
+					}
+					snap.samples = append(snap.samples, sample{labels: s.labels, counts: counts, count: atomic.LoadUint64(&s.count), sum: loadFloat(&s.sum)})
+				default:
+					snap.samples = append(snap.samples, sample{labels: s.labels, value: loadFloat(&s.value)})
+				}
+			}
+		}
+		snapshots = append(snapshots, snap)
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].name < snapshots[j].name })
+
+	var buf bytes.Buffer
+
+	for _, snap := range snapshots {
+		if snap.help != "" {
+			fmt.Fprintf(&buf, "# HELP %s %s\n", snap.name, escapeHelp(snap.help))
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", snap.name, snap.typ)
+
+		// 仪表盘在锁外取值，避免fn内部调用采集器导致死锁
+		for _, g := range snap.gauges {
+			snap.samples = append(snap.samples, sample{labels: g.labels, value: g.fn()})
+		}
+
+		sort.Slice(snap.samples, func(i, j int) bool { return snap.samples[i].labels < snap.samples[j].labels })
+
+		for _, s := range snap.samples {
+			if snap.typ != histogramType {
+				fmt.Fprintf(&buf, "%s%s %s\n", snap.name, braces(s.labels), formatFloat(s.value))
+				continue
+			}
+
+			for i, bound := range p.buckets {
+				fmt.Fprintf(&buf, "%s_bucket%s %d\n", snap.name, braces(joinLabels(s.labels, `le="`+formatFloat(bound)+`"`)), s.counts[i])
+			}
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", snap.name, braces(joinLabels(s.labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", snap.name, braces(s.labels), formatFloat(s.sum))
+			fmt.Fprintf(&buf, "%s_count%s %d\n", snap.name, braces(s.labels), s.count)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// 获取指标序列，指标类型与首次使用时不一致时忽略
+// 序列已存在时仅持有读锁查找，不存在时持有写锁创建
+func (p *Prometheus) series(name, typ string, labels []string) (*series, bool) {
+	hash := hashLabels(labels)
+
+	p.mu.RLock()
+	s, ok := p.lookup(name, typ, hash, labels)
+	p.mu.RUnlock()
+
+	if s != nil || !ok {
+		return s, ok
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.create(name, typ, hash, labels)
+}
+
+// 获取指标序列，不存在时创建，调用方需持有写锁
+func (p *Prometheus) create(name, typ string, hash uint64, labels []string) (*series, bool) {
+	if s, ok := p.lookup(name, typ, hash, labels); s != nil || !ok {
+		return s, ok
+	}
+
+	f, ok := p.families[name]
+	if !ok {
+		f = &family{help: builtinHelps[name], series: make(map[uint64][]*series)}
+		p.families[name] = f
+	}
+
+	if f.typ == "" {
+		f.typ = typ
+	}
+
+	raw := append([]string(nil), labels[:len(labels)&^1]...)
+	s := &series{hash: hash, raw: raw, labels: formatLabels(raw)}
+
+	if typ == histogramType {
+		s.counts = make([]uint64, len(p.buckets))
+	}
+
+	f.series[hash] = append(f.series[hash], s)
+
+	return s, true
+}
+
+// 查找指标序列，调用方需持有锁；指标类型不一致时返回false，序列不存在时返回nil
+func (p *Prometheus) lookup(name, typ string, hash uint64, labels []string) (*series, bool) {
+	f, ok := p.families[name]
+	if !ok || f.typ == "" {
+		return nil, true
+	}
+
+	if f.typ != typ {
+		return nil, false
+	}
+
+	for _, s := range f.series[hash] {
+		if s.match(labels) {
+			return s, true
+		}
+	}
+
+	return nil, true
+}
+
+// 移除指标序列，调用方需持有写锁
+func (f *family) remove(s *series) {
+	list := f.series[s.hash]
+
+	for i, item := range list {
+		if item == s {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(list) == 0 {
+		delete(f.series, s.hash)
+	} else {
+		f.series[s.hash] = list
+	}
+}
+
+// 标签是否一致，奇数个参数时忽略最后一个
+func (s *series) match(labels []string) bool {
+	n := len(labels) &^ 1
+	if len(s.raw) != n {
+		return false
+	}
+
+	for i := 0; i < n; i++ {
+		if s.raw[i] != labels[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// 计算标签的FNV-1a哈希，奇数个参数时忽略最后一个
+func hashLabels(labels []string) uint64 {
+	h := uint64(fnvOffset)
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		h = hashString(h, labels[i])
+		h = hashString(h, labels[i+1])
+	}
+
+	return h
+}
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+
+	// 分隔符，区分("ab","c")与("a","bc")
+	h ^= 0xff
+	h *= fnvPrime
+
+	return h
+}
+
+// 原子累加按位存储的float64
+func addFloat(addr *uint64, delta float64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if atomic.CompareAndSwapUint64(addr, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// 原子读取按位存储的float64
+func loadFloat(addr *uint64) float64 {
+	return math.Float64frombits(atomic.LoadUint64(addr))
+}
+
+// 格式化标签，奇数个参数时忽略最后一个
+func formatLabels(labels []string) string {
+	var sb strings.Builder
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(labels[i+1]))
+		sb.WriteByte('"')
+	}
+
+	return sb.String()
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+
+	return labels + "," + label
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,116 @@
+package metrics_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrometheus_ServeHTTP(t *testing.T) {
+	p := metrics.NewPrometheus(0.1, 1)
+
+	p.Add(metrics.FramesIn, 2, "protocol", "tcp")
+	p.Add(metrics.FramesIn, 3, "protocol", "tcp")
+	p.Add(metrics.PackErrors, 1, "packer", `a"b`, "op", "pack")
+	p.Observe(metrics.HandlerLatency, 0.05, "protocol", "tcp")
+	p.Observe(metrics.HandlerLatency, 0.5, "protocol", "tcp")
+	unregister := p.Gauge(metrics.ConnectionsActive, func() float64 { return 7 }, "protocol", "tcp")
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	body, _ := io.ReadAll(rec.Body)
+	text := string(body)
+
+	for _, line := range []string{
+		"# TYPE network_frames_in_total counter",
+		`network_frames_in_total{protocol="tcp"} 5`,
+		`packet_errors_total{packer="a\"b",op="pack"} 1`,
+		"# TYPE network_handler_latency_seconds histogram",
+		`network_handler_latency_seconds_bucket{protocol="tcp",le="0.1"} 1`,
+		`network_handler_latency_seconds_bucket{protocol="tcp",le="1"} 2`,
+		`network_handler_latency_seconds_bucket{protocol="tcp",le="+Inf"} 2`,
+		`network_handler_latency_seconds_sum{protocol="tcp"} 0.55`,
+		`network_handler_latency_seconds_count{protocol="tcp"} 2`,
+		`network_connections_active{protocol="tcp"} 7`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+
+	unregister()
+
+	if strings.Contains(string(p.Expose()), `network_connections_active{`) {
+		t.Fatal("gauge still exposed after unregister")
+	}
+}
+
+func TestPrometheus_TypeMismatch(t *testing.T) {
+	p := metrics.NewPrometheus()
+
+	p.Add("requests", 1)
+	p.Observe("requests", 1)
+
+	if text := string(p.Expose()); !strings.Contains(text, "requests 1\n") || strings.Contains(text, "requests_bucket") {
+		t.Fatalf("unexpected exposition:\n%s", text)
+	}
+}
+
+func TestPrometheus_NoAllocs(t *testing.T) {
+	p := metrics.NewPrometheus()
+	labels := []string{"protocol", "tcp", "addr", ":3553"}
+
+	p.Add(metrics.FramesIn, 1, labels...)
+	p.Observe(metrics.HandlerLatency, 0.1, labels...)
+
+	// 已存在的指标序列不分配内存
+	if n := testing.AllocsPerRun(100, func() {
+		p.Add(metrics.FramesIn, 1, labels...)
+		p.Observe(metrics.HandlerLatency, 0.1, labels...)
+	}); n != 0 {
+		t.Fatalf("allocs per run = %v, want 0", n)
+	}
+}
+
+func TestPrometheus_Concurrent(t *testing.T) {
+	p := metrics.NewPrometheus(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			protocol := strconv.Itoa(i % 2)
+			for j := 0; j < 1000; j++ {
+				p.Add(metrics.FramesIn, 1, "protocol", protocol)
+				p.Observe(metrics.HandlerLatency, 0.5, "protocol", protocol)
+				if j%100 == 0 {
+					_ = p.Expose()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	text := string(p.Expose())
+	for _, line := range []string{
+		`network_frames_in_total{protocol="0"} 4000`,
+		`network_frames_in_total{protocol="1"} 4000`,
+		`network_handler_latency_seconds_bucket{protocol="0",le="1"} 4000`,
+		`network_handler_latency_seconds_sum{protocol="1"} 2000`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+}
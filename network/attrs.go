@@ -0,0 +1,65 @@
+package network
+
+import (
+	"sync"
+)
+
+// Attrs 并发安全的连接属性集合，用于在连接上保存区服、设备类型、当前房间等业务数据
+type Attrs struct {
+	rw     sync.RWMutex
+	values map[string]interface{}
+}
+
+// Get 获取属性
+func (a *Attrs) Get(key string) (interface{}, bool) {
+	a.rw.RLock()
+	defer a.rw.RUnlock()
+
+	value, ok := a.values[key]
+
+	return value, ok
+}
+
+// Set 设置属性
+func (a *Attrs) Set(key string, value interface{}) {
+	a.rw.Lock()
+	defer a.rw.Unlock()
+
+	if a.values == nil {
+		a.values = make(map[string]interface{})
+	}
+
+	a.values[key] = value
+}
+
+// Delete 删除属性
+func (a *Attrs) Delete(key string) {
+	a.rw.Lock()
+	defer a.rw.Unlock()
+
+	delete(a.values, key)
+}
+
+// Range 遍历属性，fn返回false时停止遍历；遍历的是属性快照，fn内可安全修改属性
+func (a *Attrs) Range(fn func(key string, value interface{}) bool) {
+	a.rw.RLock()
+	values := make(map[string]interface{}, len(a.values))
+	for key, value := range a.values {
+		values[key] = value
+	}
+	a.rw.RUnlock()
+
+	for key, value := range values {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// Reset 清空所有属性
+func (a *Attrs) Reset() {
+	a.rw.Lock()
+	defer a.rw.Unlock()
+
+	a.values = nil
+}
@@ -0,0 +1,65 @@
+package network_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"sync"
+	"testing"
+)
+
+func TestAttrs(t *testing.T) {
+	var attrs network.Attrs
+
+	if _, ok := attrs.Get("region"); ok {
+		t.Fatal("empty attrs should not contain region")
+	}
+
+	attrs.Set("region", "cn")
+	attrs.Set("device", "ios")
+
+	if v, ok := attrs.Get("region"); !ok || v != "cn" {
+		t.Fatalf("region = %v, want cn", v)
+	}
+
+	attrs.Delete("device")
+
+	count := 0
+	attrs.Range(func(key string, value interface{}) bool {
+		// 遍历期间修改属性不会死锁
+		attrs.Set("room", 1)
+		count++
+		return true
+	})
+
+	if count != 1 {
+		t.Fatalf("range visited %d attrs, want 1", count)
+	}
+
+	attrs.Reset()
+
+	attrs.Range(func(key string, value interface{}) bool {
+		t.Fatalf("unexpected attr %s after reset", key)
+		return true
+	})
+}
+
+func TestAttrs_Concurrent(t *testing.T) {
+	var (
+		attrs network.Attrs
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				attrs.Set("key", j)
+				attrs.Get("key")
+				attrs.Range(func(key string, value interface{}) bool { return true })
+				attrs.Delete("key")
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,32 @@
+package network
+
+import (
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"net"
+)
+
+// NetBuffers 将Buffer的各节点转换为net.Buffers，用于向量化写入，不拷贝数据
+func NetBuffers(buf buffer.Buffer) net.Buffers {
+	bufs := make(net.Buffers, 0, 4)
+
+	buf.Range(func(node *buffer.NocopyNode) bool {
+		if b := node.Bytes(); len(b) > 0 {
+			bufs = append(bufs, b)
+		}
+		return true
+	})
+
+	return bufs
+}
+
+// CopyBuffer 将Buffer拷贝为独立的字节切片，拷贝后即可安全释放Buffer
+func CopyBuffer(buf buffer.Buffer) []byte {
+	data := make([]byte, 0, buf.Len())
+
+	buf.Range(func(node *buffer.NocopyNode) bool {
+		data = append(data, node.Bytes()...)
+		return true
+	})
+
+	return data
+}
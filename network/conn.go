@@ -1,6 +1,9 @@
 package network
 
 import (
+	"crypto/x509"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
 	"net"
 )
 
@@ -24,8 +27,12 @@ type (
 		Unbind()
 		// Send 发送消息（同步）
 		Send(msg []byte) error
-		// Push 发送消息（异步）
+		// Push 发送消息（异步），写入队列已满时按溢出策略处理
 		Push(msg []byte) error
+		// SendBuffer 发送消息（同步），无需拷贝Buffer，写入完成后释放Buffer
+		SendBuffer(buf buffer.Buffer) error
+		// PushBuffer 发送消息（异步），无需拷贝Buffer，写入完成后释放Buffer
+		PushBuffer(buf buffer.Buffer) error
 		// State 获取连接状态
 		State() ConnState
 		// Close 关闭连接
@@ -38,5 +45,35 @@ type (
 		RemoteIP() (string, error)
 		// RemoteAddr 获取远端地址
 		RemoteAddr() (net.Addr, error)
+		// PeerCertificate 获取对端TLS证书，未启用TLS或对端未提供证书时返回nil
+		PeerCertificate() (*x509.Certificate, error)
+		// Get 获取属性
+		Get(key string) (interface{}, bool)
+		// Set 设置属性
+		Set(key string, value interface{})
+		// Delete 删除属性
+		Delete(key string)
+		// Range 遍历属性，fn返回false时停止遍历
+		Range(fn func(key string, value interface{}) bool)
 	}
 )
+
+// IDPusher 支持按连接ID推送消息的连接
+// 池化的连接关闭后会以新的连接ID复用，在连接内部校验连接ID可避免校验后连接被复用
+type IDPusher interface {
+	// PushID 发送消息（异步），连接ID不为cid时返回errs.ErrConnectionClosed
+	PushID(cid int64, msg []byte) error
+}
+
+// PushID 发送消息给连接ID为cid的连接，连接已被复用为其他连接ID时返回errs.ErrConnectionClosed
+func PushID(conn Conn, cid int64, msg []byte) error {
+	if p, ok := conn.(IDPusher); ok {
+		return p.PushID(cid, msg)
+	}
+
+	if conn.ID() != cid {
+		return errs.ErrConnectionClosed
+	}
+
+	return conn.Push(msg)
+}
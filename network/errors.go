@@ -0,0 +1,15 @@
+package network
+
+import (
+	"strings"
+)
+
+// ConfigError 服务器配置校验错误，列出所有不合法的配置项
+type ConfigError struct {
+	Protocol string   // 协议名称
+	Problems []string // 不合法的配置项
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid " + e.Protocol + " server config: " + strings.Join(e.Problems, "; ")
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+)
+
+type (
+	// Invoker 拦截器链的下一个处理环节
+	Invoker func(frame *Frame) error
+
+	// Interceptor 消息拦截器，调用next继续处理，不调用next则中断处理
+	// 入站拦截器环绕ReceiveHandler，出站拦截器环绕Conn.Send与Conn.Push
+	Interceptor func(frame *Frame, next Invoker) error
+)
+
+// Frame 拦截器处理的消息帧
+type Frame struct {
+	conn    Conn            // 连接
+	data    []byte          // 原始帧
+	packer  ipacket.Packer  // 打包器
+	message ipacket.Message // 解包后的消息，按需解包
+}
+
+// NewFrame 创建消息帧
+func NewFrame(conn Conn, data []byte, packer ipacket.Packer) *Frame {
+	return &Frame{conn: conn, data: data, packer: packer}
+}
+
+// Conn 获取连接
+func (f *Frame) Conn() Conn {
+	return f.conn
+}
+
+// Data 获取原始帧
+func (f *Frame) Data() []byte {
+	return f.data
+}
+
+// SetData 改写原始帧
+func (f *Frame) SetData(data []byte) {
+	f.data = data
+	f.message = nil
+}
+
+// Message 获取解包后的消息，未配置打包器时返回errs.ErrNoPacker
+func (f *Frame) Message() (ipacket.Message, error) {
+	if f.message != nil {
+		return f.message, nil
+	}
+
+	if f.packer == nil {
+		return nil, errs.ErrNoPacker
+	}
+
+	message, err := f.packer.UnpackMessage(f.data)
+	if err != nil {
+		return nil, err
+	}
+
+	f.message = message
+
+	return message, nil
+}
+
+// SetMessage 改写消息，使用打包器重新打包原始帧
+func (f *Frame) SetMessage(message ipacket.Message) error {
+	if f.packer == nil {
+		return errs.ErrNoPacker
+	}
+
+	data, err := f.packer.PackMessage(message)
+	if err != nil {
+		return err
+	}
+
+	f.data = data
+	f.message = message
+
+	return nil
+}
+
+// Intercept 依次执行拦截器，全部放行后执行final
+// 每个拦截器的next固定指向其后的拦截器，多次调用next时会重新执行后续的拦截器链
+func Intercept(interceptors []Interceptor, frame *Frame, final Invoker) error {
+	if len(interceptors) == 0 {
+		return final(frame)
+	}
+
+	return interceptors[0](frame, func(frame *Frame) error {
+		return Intercept(interceptors[1:], frame, final)
+	})
+}
@@ -0,0 +1,92 @@
+package network_test
+
+import (
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"reflect"
+	"testing"
+)
+
+func TestIntercept(t *testing.T) {
+	var trace []string
+
+	record := func(name string) network.Interceptor {
+		return func(frame *network.Frame, next network.Invoker) error {
+			trace = append(trace, name+">")
+			err := next(frame)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+
+	err := network.Intercept([]network.Interceptor{record("a"), record("b")}, network.NewFrame(nil, []byte("x"), nil), func(frame *network.Frame) error {
+		trace = append(trace, "final:"+string(frame.Data()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a>", "b>", "final:x", "<b", "<a"}; !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestIntercept_Retry(t *testing.T) {
+	var trace []string
+
+	// 首次处理失败后重试一次
+	retry := func(frame *network.Frame, next network.Invoker) error {
+		if err := next(frame); err == nil {
+			return nil
+		}
+		return next(frame)
+	}
+
+	record := func(frame *network.Frame, next network.Invoker) error {
+		trace = append(trace, "b")
+		return next(frame)
+	}
+
+	attempts := 0
+	err := network.Intercept([]network.Interceptor{retry, record}, network.NewFrame(nil, nil, nil), func(frame *network.Frame) error {
+		attempts++
+		trace = append(trace, "final")
+		if attempts == 1 {
+			return errors.New("retry")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"b", "final", "b", "final"}; !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestIntercept_ShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+
+	deny := func(frame *network.Frame, next network.Invoker) error {
+		return errDenied
+	}
+
+	err := network.Intercept([]network.Interceptor{deny}, network.NewFrame(nil, nil, nil), func(frame *network.Frame) error {
+		t.Fatal("final must not be invoked")
+		return nil
+	})
+	if !errors.Is(err, errDenied) {
+		t.Fatalf("err = %v, want %v", err, errDenied)
+	}
+}
+
+func TestFrame_NoPacker(t *testing.T) {
+	frame := network.NewFrame(nil, []byte("x"), nil)
+
+	if _, err := frame.Message(); !errors.Is(err, errs.ErrNoPacker) {
+		t.Fatalf("err = %v, want %v", err, errs.ErrNoPacker)
+	}
+}
@@ -0,0 +1,80 @@
+// Package writeq 连接写入队列的溢出处理，tcp、ws、kcp共用
+package writeq
+
+import (
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"time"
+)
+
+// drop_oldest策略下丢弃最旧消息腾出空间的最大尝试次数
+const dropOldestRetries = 16
+
+// Enqueue 写入队列，队列已满时按溢出策略处理，返回被丢弃的消息
+// isControl判断是否为控制包，控制包不可丢弃
+func Enqueue[T any](ch chan T, w T, policy network.OverflowPolicy, timeout time.Duration, isControl func(T) bool) (dropped []T, err error) {
+	if tryEnqueue(ch, w) {
+		return nil, nil
+	}
+
+	switch policy {
+	case network.OverflowBlock:
+		if timeout <= 0 {
+			ch <- w
+			return nil, nil
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case ch <- w:
+			return nil, nil
+		case <-timer.C:
+			return []T{w}, errs.ErrWriteQueueFull
+		}
+	case network.OverflowDropOldest:
+		var control []T // 出队的控制包，不可丢弃
+
+		for i := 0; i < dropOldestRetries; i++ {
+			select {
+			case r := <-ch:
+				if isControl(r) {
+					control = append(control, r)
+				} else {
+					dropped = append(dropped, r)
+				}
+			default:
+			}
+
+			// 控制包非阻塞放回队列，队列被并发写满时继续丢弃最旧的消息腾出空间
+			for len(control) > 0 && tryEnqueue(ch, control[0]) {
+				control = control[1:]
+			}
+
+			if len(control) == 0 && tryEnqueue(ch, w) {
+				return dropped, nil
+			}
+		}
+
+		// 多次尝试仍无法腾出空间时写入方已停滞，不再空转，丢弃最新消息
+		// 控制包入队后连接不再接受新消息，写入方消费队列后即可放回
+		for _, r := range control {
+			ch <- r
+		}
+
+		return append(dropped, w), errs.ErrWriteQueueFull
+	default:
+		return []T{w}, errs.ErrWriteQueueFull
+	}
+}
+
+// 非阻塞写入队列
+func tryEnqueue[T any](ch chan T, w T) bool {
+	select {
+	case ch <- w:
+		return true
+	default:
+		return false
+	}
+}
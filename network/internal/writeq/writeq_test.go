@@ -0,0 +1,73 @@
+package writeq_test
+
+import (
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/writeq"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	control bool
+	n       int
+}
+
+func isControl(e entry) bool { return e.control }
+
+func TestEnqueue_DropOldest(t *testing.T) {
+	ch := make(chan entry, 2)
+	ch <- entry{n: 1}
+	ch <- entry{n: 2}
+
+	dropped, err := writeq.Enqueue(ch, entry{n: 3}, network.OverflowDropOldest, 0, isControl)
+	if err != nil || len(dropped) != 1 || dropped[0].n != 1 {
+		t.Fatalf("dropped %v, %v, want oldest entry dropped", dropped, err)
+	}
+
+	if a, b := <-ch, <-ch; a.n != 2 || b.n != 3 {
+		t.Fatalf("queue = [%d %d], want [2 3]", a.n, b.n)
+	}
+}
+
+func TestEnqueue_DropOldestStalledWriter(t *testing.T) {
+	cases := map[string]func() chan entry{
+		// 无缓冲队列且写入方停滞
+		"Unbuffered": func() chan entry { return make(chan entry) },
+		// 队列中只有不可丢弃的控制包且写入方停滞
+		"ControlOnly": func() chan entry {
+			ch := make(chan entry, 2)
+			ch <- entry{control: true, n: 1}
+			ch <- entry{control: true, n: 2}
+			return ch
+		},
+	}
+
+	for name, queue := range cases {
+		t.Run(name, func(t *testing.T) {
+			ch := queue()
+			size := len(ch)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+
+				dropped, err := writeq.Enqueue(ch, entry{n: 3}, network.OverflowDropOldest, 0, isControl)
+				if !errors.Is(err, errs.ErrWriteQueueFull) || len(dropped) != 1 || dropped[0].n != 3 {
+					t.Errorf("dropped %v, %v, want newest entry dropped with queue full error", dropped, err)
+				}
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("enqueue did not return while the writer is stalled")
+			}
+
+			if len(ch) != size {
+				t.Fatalf("queue has %d entries, want control entries kept", len(ch))
+			}
+		})
+	}
+}
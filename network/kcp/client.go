@@ -0,0 +1,113 @@
+package kcp
+
+import (
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/network"
+	"sync/atomic"
+)
+
+type client struct {
+	opts              *clientOptions            // 配置
+	id                int64                     // 连接ID
+	connectHandler    network.ConnectHandler    // 连接打开hook函数
+	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
+	receiveHandler    network.ReceiveHandler    // 接收消息hook函数
+}
+
+var _ network.Client = &client{}
+
+func NewClient(opts ...ClientOption) network.Client {
+	o := defaultClientOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &client{opts: o}
+}
+
+// Dial 拨号连接
+func (c *client) Dial(addr ...string) (network.Conn, error) {
+	var address string
+	if len(addr) > 0 && addr[0] != "" {
+		address = addr[0]
+	} else {
+		address = c.opts.addr
+	}
+
+	conn, err := dial(address, &c.opts.kcp)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClientConn(c, atomic.AddInt64(&c.id, 1), conn), nil
+}
+
+// Protocol 协议
+func (c *client) Protocol() string {
+	return protocol
+}
+
+// OnConnect 监听连接打开
+func (c *client) OnConnect(handler network.ConnectHandler) {
+	c.connectHandler = handler
+}
+
+// OnDisconnect 监听连接关闭
+func (c *client) OnDisconnect(handler network.DisconnectHandler) {
+	c.disconnectHandler = handler
+}
+
+// OnReceive 监听接收到消息
+func (c *client) OnReceive(handler network.ReceiveHandler) {
+	c.receiveHandler = handler
+}
+
+// 处理接收到的消息，依次经过入站拦截器
+func (c *client) receive(conn network.Conn, msg []byte) {
+	if len(c.opts.inbound) == 0 {
+		if c.receiveHandler != nil {
+			c.receiveHandler(conn, msg)
+		}
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	if err := network.Intercept(c.opts.inbound, frame, c.invokeReceive); err != nil {
+		c.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (c *client) invokeReceive(frame *network.Frame) error {
+	if c.receiveHandler != nil {
+		c.receiveHandler(frame.Conn(), frame.Data())
+	}
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (c *client) send(conn network.Conn, msg []byte, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	return network.Intercept(c.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (c *client) sendBuffer(conn network.Conn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return c.send(conn, msg, write)
+}
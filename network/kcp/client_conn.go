@@ -0,0 +1,422 @@
+package kcp
+
+import (
+	"crypto/x509"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type clientConn struct {
+	rw                sync.RWMutex
+	id                int64         // 连接ID
+	uid               int64         // 用户ID
+	conn              net.Conn      // KCP会话
+	state             int32         // 连接状态
+	client            *client       // 客户端
+	chWrite           chan chWrite  // 写入队列
+	done              chan struct{} // 写入完成信号
+	close             chan struct{} // 关闭信号
+	lastHeartbeatTime int64         // 上次心跳时间
+	network.Attrs                   // 连接属性
+}
+
+var _ network.Conn = &clientConn{}
+
+func newClientConn(client *client, id int64, conn net.Conn) network.Conn {
+	c := &clientConn{
+		id:                id,
+		conn:              conn,
+		state:             int32(network.ConnOpened),
+		client:            client,
+		chWrite:           make(chan chWrite, 4096),
+		done:              make(chan struct{}),
+		close:             make(chan struct{}),
+		lastHeartbeatTime: time.Now().UnixNano(),
+	}
+
+	icall.Go(c.read)
+
+	icall.Go(c.write)
+
+	if c.client.connectHandler != nil {
+		c.client.connectHandler(c)
+	}
+
+	return c
+}
+
+// ID 获取连接ID
+func (c *clientConn) ID() int64 {
+	return c.id
+}
+
+// UID 获取用户ID
+func (c *clientConn) UID() int64 {
+	return atomic.LoadInt64(&c.uid)
+}
+
+// Bind 绑定用户ID
+func (c *clientConn) Bind(uid int64) {
+	atomic.StoreInt64(&c.uid, uid)
+}
+
+// Unbind 解绑用户ID
+func (c *clientConn) Unbind() {
+	atomic.StoreInt64(&c.uid, 0)
+}
+
+// Send 发送消息（同步）
+func (c *clientConn) Send(msg []byte) error {
+	return c.client.send(c, msg, c.send)
+}
+
+// 发送消息（同步）
+func (c *clientConn) send(msg []byte) error {
+	return c.syncWrite(chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（同步）
+func (c *clientConn) sendBuffer(buf buffer.Buffer) error {
+	return c.syncWrite(chWrite{typ: dataPacket, buf: buf})
+}
+
+// Push 发送消息（异步）
+func (c *clientConn) Push(msg []byte) error {
+	return c.client.send(c, msg, c.push)
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *clientConn) SendBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *clientConn) PushBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *clientConn) push(msg []byte) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（异步）
+func (c *clientConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, buf: buf})
+}
+
+// 同步写入连接，写入完成后释放缓冲区
+func (c *clientConn) syncWrite(w chWrite) error {
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+
+	_, err := w.writeTo(conn)
+	return err
+}
+
+// 异步写入队列
+func (c *clientConn) asyncWrite(ch chan chWrite, w chWrite) error {
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
+	}
+
+	c.rw.RLock()
+	ch <- w
+	c.rw.RUnlock()
+
+	return nil
+}
+
+// State 获取连接状态
+func (c *clientConn) State() network.ConnState {
+	return network.ConnState(atomic.LoadInt32(&c.state))
+}
+
+// Close 关闭连接
+func (c *clientConn) Close(force ...bool) error {
+	if len(force) > 0 && force[0] {
+		return c.forceClose()
+	} else {
+		return c.graceClose()
+	}
+}
+
+// LocalIP 获取本地IP
+func (c *clientConn) LocalIP() (string, error) {
+	addr, err := c.LocalAddr()
+	if err != nil {
+		return "", err
+	}
+
+	return inet.ExtractIP(addr)
+}
+
+// LocalAddr 获取本地地址
+func (c *clientConn) LocalAddr() (net.Addr, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return conn.LocalAddr(), nil
+}
+
+// RemoteIP 获取远端IP
+func (c *clientConn) RemoteIP() (string, error) {
+	addr, err := c.RemoteAddr()
+	if err != nil {
+		return "", err
+	}
+
+	return inet.ExtractIP(addr)
+}
+
+// RemoteAddr 获取远端地址
+func (c *clientConn) RemoteAddr() (net.Addr, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return conn.RemoteAddr(), nil
+}
+
+// PeerCertificate 获取对端TLS证书，kcp不支持TLS，始终返回nil
+func (c *clientConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// 检测连接状态
+func (c *clientConn) checkState() error {
+	switch network.ConnState(atomic.LoadInt32(&c.state)) {
+	case network.ConnHanged:
+		return errs.ErrConnectionHanged
+	case network.ConnClosed:
+		return errs.ErrConnectionClosed
+	default:
+		return nil
+	}
+}
+
+// 优雅关闭
+func (c *clientConn) graceClose() error {
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
+	}
+
+	c.rw.RLock()
+	c.chWrite <- chWrite{typ: closeSig}
+	c.rw.RUnlock()
+
+	<-c.done
+
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
+	}
+
+	c.rw.Lock()
+	close(c.chWrite)
+	close(c.close)
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.rw.Unlock()
+
+	err := conn.Close()
+
+	if c.client.disconnectHandler != nil {
+		c.client.disconnectHandler(c)
+	}
+
+	return err
+}
+
+// 强制关闭
+func (c *clientConn) forceClose() error {
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
+		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
+			return errs.ErrConnectionClosed
+		}
+	}
+
+	c.rw.Lock()
+	close(c.chWrite)
+	close(c.close)
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.rw.Unlock()
+
+	err := conn.Close()
+
+	if c.client.disconnectHandler != nil {
+		c.client.disconnectHandler(c)
+	}
+
+	return err
+}
+
+// 读取消息
+func (c *clientConn) read() {
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	for {
+		select {
+		case <-c.close:
+			return
+		default:
+			msg, err := c.client.opts.packer.ReadMessage(conn)
+			if err != nil {
+				_ = c.forceClose()
+				return
+			}
+
+			if c.client.opts.heartbeatInterval > 0 {
+				atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
+			}
+
+			switch c.State() {
+			case network.ConnHanged:
+				continue
+			case network.ConnClosed:
+				return
+			default:
+				// ignore
+			}
+
+			isHeartbeat, err := c.client.opts.packer.CheckHeartbeat(msg)
+			if err != nil {
+				c.log().Warn("check heartbeat message error", "error", err)
+				continue
+			}
+
+			// ignore heartbeat packet
+			if isHeartbeat {
+				continue
+			}
+
+			// ignore empty packet
+			if len(msg) == 0 {
+				continue
+			}
+
+			c.client.receive(c, msg)
+		}
+	}
+}
+
+// 写入消息
+func (c *clientConn) write() {
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	var ticker *time.Ticker
+
+	if c.client.opts.heartbeatInterval > 0 {
+		ticker = time.NewTicker(c.client.opts.heartbeatInterval)
+		defer ticker.Stop()
+	} else {
+		ticker = &time.Ticker{C: make(chan time.Time, 1)}
+	}
+
+	for {
+		select {
+		case r, ok := <-c.chWrite:
+			if !ok {
+				return
+			}
+
+			if r.typ == closeSig {
+				c.rw.RLock()
+				c.done <- struct{}{}
+				c.rw.RUnlock()
+				return
+			}
+
+			if c.isClosed() {
+				return
+			}
+
+			if _, err := r.writeTo(conn); err != nil {
+				c.log().Warn("write data message error", "error", err)
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(-2 * c.client.opts.heartbeatInterval).UnixNano()
+			if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
+				c.log().Info("connection heartbeat timeout")
+				_ = c.forceClose()
+				return
+			} else {
+				if c.isClosed() {
+					return
+				}
+
+				if heartbeat, err := c.client.opts.packer.PackHeartbeat(); err != nil {
+					c.log().Error("pack heartbeat message error", "error", err)
+				} else {
+					// send heartbeat packet
+					if _, err := conn.Write(heartbeat); err != nil {
+						c.log().Warn("write heartbeat message error", "error", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 是否已关闭
+func (c *clientConn) isClosed() bool {
+	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
+}
+
+// 获取附带连接信息的日志器
+func (c *clientConn) log() logger.Logger {
+	l := c.client.opts.logger.With("cid", c.id, "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
@@ -0,0 +1,88 @@
+package kcp
+
+import (
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"time"
+)
+
+const (
+	defaultClientDialAddr          = "127.0.0.1:3553"
+	defaultClientHeartbeatInterval = time.Second * 10
+
+	defaultClientPackerName = "due"
+)
+
+type ClientOption func(o *clientOptions)
+
+type clientOptions struct {
+	addr              string        // 地址
+	heartbeatInterval time.Duration // 心跳间隔时间，默认10s
+	kcp               kcpOptions    // KCP配置，默认极速模式
+
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	packer   ipacket.Packer
+}
+
+func defaultClientOptions() *clientOptions {
+	return &clientOptions{
+		addr:              defaultClientDialAddr,
+		heartbeatInterval: defaultClientHeartbeatInterval,
+		kcp:               defaultKcpOptions(),
+		logger:            logger.Default(),
+		packer:            packet.GetDefaultPacker(defaultClientPackerName),
+	}
+}
+
+// WithClientDialAddr 设置拨号地址
+func WithClientDialAddr(addr string) ClientOption {
+	return func(o *clientOptions) { o.addr = addr }
+}
+
+// WithClientHeartbeatInterval 设置心跳间隔时间
+func WithClientHeartbeatInterval(heartbeatInterval time.Duration) ClientOption {
+	return func(o *clientOptions) { o.heartbeatInterval = heartbeatInterval }
+}
+
+func WithClientPacker(packer ipacket.Packer) ClientOption {
+	return func(o *clientOptions) {
+		o.packer = packer
+	}
+}
+
+// WithClientNoDelay 设置KCP工作模式
+// nodelay是否启用nodelay，interval内部刷新间隔（毫秒），resend快速重传阈值，nc是否关闭拥塞控制
+func WithClientNoDelay(nodelay, interval, resend, nc int) ClientOption {
+	return func(o *clientOptions) {
+		o.kcp.nodelay, o.kcp.interval, o.kcp.resend, o.kcp.nc = nodelay, interval, resend, nc
+	}
+}
+
+// WithClientWindowSize 设置KCP发送与接收窗口大小
+func WithClientWindowSize(sndWnd, rcvWnd int) ClientOption {
+	return func(o *clientOptions) { o.kcp.sndWnd, o.kcp.rcvWnd = sndWnd, rcvWnd }
+}
+
+// WithClientMTU 设置KCP最大传输单元
+func WithClientMTU(mtu int) ClientOption {
+	return func(o *clientOptions) { o.kcp.mtu = mtu }
+}
+
+// WithClientLogger 设置日志器
+func WithClientLogger(l logger.Logger) ClientOption {
+	return func(o *clientOptions) { o.logger = l }
+}
+
+// WithClientInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithClientInboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithClientOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithClientOutboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
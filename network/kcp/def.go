@@ -0,0 +1,91 @@
+package kcp
+
+import (
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/network"
+	"net"
+)
+
+const protocol = "kcp"
+
+const (
+	closeSig   int = iota // 关闭信号
+	dataPacket            // 数据包
+)
+
+type chWrite struct {
+	typ int
+	msg []byte
+	buf buffer.Buffer
+}
+
+// 消息字节数
+func (w chWrite) len() int {
+	if w.buf != nil {
+		return w.buf.Len()
+	}
+
+	return len(w.msg)
+}
+
+// 消息字节，buf释放后不可再使用
+func (w chWrite) bytes() []byte {
+	if w.buf != nil {
+		return w.buf.Bytes()
+	}
+
+	return w.msg
+}
+
+// 释放缓冲区
+func (w chWrite) release() {
+	if w.buf != nil {
+		w.buf.Release()
+	}
+}
+
+// 写入连接，写入完成后释放缓冲区
+func (w chWrite) writeTo(conn net.Conn) (int64, error) {
+	defer w.release()
+
+	if w.buf == nil {
+		n, err := conn.Write(w.msg)
+		return int64(n), err
+	}
+
+	bufs := network.NetBuffers(w.buf)
+
+	return bufs.WriteTo(conn)
+}
+
+const (
+	defaultNoDelay  = 1    // 默认启用nodelay
+	defaultInterval = 10   // 默认刷新间隔，单位毫秒
+	defaultResend   = 2    // 默认快速重传阈值
+	defaultNC       = 1    // 默认关闭拥塞控制
+	defaultSndWnd   = 128  // 默认发送窗口
+	defaultRcvWnd   = 128  // 默认接收窗口
+	defaultMTU      = 1400 // 默认MTU
+)
+
+type kcpOptions struct {
+	nodelay  int // 是否启用nodelay，0不启用，1启用
+	interval int // 内部刷新间隔，单位毫秒
+	resend   int // 快速重传阈值，0关闭快速重传
+	nc       int // 是否关闭拥塞控制，0不关闭，1关闭
+	sndWnd   int // 发送窗口大小
+	rcvWnd   int // 接收窗口大小
+	mtu      int // 最大传输单元
+}
+
+func defaultKcpOptions() kcpOptions {
+	return kcpOptions{
+		nodelay:  defaultNoDelay,
+		interval: defaultInterval,
+		resend:   defaultResend,
+		nc:       defaultNC,
+		sndWnd:   defaultSndWnd,
+		rcvWnd:   defaultRcvWnd,
+		mtu:      defaultMTU,
+	}
+}
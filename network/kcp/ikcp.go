@@ -0,0 +1,761 @@
+// 本文件移植自 https://github.com/skywind3000/kcp ，原项目使用MIT许可证：
+//
+// MIT License
+//
+// Copyright (c) 2017 Lin Wei
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package kcp
+
+import (
+	"encoding/binary"
+)
+
+// KCP ARQ协议实现，移植自 https://github.com/skywind3000/kcp
+
+const (
+	ikcpRtoNdl     = 30     // nodelay模式下的最小RTO
+	ikcpRtoMin     = 100    // 正常模式下的最小RTO
+	ikcpRtoDef     = 200    // 默认RTO
+	ikcpRtoMax     = 60000  // 最大RTO
+	ikcpCmdPush    = 81     // 数据
+	ikcpCmdAck     = 82     // 确认
+	ikcpCmdWask    = 83     // 询问窗口大小
+	ikcpCmdWins    = 84     // 告知窗口大小
+	ikcpAskSend    = 1      // 需要发送窗口询问
+	ikcpAskTell    = 2      // 需要发送窗口告知
+	ikcpWndSnd     = 32     // 默认发送窗口
+	ikcpWndRcv     = 128    // 默认接收窗口
+	ikcpMtuDef     = 1400   // 默认MTU
+	ikcpInterval   = 100    // 默认刷新间隔
+	ikcpOverhead   = 24     // 报文头长度
+	ikcpDeadLink   = 20     // 最大重传次数
+	ikcpThreshInit = 2      // 初始慢启动阈值
+	ikcpThreshMin  = 2      // 最小慢启动阈值
+	ikcpProbeInit  = 7000   // 初始窗口探测间隔
+	ikcpProbeLimit = 120000 // 最大窗口探测间隔
+	ikcpFastLimit  = 5      // 最大快速重传次数
+	ikcpDeadState  = 0xFFFFFFFF
+)
+
+type segment struct {
+	conv     uint32
+	cmd      uint8
+	frg      uint8
+	wnd      uint16
+	ts       uint32
+	sn       uint32
+	una      uint32
+	rto      uint32
+	xmit     uint32
+	resendts uint32
+	fastack  uint32
+	data     []byte
+}
+
+// 编码报文头
+func (seg *segment) encode(ptr []byte) []byte {
+	binary.LittleEndian.PutUint32(ptr, seg.conv)
+	ptr[4] = seg.cmd
+	ptr[5] = seg.frg
+	binary.LittleEndian.PutUint16(ptr[6:], seg.wnd)
+	binary.LittleEndian.PutUint32(ptr[8:], seg.ts)
+	binary.LittleEndian.PutUint32(ptr[12:], seg.sn)
+	binary.LittleEndian.PutUint32(ptr[16:], seg.una)
+	binary.LittleEndian.PutUint32(ptr[20:], uint32(len(seg.data)))
+	return ptr[ikcpOverhead:]
+}
+
+type ackItem struct {
+	sn uint32
+	ts uint32
+}
+
+type ikcp struct {
+	conv, mtu, mss, state               uint32
+	sndUna, sndNxt, rcvNxt              uint32
+	ssthresh                            uint32
+	rxRttvar, rxSrtt                    int32
+	rxRto, rxMinrto                     uint32
+	sndWnd, rcvWnd, rmtWnd, cwnd, probe uint32
+	current, interval, tsFlush, xmit    uint32
+	nodelay, updated                    uint32
+	tsProbe, probeWait                  uint32
+	deadLink, incr                      uint32
+	fastresend                          int32
+	fastlimit                           int32
+	nocwnd, stream                      int32
+	sndQueue, rcvQueue, sndBuf, rcvBuf  []segment
+	acklist                             []ackItem
+	buffer                              []byte
+	output                              func(buf []byte)
+}
+
+func newIkcp(conv uint32, output func(buf []byte)) *ikcp {
+	kcp := &ikcp{
+		conv:      conv,
+		sndWnd:    ikcpWndSnd,
+		rcvWnd:    ikcpWndRcv,
+		rmtWnd:    ikcpWndRcv,
+		mtu:       ikcpMtuDef,
+		mss:       ikcpMtuDef - ikcpOverhead,
+		rxRto:     ikcpRtoDef,
+		rxMinrto:  ikcpRtoMin,
+		interval:  ikcpInterval,
+		tsFlush:   ikcpInterval,
+		ssthresh:  ikcpThreshInit,
+		fastlimit: ikcpFastLimit,
+		deadLink:  ikcpDeadLink,
+		output:    output,
+	}
+	kcp.buffer = make([]byte, (kcp.mtu+ikcpOverhead)*3)
+
+	return kcp
+}
+
+// PeekSize 下一个完整消息的长度
+func (kcp *ikcp) PeekSize() int {
+	if len(kcp.rcvQueue) == 0 {
+		return -1
+	}
+
+	seg := &kcp.rcvQueue[0]
+	if seg.frg == 0 {
+		return len(seg.data)
+	}
+
+	if len(kcp.rcvQueue) < int(seg.frg+1) {
+		return -1
+	}
+
+	length := 0
+	for k := range kcp.rcvQueue {
+		seg := &kcp.rcvQueue[k]
+		length += len(seg.data)
+		if seg.frg == 0 {
+			break
+		}
+	}
+
+	return length
+}
+
+// Recv 读取一个完整消息
+func (kcp *ikcp) Recv(buffer []byte) int {
+	peeksize := kcp.PeekSize()
+	if peeksize < 0 {
+		return -1
+	}
+
+	if peeksize > len(buffer) {
+		return -2
+	}
+
+	fastRecover := len(kcp.rcvQueue) >= int(kcp.rcvWnd)
+
+	n, count := 0, 0
+	for k := range kcp.rcvQueue {
+		seg := &kcp.rcvQueue[k]
+		copy(buffer[n:], seg.data)
+		n += len(seg.data)
+		count++
+		if seg.frg == 0 {
+			break
+		}
+	}
+	kcp.rcvQueue = removeFront(kcp.rcvQueue, count)
+
+	kcp.moveRcvBuf()
+
+	if len(kcp.rcvQueue) < int(kcp.rcvWnd) && fastRecover {
+		kcp.probe |= ikcpAskTell
+	}
+
+	return n
+}
+
+// Send 发送数据，数据被切分为不超过mss的分片
+func (kcp *ikcp) Send(buffer []byte) int {
+	if kcp.stream != 0 && len(kcp.sndQueue) > 0 {
+		seg := &kcp.sndQueue[len(kcp.sndQueue)-1]
+		if len(seg.data) < int(kcp.mss) {
+			extend := int(kcp.mss) - len(seg.data)
+			if extend > len(buffer) {
+				extend = len(buffer)
+			}
+			seg.data = append(seg.data, buffer[:extend]...)
+			seg.frg = 0
+			buffer = buffer[extend:]
+		}
+
+		if len(buffer) == 0 {
+			return 0
+		}
+	}
+
+	count := (len(buffer) + int(kcp.mss) - 1) / int(kcp.mss)
+	if count > 255 {
+		return -2
+	}
+
+	if count == 0 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		size := len(buffer)
+		if size > int(kcp.mss) {
+			size = int(kcp.mss)
+		}
+
+		seg := segment{data: append([]byte(nil), buffer[:size]...)}
+		if kcp.stream == 0 {
+			seg.frg = uint8(count - i - 1)
+		}
+
+		kcp.sndQueue = append(kcp.sndQueue, seg)
+		buffer = buffer[size:]
+	}
+
+	return 0
+}
+
+// 更新RTT
+func (kcp *ikcp) updateAck(rtt int32) {
+	if kcp.rxSrtt == 0 {
+		kcp.rxSrtt = rtt
+		kcp.rxRttvar = rtt >> 1
+	} else {
+		delta := rtt - kcp.rxSrtt
+		if delta < 0 {
+			delta = -delta
+		}
+		kcp.rxRttvar = (3*kcp.rxRttvar + delta) / 4
+		kcp.rxSrtt = (7*kcp.rxSrtt + rtt) / 8
+		if kcp.rxSrtt < 1 {
+			kcp.rxSrtt = 1
+		}
+	}
+
+	rto := uint32(kcp.rxSrtt) + imax(kcp.interval, uint32(kcp.rxRttvar)<<2)
+	kcp.rxRto = ibound(kcp.rxMinrto, rto, ikcpRtoMax)
+}
+
+func (kcp *ikcp) shrinkBuf() {
+	if len(kcp.sndBuf) > 0 {
+		kcp.sndUna = kcp.sndBuf[0].sn
+	} else {
+		kcp.sndUna = kcp.sndNxt
+	}
+}
+
+func (kcp *ikcp) parseAck(sn uint32) {
+	if timediff(sn, kcp.sndUna) < 0 || timediff(sn, kcp.sndNxt) >= 0 {
+		return
+	}
+
+	for k := range kcp.sndBuf {
+		seg := &kcp.sndBuf[k]
+		if sn == seg.sn {
+			kcp.sndBuf = append(kcp.sndBuf[:k], kcp.sndBuf[k+1:]...)
+			break
+		}
+		if timediff(sn, seg.sn) < 0 {
+			break
+		}
+	}
+}
+
+func (kcp *ikcp) parseFastack(sn uint32) {
+	if timediff(sn, kcp.sndUna) < 0 || timediff(sn, kcp.sndNxt) >= 0 {
+		return
+	}
+
+	for k := range kcp.sndBuf {
+		seg := &kcp.sndBuf[k]
+		if timediff(sn, seg.sn) < 0 {
+			break
+		} else if sn != seg.sn {
+			seg.fastack++
+		}
+	}
+}
+
+func (kcp *ikcp) parseUna(una uint32) {
+	count := 0
+	for k := range kcp.sndBuf {
+		if timediff(una, kcp.sndBuf[k].sn) > 0 {
+			count++
+		} else {
+			break
+		}
+	}
+	kcp.sndBuf = removeFront(kcp.sndBuf, count)
+}
+
+func (kcp *ikcp) parseData(newseg segment) {
+	sn := newseg.sn
+	if timediff(sn, kcp.rcvNxt+kcp.rcvWnd) >= 0 || timediff(sn, kcp.rcvNxt) < 0 {
+		return
+	}
+
+	insertIdx, repeat := 0, false
+	for i := len(kcp.rcvBuf) - 1; i >= 0; i-- {
+		seg := &kcp.rcvBuf[i]
+		if seg.sn == sn {
+			repeat = true
+			break
+		}
+		if timediff(sn, seg.sn) > 0 {
+			insertIdx = i + 1
+			break
+		}
+	}
+
+	if !repeat {
+		newseg.data = append([]byte(nil), newseg.data...)
+		kcp.rcvBuf = append(kcp.rcvBuf, segment{})
+		copy(kcp.rcvBuf[insertIdx+1:], kcp.rcvBuf[insertIdx:])
+		kcp.rcvBuf[insertIdx] = newseg
+	}
+
+	kcp.moveRcvBuf()
+}
+
+// 将连续的数据从rcvBuf移动到rcvQueue
+func (kcp *ikcp) moveRcvBuf() {
+	count := 0
+	for k := range kcp.rcvBuf {
+		seg := &kcp.rcvBuf[k]
+		if seg.sn == kcp.rcvNxt && len(kcp.rcvQueue)+count < int(kcp.rcvWnd) {
+			kcp.rcvNxt++
+			count++
+		} else {
+			break
+		}
+	}
+
+	if count > 0 {
+		kcp.rcvQueue = append(kcp.rcvQueue, kcp.rcvBuf[:count]...)
+		kcp.rcvBuf = removeFront(kcp.rcvBuf, count)
+	}
+}
+
+// Input 输入底层收到的报文
+func (kcp *ikcp) Input(data []byte) int {
+	prevUna := kcp.sndUna
+
+	if len(data) < ikcpOverhead {
+		return -1
+	}
+
+	var (
+		maxack uint32
+		flag   bool
+	)
+
+	for len(data) >= ikcpOverhead {
+		conv := binary.LittleEndian.Uint32(data)
+		cmd := data[4]
+		frg := data[5]
+		wnd := binary.LittleEndian.Uint16(data[6:])
+		ts := binary.LittleEndian.Uint32(data[8:])
+		sn := binary.LittleEndian.Uint32(data[12:])
+		una := binary.LittleEndian.Uint32(data[16:])
+		length := binary.LittleEndian.Uint32(data[20:])
+		data = data[ikcpOverhead:]
+
+		if conv != kcp.conv {
+			return -1
+		}
+
+		if uint32(len(data)) < length {
+			return -2
+		}
+
+		if cmd != ikcpCmdPush && cmd != ikcpCmdAck && cmd != ikcpCmdWask && cmd != ikcpCmdWins {
+			return -3
+		}
+
+		kcp.rmtWnd = uint32(wnd)
+		kcp.parseUna(una)
+		kcp.shrinkBuf()
+
+		switch cmd {
+		case ikcpCmdAck:
+			if timediff(kcp.current, ts) >= 0 {
+				kcp.updateAck(timediff(kcp.current, ts))
+			}
+			kcp.parseAck(sn)
+			kcp.shrinkBuf()
+			if !flag {
+				flag = true
+				maxack = sn
+			} else if timediff(sn, maxack) > 0 {
+				maxack = sn
+			}
+		case ikcpCmdPush:
+			if timediff(sn, kcp.rcvNxt+kcp.rcvWnd) < 0 {
+				kcp.acklist = append(kcp.acklist, ackItem{sn: sn, ts: ts})
+				if timediff(sn, kcp.rcvNxt) >= 0 {
+					kcp.parseData(segment{
+						conv: conv,
+						cmd:  cmd,
+						frg:  frg,
+						wnd:  wnd,
+						ts:   ts,
+						sn:   sn,
+						una:  una,
+						data: data[:length],
+					})
+				}
+			}
+		case ikcpCmdWask:
+			kcp.probe |= ikcpAskTell
+		case ikcpCmdWins:
+			// do nothing
+		}
+
+		data = data[length:]
+	}
+
+	if flag {
+		kcp.parseFastack(maxack)
+	}
+
+	if timediff(kcp.sndUna, prevUna) > 0 && kcp.cwnd < kcp.rmtWnd {
+		mss := kcp.mss
+		if kcp.cwnd < kcp.ssthresh {
+			kcp.cwnd++
+			kcp.incr += mss
+		} else {
+			if kcp.incr < mss {
+				kcp.incr = mss
+			}
+			kcp.incr += (mss*mss)/kcp.incr + (mss / 16)
+			if (kcp.cwnd+1)*mss <= kcp.incr {
+				kcp.cwnd++
+			}
+		}
+		if kcp.cwnd > kcp.rmtWnd {
+			kcp.cwnd = kcp.rmtWnd
+			kcp.incr = kcp.rmtWnd * mss
+		}
+	}
+
+	return 0
+}
+
+func (kcp *ikcp) wndUnused() uint16 {
+	if len(kcp.rcvQueue) < int(kcp.rcvWnd) {
+		return uint16(int(kcp.rcvWnd) - len(kcp.rcvQueue))
+	}
+	return 0
+}
+
+// flush 发送确认、窗口探测与数据
+func (kcp *ikcp) flush() {
+	var (
+		current = kcp.current
+		buffer  = kcp.buffer
+		size    = 0
+	)
+
+	output := func(need int) {
+		if size+need > int(kcp.mtu) {
+			kcp.output(buffer[:size])
+			size = 0
+		}
+	}
+
+	seg := segment{
+		conv: kcp.conv,
+		cmd:  ikcpCmdAck,
+		wnd:  kcp.wndUnused(),
+		una:  kcp.rcvNxt,
+	}
+
+	for _, ack := range kcp.acklist {
+		output(ikcpOverhead)
+		seg.sn, seg.ts = ack.sn, ack.ts
+		seg.encode(buffer[size:])
+		size += ikcpOverhead
+	}
+	kcp.acklist = kcp.acklist[:0]
+
+	if kcp.rmtWnd == 0 {
+		if kcp.probeWait == 0 {
+			kcp.probeWait = ikcpProbeInit
+			kcp.tsProbe = current + kcp.probeWait
+		} else if timediff(current, kcp.tsProbe) >= 0 {
+			if kcp.probeWait < ikcpProbeInit {
+				kcp.probeWait = ikcpProbeInit
+			}
+			kcp.probeWait += kcp.probeWait / 2
+			if kcp.probeWait > ikcpProbeLimit {
+				kcp.probeWait = ikcpProbeLimit
+			}
+			kcp.tsProbe = current + kcp.probeWait
+			kcp.probe |= ikcpAskSend
+		}
+	} else {
+		kcp.tsProbe = 0
+		kcp.probeWait = 0
+	}
+
+	if kcp.probe&ikcpAskSend != 0 {
+		seg.cmd = ikcpCmdWask
+		output(ikcpOverhead)
+		seg.encode(buffer[size:])
+		size += ikcpOverhead
+	}
+
+	if kcp.probe&ikcpAskTell != 0 {
+		seg.cmd = ikcpCmdWins
+		output(ikcpOverhead)
+		seg.encode(buffer[size:])
+		size += ikcpOverhead
+	}
+
+	kcp.probe = 0
+
+	cwnd := imin(kcp.sndWnd, kcp.rmtWnd)
+	if kcp.nocwnd == 0 {
+		cwnd = imin(kcp.cwnd, cwnd)
+	}
+
+	count := 0
+	for k := range kcp.sndQueue {
+		if timediff(kcp.sndNxt, kcp.sndUna+cwnd) >= 0 {
+			break
+		}
+
+		newseg := kcp.sndQueue[k]
+		newseg.conv = kcp.conv
+		newseg.cmd = ikcpCmdPush
+		newseg.sn = kcp.sndNxt
+		kcp.sndNxt++
+		kcp.sndBuf = append(kcp.sndBuf, newseg)
+		count++
+	}
+	kcp.sndQueue = removeFront(kcp.sndQueue, count)
+
+	resent := uint32(kcp.fastresend)
+	if kcp.fastresend <= 0 {
+		resent = 0xFFFFFFFF
+	}
+
+	var rtomin uint32
+	if kcp.nodelay == 0 {
+		rtomin = kcp.rxRto >> 3
+	}
+
+	change, lost := 0, false
+
+	for k := range kcp.sndBuf {
+		segment := &kcp.sndBuf[k]
+		needsend := false
+
+		if segment.xmit == 0 {
+			needsend = true
+			segment.rto = kcp.rxRto
+			segment.resendts = current + segment.rto + rtomin
+		} else if timediff(current, segment.resendts) >= 0 {
+			needsend = true
+			kcp.xmit++
+			if kcp.nodelay == 0 {
+				segment.rto += imax(segment.rto, kcp.rxRto)
+			} else {
+				segment.rto += segment.rto / 2
+			}
+			segment.resendts = current + segment.rto
+			lost = true
+		} else if segment.fastack >= resent {
+			if kcp.fastlimit <= 0 || segment.xmit <= uint32(kcp.fastlimit) {
+				needsend = true
+				segment.fastack = 0
+				segment.resendts = current + segment.rto
+				change++
+			}
+		}
+
+		if needsend {
+			segment.xmit++
+			segment.ts = current
+			segment.wnd = seg.wnd
+			segment.una = kcp.rcvNxt
+
+			need := ikcpOverhead + len(segment.data)
+			output(need)
+			ptr := segment.encode(buffer[size:])
+			copy(ptr, segment.data)
+			size += need
+
+			if segment.xmit >= kcp.deadLink {
+				kcp.state = ikcpDeadState
+			}
+		}
+	}
+
+	if size > 0 {
+		kcp.output(buffer[:size])
+	}
+
+	if change > 0 {
+		inflight := kcp.sndNxt - kcp.sndUna
+		kcp.ssthresh = inflight / 2
+		if kcp.ssthresh < ikcpThreshMin {
+			kcp.ssthresh = ikcpThreshMin
+		}
+		kcp.cwnd = kcp.ssthresh + resent
+		kcp.incr = kcp.cwnd * kcp.mss
+	}
+
+	if lost {
+		kcp.ssthresh = cwnd / 2
+		if kcp.ssthresh < ikcpThreshMin {
+			kcp.ssthresh = ikcpThreshMin
+		}
+		kcp.cwnd = 1
+		kcp.incr = kcp.mss
+	}
+
+	if kcp.cwnd < 1 {
+		kcp.cwnd = 1
+		kcp.incr = kcp.mss
+	}
+}
+
+// Update 按时钟驱动刷新，current为毫秒时间
+func (kcp *ikcp) Update(current uint32) {
+	kcp.current = current
+
+	if kcp.updated == 0 {
+		kcp.updated = 1
+		kcp.tsFlush = current
+	}
+
+	slap := timediff(current, kcp.tsFlush)
+	if slap >= 10000 || slap < -10000 {
+		kcp.tsFlush = current
+		slap = 0
+	}
+
+	if slap >= 0 {
+		kcp.tsFlush += kcp.interval
+		if timediff(current, kcp.tsFlush) >= 0 {
+			kcp.tsFlush = current + kcp.interval
+		}
+		kcp.flush()
+	}
+}
+
+// SetMtu 设置MTU
+func (kcp *ikcp) SetMtu(mtu int) bool {
+	if mtu < 50 || mtu < ikcpOverhead {
+		return false
+	}
+
+	kcp.buffer = make([]byte, (mtu+ikcpOverhead)*3)
+	kcp.mtu = uint32(mtu)
+	kcp.mss = kcp.mtu - ikcpOverhead
+
+	return true
+}
+
+// NoDelay 设置nodelay、刷新间隔、快速重传与拥塞控制，参数小于0时保持原值
+func (kcp *ikcp) NoDelay(nodelay, interval, resend, nc int) {
+	if nodelay >= 0 {
+		kcp.nodelay = uint32(nodelay)
+		if nodelay != 0 {
+			kcp.rxMinrto = ikcpRtoNdl
+		} else {
+			kcp.rxMinrto = ikcpRtoMin
+		}
+	}
+
+	if interval >= 0 {
+		if interval > 5000 {
+			interval = 5000
+		} else if interval < 10 {
+			interval = 10
+		}
+		kcp.interval = uint32(interval)
+	}
+
+	if resend >= 0 {
+		kcp.fastresend = int32(resend)
+	}
+
+	if nc >= 0 {
+		kcp.nocwnd = int32(nc)
+	}
+}
+
+// WndSize 设置发送与接收窗口
+func (kcp *ikcp) WndSize(sndwnd, rcvwnd int) {
+	if sndwnd > 0 {
+		kcp.sndWnd = uint32(sndwnd)
+	}
+
+	if rcvwnd > 0 {
+		kcp.rcvWnd = imax(uint32(rcvwnd), ikcpWndRcv)
+	}
+}
+
+// WaitSnd 等待发送的分片数
+func (kcp *ikcp) WaitSnd() int {
+	return len(kcp.sndBuf) + len(kcp.sndQueue)
+}
+
+func removeFront(q []segment, n int) []segment {
+	if n == 0 {
+		return q
+	}
+
+	newn := copy(q, q[n:])
+	for i := newn; i < len(q); i++ {
+		q[i] = segment{}
+	}
+
+	return q[:newn]
+}
+
+func timediff(later, earlier uint32) int32 {
+	return int32(later - earlier)
+}
+
+func imin(a, b uint32) uint32 {
+	if a <= b {
+		return a
+	}
+	return b
+}
+
+func imax(a, b uint32) uint32 {
+	if a >= b {
+		return a
+	}
+	return b
+}
+
+func ibound(lower, middle, upper uint32) uint32 {
+	return imin(imax(lower, middle), upper)
+}
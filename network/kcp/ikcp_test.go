@@ -0,0 +1,67 @@
+package kcp
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestIkcp_LossyLink(t *testing.T) {
+	var (
+		rnd    = rand.New(rand.NewSource(1))
+		queueA [][]byte
+		queueB [][]byte
+	)
+
+	// 模拟30%丢包的链路
+	lossy := func(queue *[][]byte) func(buf []byte) {
+		return func(buf []byte) {
+			if rnd.Intn(100) < 30 {
+				return
+			}
+			*queue = append(*queue, append([]byte(nil), buf...))
+		}
+	}
+
+	a := newIkcp(1, lossy(&queueB))
+	b := newIkcp(1, lossy(&queueA))
+	a.NoDelay(1, 10, 2, 1)
+	b.NoDelay(1, 10, 2, 1)
+
+	var sent, recv []byte
+	for i := 0; i < 100; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 1+i*37)
+		sent = append(sent, data...)
+		if a.Send(data) != 0 {
+			t.Fatal("send failed")
+		}
+	}
+
+	buf := make([]byte, 8192)
+	for current := uint32(0); current < 60000 && len(recv) < len(sent); current += 10 {
+		a.Update(current)
+		b.Update(current)
+
+		for _, pkt := range queueB {
+			b.Input(pkt)
+		}
+		queueB = queueB[:0]
+
+		for _, pkt := range queueA {
+			a.Input(pkt)
+		}
+		queueA = queueA[:0]
+
+		for {
+			n := b.Recv(buf)
+			if n < 0 {
+				break
+			}
+			recv = append(recv, buf[:n]...)
+		}
+	}
+
+	if !bytes.Equal(sent, recv) {
+		t.Fatalf("received %d bytes, want %d", len(recv), len(sent))
+	}
+}
@@ -0,0 +1,176 @@
+package kcp
+
+import (
+	"encoding/binary"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"net"
+	"sync"
+)
+
+const defaultAcceptBacklog = 128
+
+// listener KCP监听器，按远端地址将UDP报文分发到会话，实现net.Listener
+type listener struct {
+	conn     net.PacketConn      // UDP连接
+	opts     *kcpOptions         // KCP配置
+	rw       sync.RWMutex        // 读写锁
+	sessions map[string]*session // 远端地址 -> 会话
+	chAccept chan *session       // 待接受的会话
+	die      chan struct{}       // 关闭信号
+	dieOnce  sync.Once
+}
+
+var _ net.Listener = &listener{}
+
+func listen(addr string, opts *kcpOptions) (*listener, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &listener{
+		conn:     conn,
+		opts:     opts,
+		sessions: make(map[string]*session),
+		chAccept: make(chan *session, defaultAcceptBacklog),
+		die:      make(chan struct{}),
+	}
+
+	icall.Go(l.monitor)
+
+	return l, nil
+}
+
+// Accept 接受会话
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case s := <-l.chAccept:
+		return s, nil
+	case <-l.die:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close 停止接受新会话，UDP连接在所有已建立的会话关闭后释放
+func (l *listener) Close() error {
+	closed := false
+
+	l.dieOnce.Do(func() {
+		close(l.die)
+		closed = true
+	})
+
+	if !closed {
+		return net.ErrClosed
+	}
+
+	l.rw.RLock()
+	empty := len(l.sessions) == 0
+	l.rw.RUnlock()
+
+	if empty {
+		return l.conn.Close()
+	}
+
+	return nil
+}
+
+// Addr 监听地址
+func (l *listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// 读取UDP报文并分发
+func (l *listener) monitor() {
+	buf := make([]byte, udpBufferSize)
+
+	for {
+		n, from, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		if n < ikcpOverhead {
+			continue
+		}
+
+		data := buf[:n]
+		conv := binary.LittleEndian.Uint32(data)
+
+		l.rw.RLock()
+		s, ok := l.sessions[from.String()]
+		l.rw.RUnlock()
+
+		if !ok || s.kcp.conv != conv {
+			// 未知地址或同一地址以新的会话ID重连时，仅接受握手报文创建会话
+			if !isHandshake(data) {
+				continue
+			}
+
+			if ok {
+				_ = s.close(false)
+			}
+
+			if s = l.accept(from, conv); s == nil {
+				continue
+			}
+		}
+
+		s.input(data)
+	}
+}
+
+// 是否为握手报文，即新会话的首个数据分片
+// 客户端会话首次发送数据时，首个分片的序号与确认序号均为0，用于过滤伪造或过期的报文
+func isHandshake(data []byte) bool {
+	conv := binary.LittleEndian.Uint32(data)
+	cmd := data[4]
+	sn := binary.LittleEndian.Uint32(data[12:])
+	una := binary.LittleEndian.Uint32(data[16:])
+	length := binary.LittleEndian.Uint32(data[20:])
+
+	return conv != 0 && cmd == ikcpCmdPush && sn == 0 && una == 0 && length <= uint32(len(data)-ikcpOverhead)
+}
+
+// 为新的远端地址创建会话
+func (l *listener) accept(from net.Addr, conv uint32) *session {
+	select {
+	case <-l.die:
+		return nil
+	default:
+	}
+
+	s := newSession(conv, l.conn, from, l, l.opts)
+
+	l.rw.Lock()
+	l.sessions[from.String()] = s
+	l.rw.Unlock()
+
+	select {
+	case l.chAccept <- s:
+		return s
+	default:
+		_ = s.close(false)
+		return nil
+	}
+}
+
+// 移除会话，远端地址已被新的会话替换时保留新的会话
+func (l *listener) remove(s *session) {
+	key := s.remote.String()
+
+	l.rw.Lock()
+	if l.sessions[key] == s {
+		delete(l.sessions, key)
+	}
+	empty := len(l.sessions) == 0
+	l.rw.Unlock()
+
+	select {
+	case <-l.die:
+		if empty {
+			_ = l.conn.Close()
+		}
+	default:
+	}
+}
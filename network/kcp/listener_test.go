@@ -0,0 +1,87 @@
+package kcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListener_Handshake(t *testing.T) {
+	opts := defaultKcpOptions()
+
+	l, err := listen("127.0.0.1:0", &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	conn, err := net.DialUDP("udp", nil, l.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(conv uint32, data []byte) {
+		kcp := newIkcp(conv, func(buf []byte) { _, _ = conn.Write(buf) })
+		kcp.Send(data)
+		kcp.current = currentMs()
+		// 拥塞窗口初始为0，首次刷新后才发送数据
+		kcp.flush()
+		kcp.flush()
+	}
+
+	accept := func() *session {
+		select {
+		case s := <-l.chAccept:
+			return s
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+
+	// 非握手报文不创建会话
+	garbage := make([]byte, ikcpOverhead*2)
+	for i := range garbage {
+		garbage[i] = 0xFF
+	}
+	if _, err = conn.Write(garbage); err != nil {
+		t.Fatal(err)
+	}
+
+	send(0, []byte("hello"))
+
+	select {
+	case <-l.chAccept:
+		t.Fatal("datagram without a valid handshake should not create a session")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	send(1, []byte("hello"))
+
+	s1 := accept()
+	if s1 == nil || s1.kcp.conv != 1 {
+		t.Fatal("handshake should create a session")
+	}
+
+	// 同一地址以新的会话ID重连时替换旧的会话
+	send(2, []byte("hello"))
+
+	s2 := accept()
+	if s2 == nil || s2.kcp.conv != 2 {
+		t.Fatal("reconnect with a new conv should create a new session")
+	}
+
+	if !s1.isClosed() {
+		t.Fatal("replaced session should be closed")
+	}
+
+	l.rw.RLock()
+	s, ok := l.sessions[conn.LocalAddr().String()]
+	l.rw.RUnlock()
+
+	if !ok || s != s2 {
+		t.Fatal("closing the replaced session should keep the new session")
+	}
+
+	_ = s2.close(false)
+}
@@ -0,0 +1,310 @@
+package kcp
+
+import (
+	"context"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
+	"net"
+	"time"
+)
+
+type server struct {
+	opts              *serverOptions            // 配置
+	listener          net.Listener              // 监听器
+	connMgr           *serverConnMgr            // 连接管理器
+	startHandler      network.StartHandler      // 服务器启动hook函数
+	stopHandler       network.CloseHandler      // 服务器关闭hook函数
+	connectHandler    network.ConnectHandler    // 连接打开hook函数
+	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
+	receiveHandler    network.ReceiveHandler    // 接收消息hook函数
+	labels            []string                  // 指标标签
+	unregister        []func()                  // 仪表盘注销函数
+	err               error                     // 配置错误，启动时返回
+}
+
+var _ network.Server = &server{}
+
+// NewServer 创建服务器，配置不合法时启动服务器返回*network.ConfigError
+func NewServer(opts ...ServerOption) network.Server {
+	o := defaultServerOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return newServer(o)
+}
+
+// NewServerE 创建服务器，配置不合法时返回*network.ConfigError
+func NewServerE(opts ...ServerOption) (network.Server, error) {
+	o := defaultServerOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := newServer(o)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s, nil
+}
+
+func newServer(o *serverOptions) *server {
+	s := &server{}
+	s.opts = o
+	s.err = o.validate()
+	s.labels = []string{"protocol", protocol, "addr", o.addr}
+	s.connMgr = newServerConnMgr(s)
+
+	return s
+}
+
+// Addr 监听地址
+func (s *server) Addr() string {
+	return s.opts.addr
+}
+
+// Start 启动服务器
+func (s *server) Start() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	s.register()
+
+	if s.startHandler != nil {
+		s.startHandler()
+	}
+
+	go s.serve()
+
+	return nil
+}
+
+// Stop 关闭服务器
+func (s *server) Stop() error {
+	if err := s.listener.Close(); err != nil {
+		return err
+	}
+
+	s.deregister()
+
+	s.connMgr.close()
+
+	if s.stopHandler != nil {
+		s.stopHandler()
+	}
+
+	return nil
+}
+
+// Shutdown 优雅关闭服务器
+func (s *server) Shutdown(ctx context.Context) error {
+	if err := s.listener.Close(); err != nil {
+		return err
+	}
+
+	s.deregister()
+
+	var goingAway []byte
+	if s.opts.goingAway != nil {
+		msg, err := s.opts.packer.PackMessage(s.opts.goingAway)
+		if err != nil {
+			s.opts.logger.Error("pack going away message error", "error", err)
+		} else {
+			goingAway = msg
+		}
+	}
+
+	err := s.connMgr.shutdown(ctx, goingAway)
+
+	if s.stopHandler != nil {
+		s.stopHandler()
+	}
+
+	return err
+}
+
+// Protocol 协议
+func (s *server) Protocol() string {
+	return protocol
+}
+
+// OnStart 监听服务器启动
+func (s *server) OnStart(handler network.StartHandler) {
+	s.startHandler = handler
+}
+
+// OnStop 监听服务器关闭
+func (s *server) OnStop(handler network.CloseHandler) {
+	s.stopHandler = handler
+}
+
+// OnConnect 监听连接打开
+func (s *server) OnConnect(handler network.ConnectHandler) {
+	s.connectHandler = handler
+}
+
+// OnDisconnect 监听连接关闭
+func (s *server) OnDisconnect(handler network.DisconnectHandler) {
+	s.disconnectHandler = handler
+}
+
+// OnReceive 监听接收到消息
+func (s *server) OnReceive(handler network.ReceiveHandler) {
+	s.receiveHandler = handler
+}
+
+// 初始化KCP服务器
+func (s *server) init() error {
+	ln, err := listen(s.opts.addr, &s.opts.kcp)
+	if err != nil {
+		return err
+	}
+
+	s.listener = ln
+
+	return nil
+}
+
+// 等待连接
+func (s *server) serve() {
+	var tempDelay time.Duration
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+
+				s.opts.logger.Warn("kcp accept error", "error", err, "retry", tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+
+			s.opts.logger.Warn("kcp accept error", "error", err)
+			return
+		}
+
+		tempDelay = 0
+
+		if err = s.connMgr.allocate(conn); err != nil {
+			s.opts.logger.Warn("connection allocate error", "error", err)
+			_ = conn.Close()
+		}
+	}
+}
+
+// 处理接收到的消息，依次经过入站拦截器
+func (s *server) receive(conn network.Conn, msg []byte) {
+	if len(s.opts.inbound) == 0 {
+		s.handle(conn, msg)
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, s.opts.packer)
+
+	if err := network.Intercept(s.opts.inbound, frame, s.invokeReceive); err != nil {
+		s.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (s *server) invokeReceive(frame *network.Frame) error {
+	s.handle(frame.Conn(), frame.Data())
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (s *server) send(conn network.Conn, msg []byte, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, s.opts.packer)
+
+	return network.Intercept(s.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 调用接收消息hook函数并统计耗时
+func (s *server) handle(conn network.Conn, msg []byte) {
+	if s.receiveHandler == nil {
+		return
+	}
+
+	start := time.Now()
+	s.receiveHandler(conn, msg)
+	s.opts.metrics.Observe(metrics.HandlerLatency, time.Since(start).Seconds(), s.labels...)
+}
+
+// 注册仪表盘
+func (s *server) register() {
+	s.unregister = append(s.unregister,
+		s.opts.metrics.Gauge(metrics.ConnectionsActive, s.connMgr.active, s.labels...),
+		s.opts.metrics.Gauge(metrics.WriteQueueDepth, s.connMgr.queued, s.labels...),
+	)
+}
+
+// 注销仪表盘
+func (s *server) deregister() {
+	for _, unregister := range s.unregister {
+		unregister()
+	}
+
+	s.unregister = nil
+}
+
+// 统计读取的帧
+func (s *server) countIn(n int) {
+	s.opts.metrics.Add(metrics.FramesIn, 1, s.labels...)
+	s.opts.metrics.Add(metrics.BytesIn, float64(n), s.labels...)
+}
+
+// 统计写入的帧
+func (s *server) countOut(n int) {
+	s.opts.metrics.Add(metrics.FramesOut, 1, s.labels...)
+	s.opts.metrics.Add(metrics.BytesOut, float64(n), s.labels...)
+}
+
+// 统计写入队列溢出并调用溢出hook函数
+func (s *server) overflow(conn network.Conn, dropped []chWrite) {
+	policy := string(s.opts.overflowPolicy)
+	labels := append(append(make([]string, 0, len(s.labels)+2), s.labels...), "policy", policy)
+
+	s.opts.metrics.Add(metrics.WriteQueueOverflows, float64(len(dropped)), labels...)
+
+	for _, w := range dropped {
+		if s.opts.overflowHandler != nil {
+			s.opts.overflowHandler(conn, w.bytes(), s.opts.overflowPolicy)
+		}
+
+		w.release()
+	}
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (s *server) sendBuffer(conn network.Conn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return s.send(conn, msg, write)
+}
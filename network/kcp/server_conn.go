@@ -0,0 +1,556 @@
+package kcp
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/writeq"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type serverConn struct {
+	id                int64          // 连接ID
+	uid               int64          // 用户ID
+	state             int32          // 连接状态
+	connMgr           *serverConnMgr // 连接管理
+	rw                sync.RWMutex   // 读写锁
+	conn              net.Conn       // KCP会话
+	chWrite           chan chWrite   // 写入队列
+	done              chan struct{}  // 写入完成信号
+	close             chan struct{}  // 关闭信号
+	lastHeartbeatTime int64          // 上次心跳时间
+	network.Attrs                    // 连接属性
+}
+
+var _ network.Conn = &serverConn{}
+
+// ID 获取连接ID
+func (c *serverConn) ID() int64 {
+	return atomic.LoadInt64(&c.id)
+}
+
+// UID 获取用户ID
+func (c *serverConn) UID() int64 {
+	return atomic.LoadInt64(&c.uid)
+}
+
+// Bind 绑定用户ID
+func (c *serverConn) Bind(uid int64) {
+	atomic.StoreInt64(&c.uid, uid)
+}
+
+// Unbind 解绑用户ID
+func (c *serverConn) Unbind() {
+	atomic.StoreInt64(&c.uid, 0)
+}
+
+// Send 发送消息（同步）
+func (c *serverConn) Send(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.send)
+}
+
+// 发送消息（同步）
+func (c *serverConn) send(msg []byte) error {
+	return c.syncWrite(chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（同步）
+func (c *serverConn) sendBuffer(buf buffer.Buffer) error {
+	return c.syncWrite(chWrite{typ: dataPacket, buf: buf})
+}
+
+// Push 发送消息（异步）
+func (c *serverConn) Push(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.push)
+}
+
+// PushID 发送消息（异步），连接已被复用为其他连接ID时返回errs.ErrConnectionClosed
+func (c *serverConn) PushID(cid int64, msg []byte) error {
+	if c.ID() != cid {
+		return errs.ErrConnectionClosed
+	}
+
+	return c.connMgr.server.send(c, msg, func(msg []byte) error {
+		return c.asyncWriteID(cid, c.chWrite, chWrite{typ: dataPacket, msg: msg})
+	})
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *serverConn) SendBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *serverConn) PushBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *serverConn) push(msg []byte) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（异步）
+func (c *serverConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, buf: buf})
+}
+
+// State 获取连接状态
+func (c *serverConn) State() network.ConnState {
+	return network.ConnState(atomic.LoadInt32(&c.state))
+}
+
+// Close 关闭连接
+func (c *serverConn) Close(force ...bool) error {
+	if len(force) > 0 && force[0] {
+		return c.forceClose(true)
+	} else {
+		return c.graceClose(true)
+	}
+}
+
+// LocalIP 获取本地IP
+func (c *serverConn) LocalIP() (string, error) {
+	addr, err := c.LocalAddr()
+	if err != nil {
+		return "", err
+	}
+
+	return inet.ExtractIP(addr)
+}
+
+// LocalAddr 获取本地地址
+func (c *serverConn) LocalAddr() (net.Addr, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return conn.LocalAddr(), nil
+}
+
+// RemoteIP 获取远端IP
+func (c *serverConn) RemoteIP() (string, error) {
+	addr, err := c.RemoteAddr()
+	if err != nil {
+		return "", err
+	}
+
+	return inet.ExtractIP(addr)
+}
+
+// RemoteAddr 获取远端地址
+func (c *serverConn) RemoteAddr() (net.Addr, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return conn.RemoteAddr(), nil
+}
+
+// PeerCertificate 获取对端TLS证书，kcp不支持TLS，始终返回nil
+func (c *serverConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// 检测连接状态
+func (c *serverConn) checkState() error {
+	switch network.ConnState(atomic.LoadInt32(&c.state)) {
+	case network.ConnHanged:
+		return errs.ErrConnectionHanged
+	case network.ConnClosed:
+		return errs.ErrConnectionClosed
+	default:
+		return nil
+	}
+}
+
+// 初始化连接
+func (c *serverConn) init(cm *serverConnMgr, id int64, conn net.Conn) {
+	// 持有写锁更新连接ID，按连接ID推送时持有读锁校验，连接被复用后不会收到推送
+	c.rw.Lock()
+	atomic.StoreInt64(&c.id, id)
+	c.rw.Unlock()
+	c.conn = conn
+	c.connMgr = cm
+	c.chWrite = make(chan chWrite, cm.server.opts.writeQueueSize)
+	c.done = make(chan struct{})
+	c.close = make(chan struct{})
+	c.lastHeartbeatTime = time.Now().UnixNano()
+	atomic.StoreInt64(&c.uid, 0)
+	atomic.StoreInt32(&c.state, int32(network.ConnOpened))
+
+	icall.Go(c.read)
+
+	icall.Go(c.write)
+
+	if c.connMgr.server.connectHandler != nil {
+		c.connMgr.server.connectHandler(c)
+	}
+}
+
+// 优雅关闭
+func (c *serverConn) graceClose(isNeedRecycle bool) error {
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
+	}
+
+	c.rw.RLock()
+	if c.isClosed() {
+		c.rw.RUnlock()
+		return errs.ErrConnectionClosed
+	}
+	c.chWrite <- chWrite{typ: closeSig}
+	c.rw.RUnlock()
+
+	<-c.done
+
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
+	}
+
+	c.rw.Lock()
+	close(c.chWrite)
+	close(c.close)
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.rw.Unlock()
+
+	err := conn.Close()
+
+	if c.connMgr.server.disconnectHandler != nil {
+		c.connMgr.server.disconnectHandler(c)
+	}
+
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
+	if isNeedRecycle {
+		c.connMgr.recycle(conn)
+	}
+
+	return err
+}
+
+// 优雅关闭，先下发关闭消息并排空写入队列，ctx到期后强制关闭
+func (c *serverConn) shutdown(ctx context.Context, goingAway []byte) {
+	if goingAway != nil {
+		c.rw.RLock()
+		if c.checkState() == nil {
+			select {
+			case c.chWrite <- chWrite{typ: dataPacket, msg: goingAway}:
+			default:
+			}
+		}
+		c.rw.RUnlock()
+	}
+
+	done := make(chan struct{})
+
+	icall.Go(func() {
+		_ = c.graceClose(true)
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.rw.RLock()
+		conn := c.conn
+		c.rw.RUnlock()
+
+		// 中断阻塞中的写入
+		if conn != nil {
+			_ = conn.SetDeadline(time.Now())
+		}
+
+		_ = c.forceClose(true)
+	}
+}
+
+// 强制关闭
+func (c *serverConn) forceClose(isNeedRecycle bool) error {
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
+		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
+			return errs.ErrConnectionClosed
+		}
+	}
+
+	c.rw.Lock()
+	close(c.chWrite)
+	close(c.close)
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.rw.Unlock()
+
+	err := conn.Close()
+
+	if c.connMgr.server.disconnectHandler != nil {
+		c.connMgr.server.disconnectHandler(c)
+	}
+
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
+	if isNeedRecycle {
+		c.connMgr.recycle(conn)
+	}
+
+	return err
+}
+
+// 读取消息
+func (c *serverConn) read() {
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	for {
+		select {
+		case <-c.close:
+			return
+		default:
+			msg, err := c.connMgr.server.opts.packer.ReadMessage(conn)
+			if err != nil {
+				// 数据帧长度不合法时无法继续解析后续数据，关闭连接
+				var sizeErr *errs.SizeError
+				if errors.As(err, &sizeErr) {
+					c.log().Warn("read message error", "error", err)
+				}
+
+				_ = c.forceClose(true)
+				return
+			}
+
+			c.connMgr.server.countIn(len(msg))
+
+			if c.connMgr.server.opts.heartbeatInterval > 0 {
+				atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
+			}
+
+			switch c.State() {
+			case network.ConnHanged:
+				continue
+			case network.ConnClosed:
+				return
+			default:
+				// ignore
+			}
+
+			isHeartbeat, err := c.connMgr.server.opts.packer.CheckHeartbeat(msg)
+			if err != nil {
+				c.log().Warn("check heartbeat message error", "error", err)
+				continue
+			}
+
+			// ignore heartbeat packet
+			if isHeartbeat {
+				// responsive heartbeat
+				if c.connMgr.server.opts.heartbeatMechanism == RespHeartbeat {
+					if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
+						c.log().Error("pack heartbeat message error", "error", err)
+					} else {
+						if _, err = conn.Write(heartbeat); err != nil {
+							c.log().Warn("write heartbeat message error", "error", err)
+						} else {
+							c.connMgr.server.countOut(len(heartbeat))
+						}
+					}
+				}
+				continue
+			}
+
+			// ignore empty packet
+			if len(msg) == 0 {
+				continue
+			}
+
+			c.connMgr.server.receive(c, msg)
+		}
+	}
+}
+
+// 写入消息
+func (c *serverConn) write() {
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	var ticker *time.Ticker
+
+	if c.connMgr.server.opts.heartbeatInterval > 0 {
+		ticker = time.NewTicker(c.connMgr.server.opts.heartbeatInterval)
+		defer ticker.Stop()
+	} else {
+		ticker = &time.Ticker{C: make(chan time.Time, 1)}
+	}
+
+	for {
+		select {
+		case r, ok := <-c.chWrite:
+			if !ok {
+				return
+			}
+
+			if r.typ == closeSig {
+				c.rw.RLock()
+				if !c.isClosed() {
+					c.done <- struct{}{}
+				}
+				c.rw.RUnlock()
+				return
+			}
+
+			if c.isClosed() {
+				return
+			}
+
+			size := r.len()
+
+			if _, err := r.writeTo(conn); err != nil {
+				c.log().Warn("write data message error", "error", err)
+			} else {
+				c.connMgr.server.countOut(size)
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(-2 * c.connMgr.server.opts.heartbeatInterval).UnixNano()
+			if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
+				c.log().Info("connection heartbeat timeout")
+				c.connMgr.server.opts.metrics.Add(metrics.HeartbeatTimeouts, 1, c.connMgr.server.labels...)
+				_ = c.forceClose(true)
+				return
+			} else {
+				if c.connMgr.server.opts.heartbeatMechanism == TickHeartbeat {
+					if c.isClosed() {
+						return
+					}
+
+					if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
+						c.log().Error("pack heartbeat message error", "error", err)
+					} else {
+						// send heartbeat packet
+						if _, err = conn.Write(heartbeat); err != nil {
+							c.log().Warn("write heartbeat message error", "error", err)
+						} else {
+							c.connMgr.server.countOut(len(heartbeat))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// 同步写入连接，写入完成后释放缓冲区
+func (c *serverConn) syncWrite(w chWrite) error {
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+
+	size := w.len()
+
+	if _, err := w.writeTo(conn); err != nil {
+		return err
+	}
+
+	c.connMgr.server.countOut(size)
+
+	return nil
+}
+
+// 异步写入队列，写入队列已满时按溢出策略处理
+func (c *serverConn) asyncWrite(ch chan chWrite, w chWrite) error {
+	return c.asyncWriteID(c.ID(), ch, w)
+}
+
+// 异步写入连接ID为cid的连接的队列，连接已被复用时返回errs.ErrConnectionClosed
+func (c *serverConn) asyncWriteID(cid int64, ch chan chWrite, w chWrite) error {
+	c.rw.RLock()
+	if atomic.LoadInt64(&c.id) != cid {
+		c.rw.RUnlock()
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+	if err := c.checkState(); err != nil {
+		c.rw.RUnlock()
+		w.release()
+		return err
+	}
+	dropped, err := c.enqueue(ch, w)
+	c.rw.RUnlock()
+
+	return c.overflow(dropped, err)
+}
+
+// 写入队列，队列已满时按溢出策略处理，返回被丢弃的消息；调用方需持有读锁
+func (c *serverConn) enqueue(ch chan chWrite, w chWrite) (dropped []chWrite, err error) {
+	opts := c.connMgr.server.opts
+
+	return writeq.Enqueue(ch, w, opts.overflowPolicy, opts.overflowTimeout, func(r chWrite) bool { return r.typ != dataPacket })
+}
+
+// 处理写入队列溢出，disconnect策略下断开连接
+func (c *serverConn) overflow(dropped []chWrite, err error) error {
+	if len(dropped) > 0 {
+		c.connMgr.server.overflow(c, dropped)
+	}
+
+	if err != nil && c.connMgr.server.opts.overflowPolicy == network.OverflowDisconnect {
+		_ = c.forceClose(true)
+	}
+
+	return err
+}
+
+// 是否已关闭
+func (c *serverConn) isClosed() bool {
+	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
+}
+
+// 获取附带连接信息的日志器
+func (c *serverConn) log() logger.Logger {
+	l := c.connMgr.server.opts.logger.With("cid", c.ID(), "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
@@ -0,0 +1,188 @@
+package kcp
+
+import (
+	"context"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type serverConnMgr struct {
+	id         int64        // 连接ID
+	total      int64        // 总连接数
+	server     *server      // 服务器
+	pool       sync.Pool    // 连接池
+	partitions []*partition // 连接管理
+}
+
+func newServerConnMgr(server *server) *serverConnMgr {
+	cm := &serverConnMgr{}
+	cm.server = server
+	cm.pool = sync.Pool{New: func() interface{} { return &serverConn{} }}
+	cm.partitions = make([]*partition, 100)
+
+	for i := 0; i < len(cm.partitions); i++ {
+		cm.partitions[i] = &partition{connections: make(map[net.Conn]*serverConn)}
+	}
+
+	return cm
+}
+
+// 关闭连接
+func (cm *serverConnMgr) close() {
+	var wg sync.WaitGroup
+
+	wg.Add(len(cm.partitions))
+
+	for i := range cm.partitions {
+		p := cm.partitions[i]
+
+		icall.Go(func() {
+			p.close()
+			wg.Done()
+		})
+	}
+
+	wg.Wait()
+}
+
+// 优雅关闭所有连接，ctx到期后强制关闭剩余连接
+func (cm *serverConnMgr) shutdown(ctx context.Context, goingAway []byte) error {
+	var (
+		wg    sync.WaitGroup
+		conns []*serverConn
+	)
+
+	for _, p := range cm.partitions {
+		conns = append(conns, p.snapshot()...)
+	}
+
+	wg.Add(len(conns))
+
+	for i := range conns {
+		conn := conns[i]
+
+		icall.Go(func() {
+			conn.shutdown(ctx, goingAway)
+			wg.Done()
+		})
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// 分配连接
+func (cm *serverConnMgr) allocate(c net.Conn) error {
+	if atomic.LoadInt64(&cm.total) >= int64(cm.server.opts.maxConnNum) {
+		cm.server.opts.metrics.Add(metrics.ConnectionsRejected, 1, cm.server.labels...)
+		return errs.ErrTooManyConnection
+	}
+
+	id := atomic.AddInt64(&cm.id, 1)
+	conn := cm.pool.Get().(*serverConn)
+	conn.init(cm, id, c)
+	index := int(reflect.ValueOf(c).Pointer()) % len(cm.partitions)
+	cm.partitions[index].store(c, conn)
+	atomic.AddInt64(&cm.total, 1)
+	cm.server.opts.metrics.Add(metrics.ConnectionsAccepted, 1, cm.server.labels...)
+
+	return nil
+}
+
+// 回收连接
+func (cm *serverConnMgr) recycle(c net.Conn) {
+	index := int(reflect.ValueOf(c).Pointer()) % len(cm.partitions)
+	if conn, ok := cm.partitions[index].delete(c); ok {
+		conn.Reset()
+		cm.pool.Put(conn)
+		atomic.AddInt64(&cm.total, -1)
+	}
+}
+
+// 当前连接数
+func (cm *serverConnMgr) active() float64 {
+	return float64(atomic.LoadInt64(&cm.total))
+}
+
+// 所有连接写入队列中待发送的消息数
+func (cm *serverConnMgr) queued() float64 {
+	var n int
+
+	for _, p := range cm.partitions {
+		n += p.queued()
+	}
+
+	return float64(n)
+}
+
+type partition struct {
+	rw          sync.RWMutex
+	connections map[net.Conn]*serverConn
+}
+
+// 存储连接
+func (p *partition) store(c net.Conn, conn *serverConn) {
+	p.rw.Lock()
+	p.connections[c] = conn
+	p.rw.Unlock()
+}
+
+// 加载连接
+func (p *partition) load(c net.Conn) (*serverConn, bool) {
+	p.rw.RLock()
+	conn, ok := p.connections[c]
+	p.rw.RUnlock()
+
+	return conn, ok
+}
+
+// 获取该分片内所有连接的快照
+func (p *partition) snapshot() []*serverConn {
+	p.rw.RLock()
+	defer p.rw.RUnlock()
+
+	conns := make([]*serverConn, 0, len(p.connections))
+	for _, conn := range p.connections {
+		conns = append(conns, conn)
+	}
+
+	return conns
+}
+
+// 该分片内所有连接写入队列中待发送的消息数
+func (p *partition) queued() int {
+	p.rw.RLock()
+	defer p.rw.RUnlock()
+
+	var n int
+	for _, conn := range p.connections {
+		n += len(conn.chWrite)
+	}
+
+	return n
+}
+
+// 删除连接
+func (p *partition) delete(c net.Conn) (*serverConn, bool) {
+	p.rw.Lock()
+	conn, ok := p.connections[c]
+	if ok {
+		delete(p.connections, c)
+	}
+	p.rw.Unlock()
+
+	return conn, ok
+}
+
+// 关闭该分片内的所有连接
+func (p *partition) close() {
+	for _, conn := range p.snapshot() {
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package kcp
+
+import (
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"time"
+)
+
+const (
+	defaultServerAddr               = ":3553"
+	defaultServerMaxConnNum         = 5000
+	defaultServerHeartbeatInterval  = time.Second * 10
+	defaultServerHeartbeatMechanism = "resp"
+	defaultServerWriteQueueSize     = 4096
+	defaultServerOverflowPolicy     = network.OverflowBlock
+
+	defaultServerPackerName = "due"
+)
+
+const (
+	RespHeartbeat HeartbeatMechanism = "resp" // 响应式心跳
+	TickHeartbeat HeartbeatMechanism = "tick" // 主动定时心跳
+)
+
+type HeartbeatMechanism string
+
+type ServerOption func(o *serverOptions)
+
+type serverOptions struct {
+	addr               string                  // 监听地址，默认0.0.0.0:3553
+	maxConnNum         int                     // 最大连接数，默认5000
+	heartbeatInterval  time.Duration           // 心跳检测间隔时间，默认10s
+	heartbeatMechanism HeartbeatMechanism      // 心跳机制，默认resp
+	goingAway          ipacket.Message         // 优雅关闭时下发给客户端的消息
+	writeQueueSize     int                     // 写入队列大小，默认4096
+	overflowPolicy     network.OverflowPolicy  // 写入队列溢出策略，默认block
+	overflowTimeout    time.Duration           // block策略的最长等待时间，默认为0表示一直等待
+	overflowHandler    network.OverflowHandler // 写入队列溢出hook函数
+	kcp                kcpOptions              // KCP配置，默认极速模式
+
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	metrics  metrics.Metrics       // 指标采集器，默认使用全局采集器
+	packer   ipacket.Packer
+}
+
+func defaultServerOptions() *serverOptions {
+	return &serverOptions{
+		addr:               defaultServerAddr,
+		maxConnNum:         defaultServerMaxConnNum,
+		heartbeatInterval:  defaultServerHeartbeatInterval,
+		heartbeatMechanism: HeartbeatMechanism(defaultServerHeartbeatMechanism),
+		writeQueueSize:     defaultServerWriteQueueSize,
+		overflowPolicy:     defaultServerOverflowPolicy,
+		kcp:                defaultKcpOptions(),
+		logger:             logger.Default(),
+		metrics:            metrics.Default(),
+		packer:             packet.GetDefaultPacker(defaultServerPackerName),
+	}
+}
+
+// 校验配置，返回所有不合法的配置项
+func (o *serverOptions) validate() error {
+	var problems []string
+
+	if o.writeQueueSize < 0 {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than or equal to 0, and give %d", o.writeQueueSize))
+	}
+
+	// 无缓冲队列无法丢弃最旧的消息
+	if o.writeQueueSize == 0 && o.overflowPolicy == network.OverflowDropOldest {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than 0 when the overflow policy is %s", o.overflowPolicy))
+	}
+
+	if len(problems) > 0 {
+		return &network.ConfigError{Protocol: protocol, Problems: problems}
+	}
+
+	return nil
+}
+
+// WithServerListenAddr 设置监听地址
+func WithServerListenAddr(addr string) ServerOption {
+	return func(o *serverOptions) { o.addr = addr }
+}
+
+// WithServerMaxConnNum 设置连接的最大连接数
+func WithServerMaxConnNum(maxConnNum int) ServerOption {
+	return func(o *serverOptions) { o.maxConnNum = maxConnNum }
+}
+
+// WithServerHeartbeatInterval 设置心跳检测间隔时间
+func WithServerHeartbeatInterval(heartbeatInterval time.Duration) ServerOption {
+	return func(o *serverOptions) { o.heartbeatInterval = heartbeatInterval }
+}
+
+// WithServerHeartbeatMechanism 设置心跳机制
+func WithServerHeartbeatMechanism(heartbeatMechanism HeartbeatMechanism) ServerOption {
+	return func(o *serverOptions) { o.heartbeatMechanism = heartbeatMechanism }
+}
+
+func WithServerPacker(packer ipacket.Packer) ServerOption {
+	return func(o *serverOptions) {
+		o.packer = packer
+	}
+}
+
+// WithServerGoingAway 设置优雅关闭时下发给客户端的消息，使用服务器的打包器打包
+func WithServerGoingAway(message ipacket.Message) ServerOption {
+	return func(o *serverOptions) { o.goingAway = message }
+}
+
+// WithServerNoDelay 设置KCP工作模式
+// nodelay是否启用nodelay，interval内部刷新间隔（毫秒），resend快速重传阈值，nc是否关闭拥塞控制
+func WithServerNoDelay(nodelay, interval, resend, nc int) ServerOption {
+	return func(o *serverOptions) {
+		o.kcp.nodelay, o.kcp.interval, o.kcp.resend, o.kcp.nc = nodelay, interval, resend, nc
+	}
+}
+
+// WithServerWindowSize 设置KCP发送与接收窗口大小
+func WithServerWindowSize(sndWnd, rcvWnd int) ServerOption {
+	return func(o *serverOptions) { o.kcp.sndWnd, o.kcp.rcvWnd = sndWnd, rcvWnd }
+}
+
+// WithServerMTU 设置KCP最大传输单元
+func WithServerMTU(mtu int) ServerOption {
+	return func(o *serverOptions) { o.kcp.mtu = mtu }
+}
+
+// WithServerWriteQueueSize 设置写入队列大小，不可小于0，drop_oldest策略下须大于0
+func WithServerWriteQueueSize(size int) ServerOption {
+	return func(o *serverOptions) { o.writeQueueSize = size }
+}
+
+// WithServerOverflowPolicy 设置写入队列溢出策略，timeout为block策略的最长等待时间
+func WithServerOverflowPolicy(policy network.OverflowPolicy, timeout ...time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.overflowPolicy = policy
+		if len(timeout) > 0 {
+			o.overflowTimeout = timeout[0]
+		}
+	}
+}
+
+// WithServerOverflowHandler 设置写入队列溢出hook函数
+func WithServerOverflowHandler(handler network.OverflowHandler) ServerOption {
+	return func(o *serverOptions) { o.overflowHandler = handler }
+}
+
+// WithServerLogger 设置日志器
+func WithServerLogger(l logger.Logger) ServerOption {
+	return func(o *serverOptions) { o.logger = l }
+}
+
+// WithServerMetrics 设置指标采集器
+func WithServerMetrics(m metrics.Metrics) ServerOption {
+	return func(o *serverOptions) { o.metrics = m }
+}
+
+// WithServerInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithServerInboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithServerOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithServerOutboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
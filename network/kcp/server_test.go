@@ -0,0 +1,192 @@
+package kcp_test
+
+import (
+	"context"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/kcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listenAddr(t *testing.T) string {
+	port, err := inet.AssignRandPort("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+func TestServer_Echo(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := kcp.NewServer(kcp.WithServerListenAddr(addr), kcp.WithServerPacker(packer))
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		if err := conn.Push(msg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan string, 10)
+
+	client := kcp.NewClient(kcp.WithClientDialAddr(addr), kcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- string(message.GetData())
+	})
+
+	conn, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(true)
+
+	// 超过单个MTU的消息需要分片传输
+	large := make([]byte, 4*1024)
+	for i := range large {
+		large[i] = byte('a' + i%26)
+	}
+
+	for _, data := range []string{"hello", string(large), "world"} {
+		msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte(data)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = conn.Push(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case got := <-received:
+			if got != data {
+				t.Fatalf("received %d bytes, want %d", len(got), len(data))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for echo")
+		}
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	const total = 1000
+
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := kcp.NewServer(
+		kcp.WithServerListenAddr(addr),
+		kcp.WithServerPacker(packer),
+		kcp.WithServerGoingAway(&due.Message{Route: 99}),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan int32, total+1)
+
+	client := kcp.NewClient(kcp.WithClientDialAddr(addr), kcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- message.(*due.Message).Route
+	})
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close(true)
+
+	// KCP无连接握手，服务器在收到首个报文时建立连接
+	heartbeat, err := packer.PackHeartbeat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.Push(heartbeat); err != nil {
+		t.Fatal(err)
+	}
+
+	var conn network.Conn
+	select {
+	case conn = <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+
+	msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < total; i++ {
+		if err = conn.Push(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err = server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i <= total; i++ {
+		select {
+		case route := <-received:
+			if i < total && route != 1 {
+				t.Fatalf("message %d route %d, want 1", i, route)
+			}
+			if i == total && route != 99 {
+				t.Fatalf("last message route %d, want going away route 99", route)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d messages, want %d", i, total+1)
+		}
+	}
+
+	if conn.State() != network.ConnClosed {
+		t.Fatal("server conn should be closed")
+	}
+}
+
+func TestServer_InvalidWriteQueueSize(t *testing.T) {
+	opts := []kcp.ServerOption{
+		kcp.WithServerListenAddr(listenAddr(t)),
+		kcp.WithServerWriteQueueSize(0),
+		kcp.WithServerOverflowPolicy(network.OverflowDropOldest),
+	}
+
+	var configErr *network.ConfigError
+	if _, err := kcp.NewServerE(opts...); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+
+	if err := kcp.NewServer(opts...).Start(); !errors.As(err, &configErr) {
+		t.Fatalf("expected start to return config error, got %v", err)
+	}
+}
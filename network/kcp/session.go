@@ -0,0 +1,421 @@
+package kcp
+
+import (
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	sessionLinger  = 3 * time.Second // 关闭时等待未确认数据的最长时间
+	sessionMaxSend = 128             // 单次提交给KCP的最大分片数
+	udpBufferSize  = 64 * 1024       // UDP读缓冲大小
+)
+
+var refTime = time.Now()
+
+// 当前毫秒时间
+func currentMs() uint32 {
+	return uint32(time.Since(refTime) / time.Millisecond)
+}
+
+// session KCP会话，在UDP上提供可靠的有序字节流，实现net.Conn
+type session struct {
+	mu       sync.Mutex
+	kcp      *ikcp          // KCP控制块
+	conn     net.PacketConn // UDP连接
+	remote   net.Addr       // 远端地址
+	listener *listener      // 服务端会话所属的监听器，客户端会话为nil
+	buf      []byte         // 未读完的数据
+	rd       time.Time      // 读超时时间
+	wd       time.Time      // 写超时时间
+	chRead   chan struct{}  // 可读信号
+	chWrite  chan struct{}  // 可写信号
+	die      chan struct{}  // 关闭信号
+	dieOnce  sync.Once
+}
+
+var _ net.Conn = &session{}
+
+func newSession(conv uint32, conn net.PacketConn, remote net.Addr, l *listener, opts *kcpOptions) *session {
+	s := &session{
+		conn:     conn,
+		remote:   remote,
+		listener: l,
+		chRead:   make(chan struct{}, 1),
+		chWrite:  make(chan struct{}, 1),
+		die:      make(chan struct{}),
+	}
+
+	s.kcp = newIkcp(conv, s.output)
+	s.kcp.stream = 1
+	s.kcp.NoDelay(opts.nodelay, opts.interval, opts.resend, opts.nc)
+	s.kcp.WndSize(opts.sndWnd, opts.rcvWnd)
+	s.kcp.SetMtu(opts.mtu)
+
+	icall.Go(s.update)
+
+	return s
+}
+
+// 拨号创建客户端会话
+func dial(addr string, opts *kcpOptions) (*session, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSession(rand.Uint32(), conn, udpAddr, nil, opts)
+
+	icall.Go(s.monitor)
+
+	return s, nil
+}
+
+// Read 读取数据
+func (s *session) Read(b []byte) (int, error) {
+	var timeout *time.Timer
+
+	defer func() {
+		if timeout != nil {
+			timeout.Stop()
+		}
+	}()
+
+	for {
+		s.mu.Lock()
+
+		if s.isClosed() {
+			s.mu.Unlock()
+			return 0, io.ErrClosedPipe
+		}
+
+		if len(s.buf) > 0 {
+			n := copy(b, s.buf)
+			s.buf = s.buf[n:]
+			s.mu.Unlock()
+			return n, nil
+		}
+
+		if size := s.kcp.PeekSize(); size > 0 {
+			if len(b) >= size {
+				s.kcp.Recv(b)
+				s.mu.Unlock()
+				return size, nil
+			}
+
+			buf := make([]byte, size)
+			s.kcp.Recv(buf)
+			n := copy(b, buf)
+			s.buf = buf[n:]
+			s.mu.Unlock()
+			return n, nil
+		}
+
+		deadline := s.rd
+		s.mu.Unlock()
+
+		var c <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+
+			if timeout == nil {
+				timeout = time.NewTimer(d)
+			} else {
+				timeout.Reset(d)
+			}
+			c = timeout.C
+		}
+
+		select {
+		case <-s.chRead:
+		case <-c:
+			return 0, os.ErrDeadlineExceeded
+		case <-s.die:
+			return 0, io.ErrClosedPipe
+		}
+	}
+}
+
+// Write 写入数据，发送队列已满时阻塞
+func (s *session) Write(b []byte) (int, error) {
+	var timeout *time.Timer
+
+	defer func() {
+		if timeout != nil {
+			timeout.Stop()
+		}
+	}()
+
+	for {
+		s.mu.Lock()
+
+		if s.isClosed() {
+			s.mu.Unlock()
+			return 0, io.ErrClosedPipe
+		}
+
+		if s.kcp.WaitSnd() < s.sendLimit() {
+			n := len(b)
+			for max := int(s.kcp.mss) * sessionMaxSend; len(b) > 0; {
+				size := len(b)
+				if size > max {
+					size = max
+				}
+				s.kcp.Send(b[:size])
+				b = b[size:]
+			}
+
+			s.kcp.current = currentMs()
+			s.kcp.flush()
+			s.mu.Unlock()
+
+			return n, nil
+		}
+
+		deadline := s.wd
+		s.mu.Unlock()
+
+		var c <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+
+			if timeout == nil {
+				timeout = time.NewTimer(d)
+			} else {
+				timeout.Reset(d)
+			}
+			c = timeout.C
+		}
+
+		select {
+		case <-s.chWrite:
+		case <-c:
+			return 0, os.ErrDeadlineExceeded
+		case <-s.die:
+			return 0, io.ErrClosedPipe
+		}
+	}
+}
+
+// Close 关闭会话，关闭前等待已写入的数据被对端确认
+func (s *session) Close() error {
+	return s.close(true)
+}
+
+// LocalAddr 获取本地地址
+func (s *session) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr 获取远端地址
+func (s *session) RemoteAddr() net.Addr {
+	return s.remote
+}
+
+// SetDeadline 设置读写超时时间
+func (s *session) SetDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.rd = t
+	s.wd = t
+	s.mu.Unlock()
+
+	notify(s.chRead)
+	notify(s.chWrite)
+
+	return nil
+}
+
+// SetReadDeadline 设置读超时时间
+func (s *session) SetReadDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.rd = t
+	s.mu.Unlock()
+
+	notify(s.chRead)
+
+	return nil
+}
+
+// SetWriteDeadline 设置写超时时间
+func (s *session) SetWriteDeadline(t time.Time) error {
+	s.mu.Lock()
+	s.wd = t
+	s.mu.Unlock()
+
+	notify(s.chWrite)
+
+	return nil
+}
+
+// 输入底层收到的UDP报文
+func (s *session) input(data []byte) {
+	s.mu.Lock()
+	s.kcp.current = currentMs()
+	s.kcp.Input(data)
+
+	// 立即回复确认，降低对端的RTT
+	if len(s.kcp.acklist) > 0 {
+		s.kcp.flush()
+	}
+
+	readable := s.kcp.PeekSize() > 0
+	writable := s.kcp.WaitSnd() < s.sendLimit()
+	s.mu.Unlock()
+
+	if readable {
+		notify(s.chRead)
+	}
+
+	if writable {
+		notify(s.chWrite)
+	}
+}
+
+// 输出KCP报文到UDP
+func (s *session) output(buf []byte) {
+	if s.listener != nil {
+		_, _ = s.conn.WriteTo(buf, s.remote)
+	} else {
+		_, _ = s.conn.(*net.UDPConn).Write(buf)
+	}
+}
+
+// 按刷新间隔驱动KCP
+func (s *session) update() {
+	s.mu.Lock()
+	interval := time.Duration(s.kcp.interval) * time.Millisecond
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.die:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.kcp.Update(currentMs())
+			dead := s.kcp.state == ikcpDeadState
+			writable := s.kcp.WaitSnd() < s.sendLimit()
+			s.mu.Unlock()
+
+			if dead {
+				_ = s.close(false)
+				return
+			}
+
+			if writable {
+				notify(s.chWrite)
+			}
+		}
+	}
+}
+
+// 客户端会话读取UDP报文
+func (s *session) monitor() {
+	buf := make([]byte, udpBufferSize)
+	conn := s.conn.(*net.UDPConn)
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			_ = s.close(false)
+			return
+		}
+
+		if n >= ikcpOverhead {
+			s.input(buf[:n])
+		}
+	}
+}
+
+// 关闭会话，linger为true时等待已写入的数据被对端确认
+func (s *session) close(linger bool) error {
+	closed := false
+
+	s.dieOnce.Do(func() {
+		if linger {
+			s.linger()
+		}
+
+		close(s.die)
+		closed = true
+	})
+
+	if !closed {
+		return io.ErrClosedPipe
+	}
+
+	if s.listener != nil {
+		s.listener.remove(s)
+		return nil
+	}
+
+	return s.conn.Close()
+}
+
+// 等待发送队列清空，最长等待至写超时时间或sessionLinger
+func (s *session) linger() {
+	s.mu.Lock()
+	deadline := time.Now().Add(sessionLinger)
+	if !s.wd.IsZero() && s.wd.Before(deadline) {
+		deadline = s.wd
+	}
+	interval := time.Duration(s.kcp.interval) * time.Millisecond
+	s.mu.Unlock()
+
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		pending := s.kcp.WaitSnd()
+		dead := s.kcp.state == ikcpDeadState
+		s.mu.Unlock()
+
+		if pending == 0 || dead {
+			return
+		}
+
+		select {
+		case <-s.chWrite:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// 允许排队的最大分片数
+func (s *session) sendLimit() int {
+	return int(s.kcp.sndWnd) * 2
+}
+
+// 是否已关闭
+func (s *session) isClosed() bool {
+	select {
+	case <-s.die:
+		return true
+	default:
+		return false
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,16 @@
+package network
+
+const (
+	OverflowBlock      OverflowPolicy = "block"       // 阻塞等待队列空闲，超时后丢弃最新消息
+	OverflowDropNewest OverflowPolicy = "drop_newest" // 丢弃最新消息
+	OverflowDropOldest OverflowPolicy = "drop_oldest" // 丢弃队列中最早的消息
+	OverflowDisconnect OverflowPolicy = "disconnect"  // 丢弃最新消息并断开连接
+)
+
+type (
+	// OverflowPolicy 写入队列溢出策略
+	OverflowPolicy string
+
+	// OverflowHandler 写入队列溢出hook函数，msg为被丢弃的消息
+	OverflowHandler func(conn Conn, msg []byte, policy OverflowPolicy)
+)
@@ -0,0 +1,147 @@
+package reconnect
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type client struct {
+	opts              *options                  // 配置
+	client            network.Client            // 底层客户端
+	mu                sync.Mutex                // 拨号锁
+	dialing           *clientConn               // 正在拨号的连接
+	conns             sync.Map                  // 底层连接ID -> 连接
+	connectHandler    network.ConnectHandler    // 连接打开hook函数
+	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
+	receiveHandler    network.ReceiveHandler    // 接收消息hook函数
+}
+
+var _ network.Client = &client{}
+
+// NewClient 创建自动重连客户端，底层连接断开后按指数退避重新拨号
+func NewClient(c network.Client, opts ...Option) network.Client {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cli := &client{opts: o, client: c}
+
+	c.OnConnect(cli.handleConnect)
+	c.OnDisconnect(cli.handleDisconnect)
+	c.OnReceive(cli.handleReceive)
+
+	return cli
+}
+
+// Dial 拨号连接，返回的连接在重连后保持不变
+func (c *client) Dial(addr ...string) (network.Conn, error) {
+	cc := newClientConn(c, addr)
+
+	conn, err := c.dial(cc)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.open(conn)
+
+	c.connected(cc)
+
+	return cc, nil
+}
+
+// Protocol 协议
+func (c *client) Protocol() string {
+	return c.client.Protocol()
+}
+
+// OnConnect 监听连接打开，每次重连成功且切换到新的底层连接后触发
+func (c *client) OnConnect(handler network.ConnectHandler) {
+	c.connectHandler = handler
+}
+
+// OnDisconnect 监听连接断开，每次底层连接断开都会触发
+func (c *client) OnDisconnect(handler network.DisconnectHandler) {
+	c.disconnectHandler = handler
+}
+
+// OnReceive 监听接收到消息
+func (c *client) OnReceive(handler network.ReceiveHandler) {
+	c.receiveHandler = handler
+}
+
+// 拨号底层连接
+func (c *client) dial(cc *clientConn) (network.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dialing = cc
+	defer func() { c.dialing = nil }()
+
+	return c.client.Dial(cc.addr...)
+}
+
+// 计算第attempt次重连的退避时间
+func (c *client) backoff(attempt int) time.Duration {
+	d := c.opts.minBackoff
+	for i := 1; i < attempt && d < c.opts.maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > c.opts.maxBackoff {
+		d = c.opts.maxBackoff
+	}
+
+	if c.opts.jitter > 0 && d > 0 {
+		d += time.Duration(rand.Int63n(int64(float64(d)*c.opts.jitter) + 1))
+	}
+
+	return d
+}
+
+// 处理底层连接打开，此时尚未切换到新的底层连接，连接打开hook函数在切换完成后调用
+func (c *client) handleConnect(conn network.Conn) {
+	cc := c.dialing
+	if cc == nil {
+		return
+	}
+
+	c.conns.Store(conn.ID(), cc)
+}
+
+// 调用连接打开hook函数
+func (c *client) connected(cc *clientConn) {
+	if c.connectHandler != nil {
+		c.connectHandler(cc)
+	}
+}
+
+// 处理底层连接断开
+func (c *client) handleDisconnect(conn network.Conn) {
+	v, ok := c.conns.LoadAndDelete(conn.ID())
+	if !ok {
+		return
+	}
+
+	cc := v.(*clientConn)
+	cc.lost(conn)
+
+	if c.disconnectHandler != nil {
+		c.disconnectHandler(cc)
+	}
+}
+
+// 处理接收消息
+func (c *client) handleReceive(conn network.Conn, msg []byte) {
+	if c.receiveHandler == nil {
+		return
+	}
+
+	if v, ok := c.conns.Load(conn.ID()); ok {
+		c.receiveHandler(v.(*clientConn), msg)
+	} else {
+		c.receiveHandler(conn, msg)
+	}
+}
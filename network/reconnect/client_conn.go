@@ -0,0 +1,365 @@
+package reconnect
+
+import (
+	"crypto/x509"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
+	"net"
+	"sync"
+	"time"
+)
+
+type clientConn struct {
+	rw            sync.RWMutex
+	client        *client           // 客户端
+	addr          []string          // 拨号地址
+	conn          network.Conn      // 当前底层连接
+	uid           int64             // 用户ID，重连后自动重新绑定
+	state         network.ConnState // 连接状态，重连期间为ConnHanged
+	queue         [][]byte          // 重连期间缓冲的消息
+	close         chan struct{}     // 关闭信号
+	network.Attrs                   // 连接属性，重连后保持不变
+}
+
+var _ network.Conn = &clientConn{}
+
+func newClientConn(client *client, addr []string) *clientConn {
+	return &clientConn{
+		client: client,
+		addr:   addr,
+		close:  make(chan struct{}),
+	}
+}
+
+// ID 获取当前底层连接ID，尚未建立底层连接时返回0
+func (c *clientConn) ID() int64 {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	if c.conn == nil {
+		return 0
+	}
+
+	return c.conn.ID()
+}
+
+// UID 获取用户ID
+func (c *clientConn) UID() int64 {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	return c.uid
+}
+
+// Bind 绑定用户ID
+func (c *clientConn) Bind(uid int64) {
+	c.rw.Lock()
+	c.uid = uid
+	conn := c.conn
+	c.rw.Unlock()
+
+	conn.Bind(uid)
+}
+
+// Unbind 解绑用户ID
+func (c *clientConn) Unbind() {
+	c.rw.Lock()
+	c.uid = 0
+	conn := c.conn
+	c.rw.Unlock()
+
+	conn.Unbind()
+}
+
+// Send 发送消息（同步），重连期间立即失败
+func (c *clientConn) Send(msg []byte) error {
+	conn, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	return conn.Send(msg)
+}
+
+// Push 发送消息（异步），重连期间写入缓冲，缓冲已满时失败
+func (c *clientConn) Push(msg []byte) error {
+	c.rw.Lock()
+
+	switch c.state {
+	case network.ConnOpened:
+		conn := c.conn
+		c.rw.Unlock()
+		return conn.Push(msg)
+	case network.ConnHanged:
+		defer c.rw.Unlock()
+
+		if len(c.queue) >= c.client.opts.bufferSize {
+			return errs.ErrConnectionReconnecting
+		}
+
+		c.queue = append(c.queue, msg)
+
+		return nil
+	default:
+		c.rw.Unlock()
+		return errs.ErrConnectionClosed
+	}
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *clientConn) SendBuffer(buf buffer.Buffer) error {
+	conn, err := c.current()
+	if err != nil {
+		buf.Release()
+		return err
+	}
+
+	return conn.SendBuffer(buf)
+}
+
+// PushBuffer 发送消息（异步），重连期间拷贝后写入缓冲，缓冲已满时失败
+func (c *clientConn) PushBuffer(buf buffer.Buffer) error {
+	c.rw.Lock()
+
+	switch c.state {
+	case network.ConnOpened:
+		conn := c.conn
+		c.rw.Unlock()
+		return conn.PushBuffer(buf)
+	case network.ConnHanged:
+		defer c.rw.Unlock()
+		defer buf.Release()
+
+		if len(c.queue) >= c.client.opts.bufferSize {
+			return errs.ErrConnectionReconnecting
+		}
+
+		c.queue = append(c.queue, network.CopyBuffer(buf))
+
+		return nil
+	default:
+		c.rw.Unlock()
+		buf.Release()
+		return errs.ErrConnectionClosed
+	}
+}
+
+// State 获取连接状态
+func (c *clientConn) State() network.ConnState {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	return c.state
+}
+
+// Close 关闭连接并停止重连
+func (c *clientConn) Close(force ...bool) error {
+	c.rw.Lock()
+	if c.state == network.ConnClosed {
+		c.rw.Unlock()
+		return errs.ErrConnectionClosed
+	}
+
+	state := c.state
+	c.state = network.ConnClosed
+	c.queue = nil
+	close(c.close)
+	conn := c.conn
+	c.rw.Unlock()
+
+	if state == network.ConnHanged {
+		return nil
+	}
+
+	return conn.Close(force...)
+}
+
+// LocalIP 获取本地IP
+func (c *clientConn) LocalIP() (string, error) {
+	conn, err := c.current()
+	if err != nil {
+		return "", err
+	}
+
+	return conn.LocalIP()
+}
+
+// LocalAddr 获取本地地址
+func (c *clientConn) LocalAddr() (net.Addr, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.LocalAddr()
+}
+
+// RemoteIP 获取远端IP
+func (c *clientConn) RemoteIP() (string, error) {
+	conn, err := c.current()
+	if err != nil {
+		return "", err
+	}
+
+	return conn.RemoteIP()
+}
+
+// RemoteAddr 获取远端地址
+func (c *clientConn) RemoteAddr() (net.Addr, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.RemoteAddr()
+}
+
+// PeerCertificate 获取对端TLS证书
+func (c *clientConn) PeerCertificate() (*x509.Certificate, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.PeerCertificate()
+}
+
+// 获取当前可用的底层连接
+func (c *clientConn) current() (network.Conn, error) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	switch c.state {
+	case network.ConnOpened:
+		return c.conn, nil
+	case network.ConnHanged:
+		return nil, errs.ErrConnectionReconnecting
+	default:
+		return nil, errs.ErrConnectionClosed
+	}
+}
+
+// 底层连接建立
+func (c *clientConn) open(conn network.Conn) {
+	c.rw.Lock()
+	c.conn = conn
+	c.state = network.ConnOpened
+	c.rw.Unlock()
+}
+
+// 底层连接断开，开始重连
+func (c *clientConn) lost(conn network.Conn) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+
+	if c.conn != conn || c.state != network.ConnOpened {
+		return
+	}
+
+	c.state = network.ConnHanged
+
+	icall.Go(c.reconnect)
+}
+
+// 按指数退避重连
+func (c *clientConn) reconnect() {
+	opts := c.client.opts
+
+	for attempt := 1; opts.maxAttempts <= 0 || attempt <= opts.maxAttempts; attempt++ {
+		timer := time.NewTimer(c.client.backoff(attempt))
+
+		select {
+		case <-c.close:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		conn, err := c.client.dial(c)
+		if err != nil {
+			c.client.opts.logger.Warn("reconnect attempt failed", "attempt", attempt, "error", err)
+			continue
+		}
+
+		if uid := c.UID(); uid != 0 {
+			conn.Bind(uid)
+		}
+
+		if opts.reconnectHandler != nil {
+			if err = opts.reconnectHandler(conn); err != nil {
+				c.client.opts.logger.Warn("reconnect handler error", "error", err)
+				_ = conn.Close(true)
+				continue
+			}
+		}
+
+		ok, closed := c.resume(conn)
+		if ok {
+			c.client.connected(c)
+			return
+		}
+
+		_ = conn.Close(true)
+
+		if closed {
+			return
+		}
+	}
+
+	c.client.opts.logger.Error("reconnect give up", "attempts", opts.maxAttempts)
+
+	c.rw.Lock()
+	if c.state == network.ConnHanged {
+		c.state = network.ConnClosed
+		c.queue = nil
+		close(c.close)
+	}
+	c.rw.Unlock()
+}
+
+// 切换到新的底层连接并发送缓冲的消息
+// 发送缓冲期间保持ConnHanged状态，新的消息继续写入缓冲以保证顺序，发送时不持有锁
+// 新连接已断开时ok为false，需继续重连；连接已被主动关闭时closed为true
+func (c *clientConn) resume(conn network.Conn) (ok bool, closed bool) {
+	c.rw.Lock()
+	if c.state != network.ConnHanged {
+		c.rw.Unlock()
+		return false, true
+	}
+	prev := c.conn
+	c.conn = conn
+	c.rw.Unlock()
+
+	for {
+		c.rw.Lock()
+		if c.state != network.ConnHanged {
+			c.rw.Unlock()
+			return false, true
+		}
+
+		// 在锁内检测新连接状态，避免新连接断开的通知因仍处于ConnHanged状态被忽略
+		if conn.State() != network.ConnOpened {
+			c.conn = prev
+			c.rw.Unlock()
+			return false, false
+		}
+
+		queue := c.queue
+		c.queue = nil
+
+		if len(queue) == 0 {
+			c.state = network.ConnOpened
+			c.rw.Unlock()
+			return true, false
+		}
+		c.rw.Unlock()
+
+		for _, msg := range queue {
+			if err := conn.Push(msg); err != nil {
+				c.client.opts.logger.Warn("flush buffered message error", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package reconnect_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/reconnect"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClient_Reconnect(t *testing.T) {
+	port, err := inet.AssignRandPort("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	packer := due.NewPacker()
+	received := make(chan string, 10)
+
+	startServer := func() network.Server {
+		server := tcp.NewServer(tcp.WithServerListenAddr(addr), tcp.WithServerPacker(packer))
+		server.OnReceive(func(conn network.Conn, msg []byte) {
+			message, err := packer.UnpackMessage(msg)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			received <- string(message.GetData())
+		})
+		if err := server.Start(); err != nil {
+			t.Fatal(err)
+		}
+		return server
+	}
+
+	pack := func(s string) []byte {
+		msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte(s)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	server := startServer()
+
+	client := reconnect.NewClient(
+		tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer)),
+		reconnect.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		reconnect.WithBufferSize(10),
+		reconnect.WithReconnectHandler(func(conn network.Conn) error {
+			return conn.Push(pack("auth"))
+		}),
+	)
+
+	type connected struct {
+		id    int64
+		state network.ConnState
+	}
+
+	// 连接打开hook函数在切换到新的底层连接后调用
+	connects := make(chan connected, 2)
+	client.OnConnect(func(conn network.Conn) {
+		connects <- connected{id: conn.ID(), state: conn.State()}
+	})
+
+	conn, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(true)
+
+	first := <-connects
+	if first.id == 0 || first.id != conn.ID() || first.state != network.ConnOpened {
+		t.Fatalf("connect hook saw id %d state %d, want id %d state %d", first.id, first.state, conn.ID(), network.ConnOpened)
+	}
+
+	if err = conn.Push(pack("first")); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, received, "first")
+
+	_ = server.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for conn.State() != network.ConnHanged {
+		if time.Now().After(deadline) {
+			t.Fatal("conn not reconnecting after server stop")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err = conn.Push(pack("queued")); err != nil {
+		t.Fatal(err)
+	}
+
+	server = startServer()
+	defer server.Stop()
+
+	expect(t, received, "auth")
+	expect(t, received, "queued")
+
+	select {
+	case second := <-connects:
+		if second.id == first.id || second.id != conn.ID() || second.state != network.ConnOpened {
+			t.Fatalf("reconnect hook saw id %d state %d, want id %d state %d", second.id, second.state, conn.ID(), network.ConnOpened)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for reconnect hook")
+	}
+
+	if conn.State() != network.ConnOpened {
+		t.Fatalf("unexpected state after reconnect: %d", conn.State())
+	}
+}
+
+func expect(t *testing.T, received chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for %q", want)
+	}
+}
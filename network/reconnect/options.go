@@ -0,0 +1,72 @@
+package reconnect
+
+import (
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
+	"time"
+)
+
+const (
+	defaultMinBackoff  = time.Millisecond * 500
+	defaultMaxBackoff  = time.Second * 30
+	defaultJitter      = 0.2
+	defaultMaxAttempts = 0
+	defaultBufferSize  = 0
+)
+
+// ReconnectHandler 重连成功后、缓冲消息发送前的hook函数，可用于重新鉴权
+// 返回错误时放弃本次连接并继续重连
+type ReconnectHandler func(conn network.Conn) error
+
+type Option func(o *options)
+
+type options struct {
+	minBackoff       time.Duration    // 最小退避时间，默认500ms
+	maxBackoff       time.Duration    // 最大退避时间，默认30s
+	jitter           float64          // 退避抖动比例，默认0.2
+	maxAttempts      int              // 最大重连次数，默认0（不限制）
+	bufferSize       int              // 重连期间Push缓冲的消息数，默认0（立即失败）
+	reconnectHandler ReconnectHandler // 重连hook函数
+	logger           logger.Logger    // 日志器，默认使用全局日志器
+}
+
+func defaultOptions() *options {
+	return &options{
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		jitter:      defaultJitter,
+		maxAttempts: defaultMaxAttempts,
+		bufferSize:  defaultBufferSize,
+		logger:      logger.Default(),
+	}
+}
+
+// WithBackoff 设置指数退避的最小与最大时间
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithJitter 设置退避抖动比例，取值范围[0, 1]
+func WithJitter(jitter float64) Option {
+	return func(o *options) { o.jitter = jitter }
+}
+
+// WithMaxAttempts 设置最大重连次数，0为不限制
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(o *options) { o.maxAttempts = maxAttempts }
+}
+
+// WithBufferSize 设置重连期间Push缓冲的消息数，0为立即失败
+func WithBufferSize(bufferSize int) Option {
+	return func(o *options) { o.bufferSize = bufferSize }
+}
+
+// WithReconnectHandler 设置重连hook函数
+func WithReconnectHandler(handler ReconnectHandler) Option {
+	return func(o *options) { o.reconnectHandler = handler }
+}
+
+// WithLogger 设置日志器
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
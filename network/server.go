@@ -1,5 +1,9 @@
 package network
 
+import (
+	"context"
+)
+
 type (
 	StartHandler      func()
 	CloseHandler      func()
@@ -15,6 +19,8 @@ type Server interface {
 	Start() error
 	// Stop 关闭服务器
 	Stop() error
+	// Shutdown 优雅关闭服务器，停止接收新连接并等待写入队列排空，ctx到期后强制关闭剩余连接
+	Shutdown(ctx context.Context) error
 	// Protocol 协议
 	Protocol() string
 	// OnStart 监听服务器启动
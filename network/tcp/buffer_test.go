@@ -0,0 +1,80 @@
+package tcp_test
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"testing"
+	"time"
+)
+
+func TestServerConn_SendBuffer(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan *due.Message, 2)
+
+	client := tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- message.(*due.Message)
+	})
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	conn := <-connected
+
+	payload := bytes.Repeat([]byte("x"), 1024)
+
+	for route, send := range map[int32]func(message *due.Message) error{
+		1: func(message *due.Message) error {
+			buf, err := packer.PackBuffer(message)
+			if err != nil {
+				return err
+			}
+			return conn.SendBuffer(buf)
+		},
+		2: func(message *due.Message) error {
+			buf, err := packer.PackBuffer(message)
+			if err != nil {
+				return err
+			}
+			return conn.PushBuffer(buf)
+		},
+	} {
+		if err = send(&due.Message{Route: route, Buffer: payload}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case message := <-received:
+			if message.Route != route || !bytes.Equal(message.Buffer, payload) {
+				t.Fatalf("received route %d with %d bytes, want route %d with %d bytes", message.Route, len(message.Buffer), route, len(payload))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package tcp
 
 import (
+	"crypto/tls"
+	"github.com/cute-angelia/go-game-utils/buffer"
 	"github.com/cute-angelia/go-game-utils/network"
 	"net"
 	"sync/atomic"
@@ -39,7 +41,21 @@ func (c *client) Dial(addr ...string) (network.Conn, error) {
 		return nil, err
 	}
 
-	conn, err := net.DialTimeout(tcpAddr.Network(), tcpAddr.String(), c.opts.timeout)
+	var conn net.Conn
+
+	if c.opts.tlsConfig != nil {
+		config := c.opts.tlsConfig
+		if config.ServerName == "" {
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				config = config.Clone()
+				config.ServerName = host
+			}
+		}
+
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: c.opts.timeout}, tcpAddr.Network(), tcpAddr.String(), config)
+	} else {
+		conn, err = net.DialTimeout(tcpAddr.Network(), tcpAddr.String(), c.opts.timeout)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -66,3 +82,53 @@ func (c *client) OnDisconnect(handler network.DisconnectHandler) {
 func (c *client) OnReceive(handler network.ReceiveHandler) {
 	c.receiveHandler = handler
 }
+
+// 处理接收到的消息，依次经过入站拦截器
+func (c *client) receive(conn network.Conn, msg []byte) {
+	if len(c.opts.inbound) == 0 {
+		if c.receiveHandler != nil {
+			c.receiveHandler(conn, msg)
+		}
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	if err := network.Intercept(c.opts.inbound, frame, c.invokeReceive); err != nil {
+		c.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (c *client) invokeReceive(frame *network.Frame) error {
+	if c.receiveHandler != nil {
+		c.receiveHandler(frame.Conn(), frame.Data())
+	}
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (c *client) send(conn network.Conn, msg []byte, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	return network.Intercept(c.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (c *client) sendBuffer(conn network.Conn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return c.send(conn, msg, write)
+}
@@ -1,11 +1,13 @@
 package tcp
 
 import (
-	"errors"
+	"crypto/x509"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
 	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"github.com/cute-angelia/go-game-utils/utils/inet"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -14,6 +16,7 @@ import (
 
 type clientConn struct {
 	rw                sync.RWMutex
+	wmu               sync.Mutex    // 写入锁，TLS连接上多段缓冲区逐段写入，需保证同一帧不被其他写入交错
 	id                int64         // 连接ID
 	uid               int64         // 用户ID
 	conn              net.Conn      // TCP源连接
@@ -23,6 +26,7 @@ type clientConn struct {
 	done              chan struct{} // 写入完成信号
 	close             chan struct{} // 关闭信号
 	lastHeartbeatTime int64         // 上次心跳时间
+	network.Attrs                   // 连接属性
 }
 
 var _ network.Conn = &clientConn{}
@@ -72,7 +76,48 @@ func (c *clientConn) Unbind() {
 
 // Send 发送消息（同步）
 func (c *clientConn) Send(msg []byte) error {
+	return c.client.send(c, msg, c.send)
+}
+
+// 发送消息（同步）
+func (c *clientConn) send(msg []byte) error {
+	return c.syncWrite(chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（同步）
+func (c *clientConn) sendBuffer(buf buffer.Buffer) error {
+	return c.syncWrite(chWrite{typ: dataPacket, buf: buf})
+}
+
+// Push 发送消息（异步）
+func (c *clientConn) Push(msg []byte) error {
+	return c.client.send(c, msg, c.push)
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *clientConn) SendBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *clientConn) PushBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *clientConn) push(msg []byte) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（异步）
+func (c *clientConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, buf: buf})
+}
+
+// 同步写入连接，写入完成后释放缓冲区
+func (c *clientConn) syncWrite(w chWrite) error {
 	if err := c.checkState(); err != nil {
+		w.release()
 		return err
 	}
 
@@ -81,24 +126,29 @@ func (c *clientConn) Send(msg []byte) error {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return errors.New("ErrConnectionClosed")
+		w.release()
+		return errs.ErrConnectionClosed
 	}
 
-	_, err := conn.Write(msg)
+	c.wmu.Lock()
+	_, err := w.writeTo(conn)
+	c.wmu.Unlock()
+
 	return err
 }
 
-// Push 发送消息（异步）
-func (c *clientConn) Push(msg []byte) (err error) {
-	if err = c.checkState(); err != nil {
-		return
+// 异步写入队列
+func (c *clientConn) asyncWrite(ch chan chWrite, w chWrite) error {
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
 	}
 
 	c.rw.RLock()
-	c.chWrite <- chWrite{typ: dataPacket, msg: msg}
+	ch <- w
 	c.rw.RUnlock()
 
-	return
+	return nil
 }
 
 // State 获取连接状态
@@ -136,7 +186,7 @@ func (c *clientConn) LocalAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.LocalAddr(), nil
@@ -163,19 +213,36 @@ func (c *clientConn) RemoteAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.RemoteAddr(), nil
 }
 
+// PeerCertificate 获取对端TLS证书
+func (c *clientConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return network.PeerCertificate(conn), nil
+}
+
 // 检测连接状态
 func (c *clientConn) checkState() error {
 	switch network.ConnState(atomic.LoadInt32(&c.state)) {
 	case network.ConnHanged:
-		return errors.New("ErrConnectionHanged")
+		return errs.ErrConnectionHanged
 	case network.ConnClosed:
-		return errors.New("ErrConnectionClosed")
+		return errs.ErrConnectionClosed
 	default:
 		return nil
 	}
@@ -184,7 +251,7 @@ func (c *clientConn) checkState() error {
 // 优雅关闭
 func (c *clientConn) graceClose() error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
-		return errors.New("ErrConnectionNotOpened")
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.RLock()
@@ -194,7 +261,7 @@ func (c *clientConn) graceClose() error {
 	<-c.done
 
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-		return errors.New("ErrConnectionNotHanged")
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.Lock()
@@ -218,7 +285,7 @@ func (c *clientConn) graceClose() error {
 func (c *clientConn) forceClose() error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
 		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-			return errors.New("ErrConnectionClosed")
+			return errs.ErrConnectionClosed
 		}
 	}
 
@@ -241,61 +308,76 @@ func (c *clientConn) forceClose() error {
 
 // 读取消息
 func (c *clientConn) read() {
+	c.rw.RLock()
 	conn := c.conn
+	c.rw.RUnlock()
+
+	reader := buffer.NewConnReader(conn)
+	defer reader.Close()
 
 	for {
 		select {
 		case <-c.close:
 			return
 		default:
-			msg, err := c.client.opts.packer.ReadMessage(conn)
+			msg, release, err := readMessage(c.client.opts.packer, reader, c.client.opts.readLease)
 			if err != nil {
 				_ = c.forceClose()
 				return
 			}
 
-			if c.client.opts.heartbeatInterval > 0 {
-				atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
-			}
-
-			switch c.State() {
-			case network.ConnHanged:
-				continue
-			case network.ConnClosed:
+			ok := c.handle(msg)
+			release()
+			if !ok {
 				return
-			default:
-				// ignore
 			}
+		}
+	}
+}
 
-			isHeartbeat, err := c.client.opts.packer.CheckHeartbeat(msg)
-			if err != nil {
-				log.Printf("check heartbeat message error: %v", err)
-				continue
-			}
+// 处理读取到的消息，连接已关闭时返回false
+func (c *clientConn) handle(msg []byte) bool {
+	if c.client.opts.heartbeatInterval > 0 {
+		atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
+	}
 
-			// ignore heartbeat packet
-			if isHeartbeat {
-				continue
-			}
+	switch c.State() {
+	case network.ConnHanged:
+		return true
+	case network.ConnClosed:
+		return false
+	default:
+		// ignore
+	}
 
-			// ignore empty packet
-			if len(msg) == 0 {
-				continue
-			}
+	isHeartbeat, err := c.client.opts.packer.CheckHeartbeat(msg)
+	if err != nil {
+		c.log().Warn("check heartbeat message error", "error", err)
+		return true
+	}
 
-			if c.client.receiveHandler != nil {
-				c.client.receiveHandler(c, msg)
-			}
-		}
+	// ignore heartbeat packet
+	if isHeartbeat {
+		return true
 	}
+
+	// ignore empty packet
+	if len(msg) == 0 {
+		return true
+	}
+
+	c.client.receive(c, msg)
+
+	return true
 }
 
 // 写入消息
 func (c *clientConn) write() {
-	var (
-		conn   = c.conn
-		ticker *time.Ticker
-	)
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	var ticker *time.Ticker
 
 	if c.client.opts.heartbeatInterval > 0 {
 		ticker = time.NewTicker(c.client.opts.heartbeatInterval)
@@ -322,13 +404,17 @@ func (c *clientConn) write() {
 				return
 			}
 
-			if _, err := conn.Write(r.msg); err != nil {
-				log.Printf("write data message error: %v", err)
+			c.wmu.Lock()
+			_, err := r.writeTo(conn)
+			c.wmu.Unlock()
+
+			if err != nil {
+				c.log().Warn("write data message error", "error", err)
 			}
 		case <-ticker.C:
 			deadline := time.Now().Add(-2 * c.client.opts.heartbeatInterval).UnixNano()
 			if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
-				log.Printf("connection heartbeat timeout")
+				c.log().Info("connection heartbeat timeout")
 				_ = c.forceClose()
 				return
 			} else {
@@ -337,11 +423,15 @@ func (c *clientConn) write() {
 				}
 
 				if heartbeat, err := c.client.opts.packer.PackHeartbeat(); err != nil {
-					log.Printf("pack heartbeat message error: %v", err)
+					c.log().Error("pack heartbeat message error", "error", err)
 				} else {
 					// send heartbeat packet
-					if _, err := conn.Write(heartbeat); err != nil {
-						log.Printf("write heartbeat message error: %v", err)
+					c.wmu.Lock()
+					_, err = conn.Write(heartbeat)
+					c.wmu.Unlock()
+
+					if err != nil {
+						c.log().Warn("write heartbeat message error", "error", err)
 					}
 				}
 			}
@@ -353,3 +443,14 @@ func (c *clientConn) write() {
 func (c *clientConn) isClosed() bool {
 	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
 }
+
+// 获取附带连接信息的日志器
+func (c *clientConn) log() logger.Logger {
+	l := c.client.opts.logger.With("cid", c.id, "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
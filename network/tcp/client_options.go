@@ -1,6 +1,9 @@
 package tcp
 
 import (
+	"crypto/tls"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/packet"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"time"
@@ -20,8 +23,13 @@ type clientOptions struct {
 	addr              string        // 地址
 	timeout           time.Duration // 拨号超时时间，默认5s
 	heartbeatInterval time.Duration // 心跳间隔时间，默认10s
+	tlsConfig         *tls.Config   // TLS配置，为nil时不启用TLS
+	readLease         bool          // 是否租借读取消息，默认false
 
-	packer ipacket.Packer
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	packer   ipacket.Packer
 }
 
 func defaultClientOptions() *clientOptions {
@@ -29,6 +37,7 @@ func defaultClientOptions() *clientOptions {
 		addr:              defaultClientDialAddr,
 		timeout:           defaultClientDialTimeout,
 		heartbeatInterval: defaultClientHeartbeatInterval,
+		logger:            logger.Default(),
 		packer:            packet.GetDefaultPacker(defaultClientPackerName),
 	}
 }
@@ -53,3 +62,29 @@ func WithClientPacker(packer ipacket.Packer) ClientOption {
 		o.packer = packer
 	}
 }
+
+// WithClientTLSConfig 设置TLS配置，未设置ServerName时使用拨号地址的主机名
+func WithClientTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) { o.tlsConfig = config }
+}
+
+// WithClientReadLease 设置是否租借读取消息
+// 启用后打包器支持租借读取时消息直接引用读取器的内存块，接收消息hook函数返回后即归还，hook函数中不可持有消息引用
+func WithClientReadLease(enable bool) ClientOption {
+	return func(o *clientOptions) { o.readLease = enable }
+}
+
+// WithClientLogger 设置日志器
+func WithClientLogger(l logger.Logger) ClientOption {
+	return func(o *clientOptions) { o.logger = l }
+}
+
+// WithClientInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithClientInboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithClientOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithClientOutboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
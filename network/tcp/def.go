@@ -1,5 +1,12 @@
 package tcp
 
+import (
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"net"
+)
+
 const protocol = "tcp"
 
 const (
@@ -10,4 +17,64 @@ const (
 type chWrite struct {
 	typ int
 	msg []byte
+	buf buffer.Buffer
+}
+
+// 消息字节数
+func (w chWrite) len() int {
+	if w.buf != nil {
+		return w.buf.Len()
+	}
+
+	return len(w.msg)
+}
+
+// 消息字节，buf释放后不可再使用
+func (w chWrite) bytes() []byte {
+	if w.buf != nil {
+		return w.buf.Bytes()
+	}
+
+	return w.msg
+}
+
+// 释放缓冲区
+func (w chWrite) release() {
+	if w.buf != nil {
+		w.buf.Release()
+	}
+}
+
+// 写入连接，写入完成后释放缓冲区
+func (w chWrite) writeTo(conn net.Conn) (int64, error) {
+	defer w.release()
+
+	if w.buf == nil {
+		n, err := conn.Write(w.msg)
+		return int64(n), err
+	}
+
+	bufs := network.NetBuffers(w.buf)
+
+	return bufs.WriteTo(conn)
+}
+
+func noRelease() {}
+
+// 读取消息，启用租借读取且打包器支持时消息引用读取器内存，须在处理完毕后调用release归还
+func readMessage(packer ipacket.Packer, reader *buffer.ConnReader, lease bool) (msg []byte, release func(), err error) {
+	if lease {
+		if lr, ok := packer.(ipacket.LeaseReader); ok {
+			l, err := lr.ReadLease(reader)
+			if err != nil {
+				return nil, noRelease, err
+			}
+
+			return l.Bytes(), l.Release, nil
+		}
+	}
+
+	msg, err = packer.ReadMessage(reader)
+
+	return msg, noRelease, err
 }
@@ -0,0 +1,109 @@
+package tcp_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"testing"
+	"time"
+)
+
+func TestServer_Interceptors(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	// 入站：丢弃路由为0的消息，其余路由加100
+	inbound := func(frame *network.Frame, next network.Invoker) error {
+		message, err := frame.Message()
+		if err != nil {
+			return err
+		}
+
+		msg := message.(*due.Message)
+		if msg.Route == 0 {
+			return nil
+		}
+
+		if err = frame.SetMessage(&due.Message{Route: msg.Route + 100, Buffer: msg.Buffer}); err != nil {
+			return err
+		}
+
+		return next(frame)
+	}
+
+	// 出站：回写消息的路由加1000
+	outbound := func(frame *network.Frame, next network.Invoker) error {
+		message, err := frame.Message()
+		if err != nil {
+			return err
+		}
+
+		msg := message.(*due.Message)
+		if err = frame.SetMessage(&due.Message{Route: msg.Route + 1000, Buffer: msg.Buffer}); err != nil {
+			return err
+		}
+
+		return next(frame)
+	}
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerInboundInterceptors(inbound),
+		tcp.WithServerOutboundInterceptors(outbound),
+	)
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		if err := conn.Push(msg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan int32, 2)
+
+	client := tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- message.(*due.Message).Route
+	})
+
+	conn, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, route := range []int32{0, 1} {
+		msg, err := packer.PackMessage(&due.Message{Route: route, Buffer: []byte("hello")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = conn.Push(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case route := <-received:
+		if route != 1101 {
+			t.Fatalf("route = %d, want 1101", route)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for echo")
+	}
+
+	select {
+	case route := <-received:
+		t.Fatalf("unexpected message with route %d", route)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
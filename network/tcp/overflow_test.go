@@ -0,0 +1,126 @@
+package tcp_test
+
+import (
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_OverflowPolicy(t *testing.T) {
+	cases := []struct {
+		policy  network.OverflowPolicy
+		timeout time.Duration
+	}{
+		{policy: network.OverflowBlock, timeout: 10 * time.Millisecond},
+		{policy: network.OverflowDropNewest},
+		{policy: network.OverflowDropOldest},
+		{policy: network.OverflowDisconnect},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			addr := listenAddr(t)
+
+			var overflows int64
+
+			server := tcp.NewServer(
+				tcp.WithServerListenAddr(addr),
+				tcp.WithServerPacker(due.NewPacker()),
+				tcp.WithServerHeartbeatInterval(0),
+				tcp.WithServerWriteQueueSize(4),
+				tcp.WithServerOverflowPolicy(tc.policy, tc.timeout),
+				tcp.WithServerOverflowHandler(func(conn network.Conn, msg []byte, policy network.OverflowPolicy) {
+					if policy != tc.policy {
+						t.Errorf("policy = %s, want %s", policy, tc.policy)
+					}
+					atomic.AddInt64(&overflows, 1)
+				}),
+			)
+
+			connected := make(chan network.Conn, 1)
+			server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+			if err := server.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer server.Stop()
+
+			// 客户端从不读取，服务端写入最终会阻塞
+			client, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			conn := <-connected
+			msg := make([]byte, 64*1024)
+
+			var failures int64
+
+			deadline := time.Now().Add(5 * time.Second)
+			for failures == 0 && time.Now().Before(deadline) {
+				err = conn.Push(msg)
+				switch {
+				case err == nil:
+				case errors.Is(err, errs.ErrWriteQueueFull):
+					failures++
+				default:
+					t.Fatalf("unexpected push error: %v", err)
+				}
+
+				if tc.policy == network.OverflowDropOldest && atomic.LoadInt64(&overflows) > 0 {
+					break
+				}
+			}
+
+			if atomic.LoadInt64(&overflows) == 0 {
+				t.Fatal("overflow handler was not invoked")
+			}
+
+			switch tc.policy {
+			case network.OverflowDropOldest:
+				if failures != 0 {
+					t.Fatalf("drop oldest must accept the newest message, got %d failures", failures)
+				}
+			case network.OverflowDisconnect:
+				if conn.State() != network.ConnClosed {
+					t.Fatalf("state = %v, want closed", conn.State())
+				}
+			default:
+				if failures == 0 {
+					t.Fatal("expected ErrWriteQueueFull")
+				}
+			}
+		})
+	}
+}
+
+func TestServer_InvalidWriteQueueSize(t *testing.T) {
+	for name, opts := range map[string][]tcp.ServerOption{
+		"Negative":         {tcp.WithServerWriteQueueSize(-1)},
+		"UnbufferedOldest": {tcp.WithServerWriteQueueSize(0), tcp.WithServerOverflowPolicy(network.OverflowDropOldest)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts = append(opts, tcp.WithServerListenAddr(listenAddr(t)))
+
+			var configErr *network.ConfigError
+			if _, err := tcp.NewServerE(opts...); !errors.As(err, &configErr) {
+				t.Fatalf("expected config error, got %v", err)
+			}
+
+			if err := tcp.NewServer(opts...).Start(); !errors.As(err, &configErr) {
+				t.Fatalf("expected start to return config error, got %v", err)
+			}
+		})
+	}
+
+	if _, err := tcp.NewServerE(tcp.WithServerWriteQueueSize(0), tcp.WithServerOverflowPolicy(network.OverflowBlock)); err != nil {
+		t.Fatalf("unbuffered queue with block policy should be valid, got %v", err)
+	}
+}
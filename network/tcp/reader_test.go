@@ -0,0 +1,185 @@
+package tcp_test
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerConn_ReadStraddle(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker(due.WithBufferBytes(32 * 1024))
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+	)
+
+	received := make(chan *due.Message, 8)
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- message.(*due.Message)
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	messages := []*due.Message{
+		{Route: 1, Buffer: []byte("hello")},
+		{Route: 2, Buffer: bytes.Repeat([]byte("x"), 20*1024)},
+		{Route: 3, Buffer: []byte("world")},
+	}
+
+	var stream []byte
+	for _, message := range messages {
+		data, err := packer.PackMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, data...)
+	}
+
+	// 小块写入使帧跨越多次读取
+	go func() {
+		for i := 0; i < len(stream); i += 3 {
+			end := min(i+3, len(stream))
+			if _, err := conn.Write(stream[i:end]); err != nil {
+				return
+			}
+			if i < 64 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	for _, want := range messages {
+		select {
+		case message := <-received:
+			if message.Route != want.Route || !bytes.Equal(message.Buffer, want.Buffer) {
+				t.Fatalf("received route %d with %d bytes, want route %d with %d bytes", message.Route, len(message.Buffer), want.Route, len(want.Buffer))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func TestServerConn_ReadFrameTooLarge(t *testing.T) {
+	addr := listenAddr(t)
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(due.NewPacker(due.WithMaxFrameBytes(1024))),
+	)
+
+	disconnected := make(chan struct{}, 1)
+	server.OnDisconnect(func(conn network.Conn) { disconnected <- struct{}{} })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte{0x7f, 0xff, 0xff, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not close the connection with an oversized frame")
+	}
+}
+
+func TestServerConn_ReadLease(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker(due.WithBufferBytes(32 * 1024))
+	before := buffer.BlocksInUse()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerReadLease(true),
+	)
+
+	// 消息仅在hook函数返回前有效，需持有时须拷贝
+	received := make(chan []byte, 8)
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- bytes.Clone(message.(*due.Message).Buffer)
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var payloads [][]byte
+	for i := 0; i < 64; i++ {
+		payload := bytes.Repeat([]byte{byte('a' + i%26)}, 512+i*37)
+
+		data, err := packer.PackMessage(&due.Message{Route: 1, Seq: int32(i), Buffer: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	for i, want := range payloads {
+		select {
+		case got := <-received:
+			if !bytes.Equal(got, want) {
+				t.Fatalf("frame %d received %d bytes, want %d bytes", i, len(got), len(want))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+
+	_ = server.Stop()
+
+	// 租借的内存块在hook函数返回后归还，读取器关闭后全部回到池中
+	deadline := time.Now().Add(5 * time.Second)
+	for buffer.BlocksInUse() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d blocks not returned to pool", buffer.BlocksInUse()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
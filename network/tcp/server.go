@@ -1,9 +1,14 @@
 package tcp
 
 import (
+	"context"
+	"crypto/tls"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/metrics"
 	"github.com/cute-angelia/go-game-utils/network"
-	"log"
+	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -16,18 +21,45 @@ type server struct {
 	connectHandler    network.ConnectHandler    // 连接打开hook函数
 	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
 	receiveHandler    network.ReceiveHandler    // 接收消息hook函数
+	labels            []string                  // 指标标签
+	unregister        []func()                  // 仪表盘注销函数
+	rw                sync.Mutex                // 握手连接锁
+	handshakes        map[net.Conn]struct{}     // 正在进行TLS握手的连接，服务器关闭后为nil
+	err               error                     // 配置错误，启动时返回
 }
 
 var _ network.Server = &server{}
 
+// NewServer 创建服务器，配置不合法时启动服务器返回*network.ConfigError
 func NewServer(opts ...ServerOption) network.Server {
 	o := defaultServerOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	return newServer(o)
+}
+
+// NewServerE 创建服务器，配置不合法时返回*network.ConfigError
+func NewServerE(opts ...ServerOption) (network.Server, error) {
+	o := defaultServerOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := newServer(o)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s, nil
+}
+
+func newServer(o *serverOptions) *server {
 	s := &server{}
 	s.opts = o
+	s.err = o.validate()
+	s.labels = []string{"protocol", protocol, "addr", o.addr}
 	s.connMgr = newServerConnMgr(s)
 
 	return s
@@ -40,10 +72,16 @@ func (s *server) Addr() string {
 
 // Start 启动服务器
 func (s *server) Start() error {
+	if s.err != nil {
+		return s.err
+	}
+
 	if err := s.init(); err != nil {
 		return err
 	}
 
+	s.register()
+
 	if s.startHandler != nil {
 		s.startHandler()
 	}
@@ -59,6 +97,10 @@ func (s *server) Stop() error {
 		return err
 	}
 
+	s.closeHandshakes()
+
+	s.deregister()
+
 	s.connMgr.close()
 
 	if s.stopHandler != nil {
@@ -68,6 +110,35 @@ func (s *server) Stop() error {
 	return nil
 }
 
+// Shutdown 优雅关闭服务器
+func (s *server) Shutdown(ctx context.Context) error {
+	if err := s.listener.Close(); err != nil {
+		return err
+	}
+
+	s.closeHandshakes()
+
+	s.deregister()
+
+	var goingAway []byte
+	if s.opts.goingAway != nil {
+		msg, err := s.opts.packer.PackMessage(s.opts.goingAway)
+		if err != nil {
+			s.opts.logger.Error("pack going away message error", "error", err)
+		} else {
+			goingAway = msg
+		}
+	}
+
+	err := s.connMgr.shutdown(ctx, goingAway)
+
+	if s.stopHandler != nil {
+		s.stopHandler()
+	}
+
+	return err
+}
+
 // Protocol 协议
 func (s *server) Protocol() string {
 	return protocol
@@ -110,7 +181,15 @@ func (s *server) init() error {
 		return err
 	}
 
-	s.listener = ln
+	s.rw.Lock()
+	s.handshakes = make(map[net.Conn]struct{})
+	s.rw.Unlock()
+
+	if s.opts.tlsConfig != nil {
+		s.listener = tls.NewListener(ln, s.opts.tlsConfig)
+	} else {
+		s.listener = ln
+	}
 
 	return nil
 }
@@ -132,20 +211,191 @@ func (s *server) serve() {
 					tempDelay = max
 				}
 
-				log.Printf("tcp accept error: %v; retrying in %v", err, tempDelay)
+				s.opts.logger.Warn("tcp accept error", "error", err, "retry", tempDelay)
 				time.Sleep(tempDelay)
 				continue
 			}
 
-			log.Printf("tcp accept error: %v", err)
+			s.opts.logger.Warn("tcp accept error", "error", err)
 			return
 		}
 
 		tempDelay = 0
 
+		// TLS握手在独立协程中完成，避免慢速客户端阻塞监听
+		if tlsConn, ok := conn.(*tls.Conn); ok {
+			if s.track(tlsConn) {
+				icall.Go(func() { s.handshake(tlsConn) })
+			} else {
+				_ = conn.Close()
+			}
+			continue
+		}
+
 		if err = s.connMgr.allocate(conn); err != nil {
-			log.Printf("connection allocate error: %v", err)
+			s.opts.logger.Warn("connection allocate error", "error", err)
 			_ = conn.Close()
 		}
 	}
 }
+
+// 完成TLS握手后分配连接
+func (s *server) handshake(conn *tls.Conn) {
+	ctx := context.Background()
+	if s.opts.handshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.handshakeTimeout)
+		defer cancel()
+	}
+
+	err := conn.HandshakeContext(ctx)
+
+	s.rw.Lock()
+	defer s.rw.Unlock()
+
+	if s.handshakes == nil {
+		_ = conn.Close()
+		return
+	}
+
+	delete(s.handshakes, conn)
+
+	if err != nil {
+		s.opts.logger.Warn("tls handshake error", "remote", conn.RemoteAddr().String(), "error", err)
+		_ = conn.Close()
+		return
+	}
+
+	if err = s.connMgr.allocate(conn); err != nil {
+		s.opts.logger.Warn("connection allocate error", "error", err)
+		_ = conn.Close()
+	}
+}
+
+// 记录正在进行TLS握手的连接，服务器已关闭时返回false
+func (s *server) track(conn net.Conn) bool {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+
+	if s.handshakes == nil {
+		return false
+	}
+
+	s.handshakes[conn] = struct{}{}
+
+	return true
+}
+
+// 关闭所有正在进行TLS握手的连接
+func (s *server) closeHandshakes() {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+
+	for conn := range s.handshakes {
+		_ = conn.Close()
+	}
+
+	s.handshakes = nil
+}
+
+// 处理接收到的消息，依次经过入站拦截器
+func (s *server) receive(conn network.Conn, msg []byte) {
+	if len(s.opts.inbound) == 0 {
+		s.handle(conn, msg)
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, s.opts.packer)
+
+	if err := network.Intercept(s.opts.inbound, frame, s.invokeReceive); err != nil {
+		s.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (s *server) invokeReceive(frame *network.Frame) error {
+	s.handle(frame.Conn(), frame.Data())
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (s *server) send(conn network.Conn, msg []byte, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, s.opts.packer)
+
+	return network.Intercept(s.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 调用接收消息hook函数并统计耗时
+func (s *server) handle(conn network.Conn, msg []byte) {
+	if s.receiveHandler == nil {
+		return
+	}
+
+	start := time.Now()
+	s.receiveHandler(conn, msg)
+	s.opts.metrics.Observe(metrics.HandlerLatency, time.Since(start).Seconds(), s.labels...)
+}
+
+// 注册仪表盘
+func (s *server) register() {
+	s.unregister = append(s.unregister,
+		s.opts.metrics.Gauge(metrics.ConnectionsActive, s.connMgr.active, s.labels...),
+		s.opts.metrics.Gauge(metrics.WriteQueueDepth, s.connMgr.queued, s.labels...),
+	)
+}
+
+// 注销仪表盘
+func (s *server) deregister() {
+	for _, unregister := range s.unregister {
+		unregister()
+	}
+
+	s.unregister = nil
+}
+
+// 统计读取的帧
+func (s *server) countIn(n int) {
+	s.opts.metrics.Add(metrics.FramesIn, 1, s.labels...)
+	s.opts.metrics.Add(metrics.BytesIn, float64(n), s.labels...)
+}
+
+// 统计写入的帧
+func (s *server) countOut(frames, n int) {
+	s.opts.metrics.Add(metrics.FramesOut, float64(frames), s.labels...)
+	s.opts.metrics.Add(metrics.BytesOut, float64(n), s.labels...)
+}
+
+// 统计写入队列溢出并调用溢出hook函数
+func (s *server) overflow(conn network.Conn, dropped []chWrite) {
+	policy := string(s.opts.overflowPolicy)
+	labels := append(append(make([]string, 0, len(s.labels)+2), s.labels...), "policy", policy)
+
+	s.opts.metrics.Add(metrics.WriteQueueOverflows, float64(len(dropped)), labels...)
+
+	for _, w := range dropped {
+		if s.opts.overflowHandler != nil {
+			s.opts.overflowHandler(conn, w.bytes(), s.opts.overflowPolicy)
+		}
+
+		w.release()
+	}
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (s *server) sendBuffer(conn network.Conn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return s.send(conn, msg, write)
+}
@@ -0,0 +1,86 @@
+package tcp_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 对比逐条写入与合并写入在大量小包下的吞吐
+func BenchmarkServer_Push(b *testing.B) {
+	for _, batchBytes := range []int{0, 64 * 1024} {
+		b.Run("batch="+strconv.Itoa(batchBytes), func(b *testing.B) {
+			benchmarkPush(b, batchBytes)
+		})
+	}
+}
+
+func benchmarkPush(b *testing.B, batchBytes int) {
+	addr := listenAddr(b)
+	packer := due.NewPacker()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerHeartbeatInterval(0),
+		tcp.WithServerWriteBatchBytes(batchBytes),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer server.Stop()
+
+	client, err := net.Dial("tcp", addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	var received int64
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := client.Read(buf)
+			atomic.AddInt64(&received, int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	conn := <-connected
+
+	msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: make([]byte, 64)})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(msg)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err = conn.Push(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	total := int64(b.N * len(msg))
+	deadline := time.Now().Add(30 * time.Second)
+	for atomic.LoadInt64(&received) < total {
+		if time.Now().After(deadline) {
+			b.Fatalf("received %d of %d bytes", atomic.LoadInt64(&received), total)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
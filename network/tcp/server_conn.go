@@ -1,11 +1,17 @@
 package tcp
 
 import (
+	"context"
+	"crypto/x509"
 	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
 	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/writeq"
 	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"github.com/cute-angelia/go-game-utils/utils/inet"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -18,18 +24,22 @@ type serverConn struct {
 	state             int32          // 连接状态
 	connMgr           *serverConnMgr // 连接管理
 	rw                sync.RWMutex   // 读写锁
+	wmu               sync.Mutex     // 写入锁，TLS连接上多段缓冲区逐段写入，需保证同一帧不被其他写入交错
 	conn              net.Conn       // TCP源连接
 	chWrite           chan chWrite   // 写入队列
+	batch             net.Buffers    // 批量写入缓存，仅由写入协程使用
+	items             []chWrite      // 批量写入的消息，仅由写入协程使用
 	done              chan struct{}  // 写入完成信号
 	close             chan struct{}  // 关闭信号
 	lastHeartbeatTime int64          // 上次心跳时间
+	network.Attrs                    // 连接属性
 }
 
 var _ network.Conn = &serverConn{}
 
 // ID 获取连接ID
 func (c *serverConn) ID() int64 {
-	return c.id
+	return atomic.LoadInt64(&c.id)
 }
 
 // UID 获取用户ID
@@ -48,35 +58,54 @@ func (c *serverConn) Unbind() {
 }
 
 // Send 发送消息（同步）
-func (c *serverConn) Send(msg []byte) (err error) {
-	if err = c.checkState(); err != nil {
-		return
-	}
-
-	c.rw.RLock()
-	conn := c.conn
-	c.rw.RUnlock()
+func (c *serverConn) Send(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.send)
+}
 
-	if conn == nil {
-		return errors.New("ErrConnectionClosed")
-	}
+// 发送消息（同步）
+func (c *serverConn) send(msg []byte) error {
+	return c.syncWrite(chWrite{typ: dataPacket, msg: msg})
+}
 
-	_, err = conn.Write(msg)
-	return
+// 发送缓冲区（同步）
+func (c *serverConn) sendBuffer(buf buffer.Buffer) error {
+	return c.syncWrite(chWrite{typ: dataPacket, buf: buf})
 }
 
 // Push 发送消息（异步）
-func (c *serverConn) Push(msg []byte) (err error) {
-	c.rw.RLock()
-	defer c.rw.RUnlock()
+func (c *serverConn) Push(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.push)
+}
 
-	if err = c.checkState(); err != nil {
-		return
+// PushID 发送消息（异步），连接已被复用为其他连接ID时返回errs.ErrConnectionClosed
+func (c *serverConn) PushID(cid int64, msg []byte) error {
+	if c.ID() != cid {
+		return errs.ErrConnectionClosed
 	}
 
-	c.chWrite <- chWrite{typ: dataPacket, msg: msg}
+	return c.connMgr.server.send(c, msg, func(msg []byte) error {
+		return c.asyncWriteID(cid, c.chWrite, chWrite{typ: dataPacket, msg: msg})
+	})
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *serverConn) SendBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *serverConn) PushBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *serverConn) push(msg []byte) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, msg: msg})
+}
 
-	return
+// 发送缓冲区（异步）
+func (c *serverConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chWrite, chWrite{typ: dataPacket, buf: buf})
 }
 
 // State 获取连接状态
@@ -114,7 +143,7 @@ func (c *serverConn) LocalAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.LocalAddr(), nil
@@ -141,19 +170,36 @@ func (c *serverConn) RemoteAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.RemoteAddr(), nil
 }
 
+// PeerCertificate 获取对端TLS证书
+func (c *serverConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return network.PeerCertificate(conn), nil
+}
+
 // 检测连接状态
 func (c *serverConn) checkState() error {
 	switch network.ConnState(atomic.LoadInt32(&c.state)) {
 	case network.ConnHanged:
-		return errors.New("ErrConnectionHanged")
+		return errs.ErrConnectionHanged
 	case network.ConnClosed:
-		return errors.New("ErrConnectionClosed")
+		return errs.ErrConnectionClosed
 	default:
 		return nil
 	}
@@ -161,10 +207,13 @@ func (c *serverConn) checkState() error {
 
 // 初始化连接
 func (c *serverConn) init(cm *serverConnMgr, id int64, conn net.Conn) {
-	c.id = id
+	// 持有写锁更新连接ID，按连接ID推送时持有读锁校验，连接被复用后不会收到推送
+	c.rw.Lock()
+	atomic.StoreInt64(&c.id, id)
+	c.rw.Unlock()
 	c.conn = conn
 	c.connMgr = cm
-	c.chWrite = make(chan chWrite, 4096)
+	c.chWrite = make(chan chWrite, cm.server.opts.writeQueueSize)
 	c.done = make(chan struct{})
 	c.close = make(chan struct{})
 	c.lastHeartbeatTime = time.Now().UnixNano()
@@ -183,17 +232,21 @@ func (c *serverConn) init(cm *serverConnMgr, id int64, conn net.Conn) {
 // 优雅关闭
 func (c *serverConn) graceClose(isNeedRecycle bool) error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
-		return errors.New("ErrConnectionNotOpened")
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.RLock()
+	if c.isClosed() {
+		c.rw.RUnlock()
+		return errs.ErrConnectionClosed
+	}
 	c.chWrite <- chWrite{typ: closeSig}
 	c.rw.RUnlock()
 
 	<-c.done
 
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-		return errors.New("ErrConnectionNotHanged")
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.Lock()
@@ -206,22 +259,59 @@ func (c *serverConn) graceClose(isNeedRecycle bool) error {
 
 	err := conn.Close()
 
+	if c.connMgr.server.disconnectHandler != nil {
+		c.connMgr.server.disconnectHandler(c)
+	}
+
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
 	if isNeedRecycle {
 		c.connMgr.recycle(conn)
 	}
 
-	if c.connMgr.server.disconnectHandler != nil {
-		c.connMgr.server.disconnectHandler(c)
+	return err
+}
+
+// 优雅关闭，先下发关闭消息并排空写入队列，ctx到期后强制关闭
+func (c *serverConn) shutdown(ctx context.Context, goingAway []byte) {
+	if goingAway != nil {
+		c.rw.RLock()
+		if c.checkState() == nil {
+			select {
+			case c.chWrite <- chWrite{typ: dataPacket, msg: goingAway}:
+			default:
+			}
+		}
+		c.rw.RUnlock()
 	}
 
-	return err
+	done := make(chan struct{})
+
+	icall.Go(func() {
+		_ = c.graceClose(true)
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.rw.RLock()
+		conn := c.conn
+		c.rw.RUnlock()
+
+		// 中断阻塞中的写入
+		if conn != nil {
+			_ = conn.SetDeadline(time.Now())
+		}
+
+		_ = c.forceClose(true)
+	}
 }
 
 // 强制关闭
 func (c *serverConn) forceClose(isNeedRecycle bool) error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
 		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-			return errors.New("ErrConnectionClosed")
+			return errs.ErrConnectionClosed
 		}
 	}
 
@@ -235,84 +325,114 @@ func (c *serverConn) forceClose(isNeedRecycle bool) error {
 
 	err := conn.Close()
 
-	if isNeedRecycle {
-		c.connMgr.recycle(conn)
-	}
-
 	if c.connMgr.server.disconnectHandler != nil {
 		c.connMgr.server.disconnectHandler(c)
 	}
 
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
+	if isNeedRecycle {
+		c.connMgr.recycle(conn)
+	}
+
 	return err
 }
 
 // 读取消息
 func (c *serverConn) read() {
+	c.rw.RLock()
 	conn := c.conn
+	c.rw.RUnlock()
+
+	reader := buffer.NewConnReader(conn)
+	defer reader.Close()
 
 	for {
 		select {
 		case <-c.close:
 			return
 		default:
-			msg, err := c.connMgr.server.opts.packer.ReadMessage(conn)
+			msg, release, err := readMessage(c.connMgr.server.opts.packer, reader, c.connMgr.server.opts.readLease)
 			if err != nil {
+				// 数据帧长度不合法时无法继续解析后续数据，关闭连接
+				var sizeErr *errs.SizeError
+				if errors.As(err, &sizeErr) {
+					c.log().Warn("read message error", "error", err)
+				}
+
 				_ = c.forceClose(true)
 				return
 			}
 
-			if c.connMgr.server.opts.heartbeatInterval > 0 {
-				atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
-			}
-
-			switch c.State() {
-			case network.ConnHanged:
-				continue
-			case network.ConnClosed:
+			ok := c.handle(conn, msg)
+			release()
+			if !ok {
 				return
-			default:
-				// ignore
 			}
+		}
+	}
+}
 
-			isHeartbeat, err := c.connMgr.server.opts.packer.CheckHeartbeat(msg)
-			if err != nil {
-				log.Printf("check heartbeat message error: %v", err)
-				continue
-			}
+// 处理读取到的消息，连接已关闭时返回false
+func (c *serverConn) handle(conn net.Conn, msg []byte) bool {
+	c.connMgr.server.countIn(len(msg))
 
-			// ignore heartbeat packet
-			if isHeartbeat {
-				// responsive heartbeat
-				if c.connMgr.server.opts.heartbeatMechanism == RespHeartbeat {
-					if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
-						log.Printf("pack heartbeat message error: %v", err)
-					} else {
-						if _, err = conn.Write(heartbeat); err != nil {
-							log.Printf("write heartbeat message error: %v", err)
-						}
-					}
-				}
-				continue
-			}
+	if c.connMgr.server.opts.heartbeatInterval > 0 {
+		atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
+	}
 
-			// ignore empty packet
-			if len(msg) == 0 {
-				continue
-			}
+	switch c.State() {
+	case network.ConnHanged:
+		return true
+	case network.ConnClosed:
+		return false
+	default:
+		// ignore
+	}
 
-			if c.connMgr.server.receiveHandler != nil {
-				c.connMgr.server.receiveHandler(c, msg)
+	isHeartbeat, err := c.connMgr.server.opts.packer.CheckHeartbeat(msg)
+	if err != nil {
+		c.log().Warn("check heartbeat message error", "error", err)
+		return true
+	}
+
+	// ignore heartbeat packet
+	if isHeartbeat {
+		// responsive heartbeat
+		if c.connMgr.server.opts.heartbeatMechanism == RespHeartbeat {
+			if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
+				c.log().Error("pack heartbeat message error", "error", err)
+			} else {
+				c.wmu.Lock()
+				_, err = conn.Write(heartbeat)
+				c.wmu.Unlock()
+
+				if err != nil {
+					c.log().Warn("write heartbeat message error", "error", err)
+				} else {
+					c.connMgr.server.countOut(1, len(heartbeat))
+				}
 			}
 		}
+		return true
+	}
+
+	// ignore empty packet
+	if len(msg) == 0 {
+		return true
 	}
+
+	c.connMgr.server.receive(c, msg)
+
+	return true
 }
 
 // 写入消息
 func (c *serverConn) write() {
-	var (
-		conn   = c.conn
-		ticker *time.Ticker
-	)
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	var ticker *time.Ticker
 
 	if c.connMgr.server.opts.heartbeatInterval > 0 {
 		ticker = time.NewTicker(c.connMgr.server.opts.heartbeatInterval)
@@ -329,9 +449,7 @@ func (c *serverConn) write() {
 			}
 
 			if r.typ == closeSig {
-				c.rw.RLock()
-				c.done <- struct{}{}
-				c.rw.RUnlock()
+				c.signalDone()
 				return
 			}
 
@@ -339,13 +457,20 @@ func (c *serverConn) write() {
 				return
 			}
 
-			if _, err := conn.Write(r.msg); err != nil {
-				log.Printf("write data message error: %v", err)
+			isCloseSig, ok := c.flush(conn, r)
+			if !ok {
+				return
+			}
+
+			if isCloseSig {
+				c.signalDone()
+				return
 			}
 		case <-ticker.C:
 			deadline := time.Now().Add(-2 * c.connMgr.server.opts.heartbeatInterval).UnixNano()
 			if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
-				log.Printf("connection heartbeat timeout, cid: %d", c.id)
+				c.log().Info("connection heartbeat timeout")
+				c.connMgr.server.opts.metrics.Add(metrics.HeartbeatTimeouts, 1, c.connMgr.server.labels...)
 				_ = c.forceClose(true)
 				return
 			} else {
@@ -355,11 +480,17 @@ func (c *serverConn) write() {
 					}
 
 					if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
-						log.Printf("pack heartbeat message error: %v", err)
+						c.log().Error("pack heartbeat message error", "error", err)
 					} else {
 						// send heartbeat packet
-						if _, err = conn.Write(heartbeat); err != nil {
-							log.Printf("write heartbeat message error: %v", err)
+						c.wmu.Lock()
+						_, err = conn.Write(heartbeat)
+						c.wmu.Unlock()
+
+						if err != nil {
+							c.log().Warn("write heartbeat message error", "error", err)
+						} else {
+							c.connMgr.server.countOut(1, len(heartbeat))
 						}
 					}
 				}
@@ -368,7 +499,181 @@ func (c *serverConn) write() {
 	}
 }
 
+// 合并写入队列中已就绪的消息并批量写入，遇到关闭信号时停止合并；写入队列关闭时返回ok为false
+func (c *serverConn) flush(conn net.Conn, first chWrite) (isCloseSig bool, ok bool) {
+	var (
+		opts  = c.connMgr.server.opts
+		items = append(c.items[:0], first)
+		size  = first.len()
+		timer *time.Timer
+	)
+
+	// 写入完成后释放缓冲区，并置空元素以免继续持有消息
+	defer func() {
+		for i := range items {
+			items[i].release()
+			items[i] = chWrite{}
+		}
+		c.items = items[:0]
+	}()
+
+loop:
+	for size < opts.writeBatchBytes {
+		var r chWrite
+
+		select {
+		case r, ok = <-c.chWrite:
+		default:
+			if opts.writeFlushLatency <= 0 {
+				break loop
+			}
+
+			if timer == nil {
+				timer = time.NewTimer(opts.writeFlushLatency)
+				defer timer.Stop()
+			}
+
+			select {
+			case r, ok = <-c.chWrite:
+			case <-timer.C:
+				break loop
+			}
+		}
+
+		if !ok {
+			return false, false
+		}
+
+		if r.typ == closeSig {
+			isCloseSig = true
+			break loop
+		}
+
+		items = append(items, r)
+		size += r.len()
+	}
+
+	bufs := c.batch[:0]
+	for _, item := range items {
+		if item.buf != nil {
+			bufs = append(bufs, network.NetBuffers(item.buf)...)
+		} else {
+			bufs = append(bufs, item.msg)
+		}
+	}
+
+	// WriteTo会消费bufs，写入完成后置空的元素不再持有消息
+	c.batch = bufs
+
+	c.wmu.Lock()
+	_, err := bufs.WriteTo(conn)
+	c.wmu.Unlock()
+
+	if err != nil {
+		c.log().Warn("write data message error", "error", err)
+	} else {
+		c.connMgr.server.countOut(len(items), size)
+	}
+
+	return isCloseSig, true
+}
+
+// 通知优雅关闭写入队列已排空
+func (c *serverConn) signalDone() {
+	c.rw.RLock()
+	if !c.isClosed() {
+		c.done <- struct{}{}
+	}
+	c.rw.RUnlock()
+}
+
+// 同步写入连接，写入完成后释放缓冲区
+func (c *serverConn) syncWrite(w chWrite) error {
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+
+	size := w.len()
+
+	c.wmu.Lock()
+	_, err := w.writeTo(conn)
+	c.wmu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	c.connMgr.server.countOut(1, size)
+
+	return nil
+}
+
+// 异步写入队列，写入队列已满时按溢出策略处理
+func (c *serverConn) asyncWrite(ch chan chWrite, w chWrite) error {
+	return c.asyncWriteID(c.ID(), ch, w)
+}
+
+// 异步写入连接ID为cid的连接的队列，连接已被复用时返回errs.ErrConnectionClosed
+func (c *serverConn) asyncWriteID(cid int64, ch chan chWrite, w chWrite) error {
+	c.rw.RLock()
+	if atomic.LoadInt64(&c.id) != cid {
+		c.rw.RUnlock()
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+	if err := c.checkState(); err != nil {
+		c.rw.RUnlock()
+		w.release()
+		return err
+	}
+	dropped, err := c.enqueue(ch, w)
+	c.rw.RUnlock()
+
+	return c.overflow(dropped, err)
+}
+
+// 写入队列，队列已满时按溢出策略处理，返回被丢弃的消息；调用方需持有读锁
+func (c *serverConn) enqueue(ch chan chWrite, w chWrite) (dropped []chWrite, err error) {
+	opts := c.connMgr.server.opts
+
+	return writeq.Enqueue(ch, w, opts.overflowPolicy, opts.overflowTimeout, func(r chWrite) bool { return r.typ != dataPacket })
+}
+
+// 处理写入队列溢出，disconnect策略下断开连接
+func (c *serverConn) overflow(dropped []chWrite, err error) error {
+	if len(dropped) > 0 {
+		c.connMgr.server.overflow(c, dropped)
+	}
+
+	if err != nil && c.connMgr.server.opts.overflowPolicy == network.OverflowDisconnect {
+		_ = c.forceClose(true)
+	}
+
+	return err
+}
+
 // 是否已关闭
 func (c *serverConn) isClosed() bool {
 	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
 }
+
+// 获取附带连接信息的日志器
+func (c *serverConn) log() logger.Logger {
+	l := c.connMgr.server.opts.logger.With("cid", c.ID(), "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
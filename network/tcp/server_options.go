@@ -1,6 +1,11 @@
 package tcp
 
 import (
+	"crypto/tls"
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/packet"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"time"
@@ -11,6 +16,10 @@ const (
 	defaultServerMaxConnNum         = 5000
 	defaultServerHeartbeatInterval  = time.Second * 10
 	defaultServerHeartbeatMechanism = "resp"
+	defaultServerWriteQueueSize     = 4096
+	defaultServerOverflowPolicy     = network.OverflowBlock
+	defaultServerWriteBatchBytes    = 64 * 1024
+	defaultServerHandshakeTimeout   = time.Second * 10
 
 	defaultServerPackerName = "due"
 )
@@ -25,12 +34,26 @@ type HeartbeatMechanism string
 type ServerOption func(o *serverOptions)
 
 type serverOptions struct {
-	addr               string             // 监听地址，默认0.0.0.0:3553
-	maxConnNum         int                // 最大连接数，默认5000
-	heartbeatInterval  time.Duration      // 心跳检测间隔时间，默认10s
-	heartbeatMechanism HeartbeatMechanism // 心跳机制，默认resp
+	addr               string                  // 监听地址，默认0.0.0.0:3553
+	maxConnNum         int                     // 最大连接数，默认5000
+	heartbeatInterval  time.Duration           // 心跳检测间隔时间，默认10s
+	heartbeatMechanism HeartbeatMechanism      // 心跳机制，默认resp
+	goingAway          ipacket.Message         // 优雅关闭时下发给客户端的消息
+	writeQueueSize     int                     // 写入队列大小，默认4096
+	overflowPolicy     network.OverflowPolicy  // 写入队列溢出策略，默认block
+	overflowTimeout    time.Duration           // block策略的最长等待时间，默认为0表示一直等待
+	overflowHandler    network.OverflowHandler // 写入队列溢出hook函数
+	writeBatchBytes    int                     // 单次批量写入的最大字节数，默认64KB，为0时不合并写入
+	writeFlushLatency  time.Duration           // 批量写入等待后续消息的最长时间，默认为0表示只合并已就绪的消息
+	tlsConfig          *tls.Config             // TLS配置，为nil时不启用TLS
+	handshakeTimeout   time.Duration           // TLS握手超时时间，默认10s
+	readLease          bool                    // 是否租借读取消息，默认false
 
-	packer ipacket.Packer
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	metrics  metrics.Metrics       // 指标采集器，默认使用全局采集器
+	packer   ipacket.Packer
 }
 
 func defaultServerOptions() *serverOptions {
@@ -39,10 +62,36 @@ func defaultServerOptions() *serverOptions {
 		maxConnNum:         defaultServerMaxConnNum,
 		heartbeatInterval:  defaultServerHeartbeatInterval,
 		heartbeatMechanism: HeartbeatMechanism(defaultServerHeartbeatMechanism),
+		writeQueueSize:     defaultServerWriteQueueSize,
+		overflowPolicy:     defaultServerOverflowPolicy,
+		writeBatchBytes:    defaultServerWriteBatchBytes,
+		handshakeTimeout:   defaultServerHandshakeTimeout,
+		logger:             logger.Default(),
+		metrics:            metrics.Default(),
 		packer:             packet.GetDefaultPacker(defaultClientPackerName),
 	}
 }
 
+// 校验配置，返回所有不合法的配置项
+func (o *serverOptions) validate() error {
+	var problems []string
+
+	if o.writeQueueSize < 0 {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than or equal to 0, and give %d", o.writeQueueSize))
+	}
+
+	// 无缓冲队列无法丢弃最旧的消息
+	if o.writeQueueSize == 0 && o.overflowPolicy == network.OverflowDropOldest {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than 0 when the overflow policy is %s", o.overflowPolicy))
+	}
+
+	if len(problems) > 0 {
+		return &network.ConfigError{Protocol: protocol, Problems: problems}
+	}
+
+	return nil
+}
+
 // WithServerListenAddr 设置监听地址
 func WithServerListenAddr(addr string) ServerOption {
 	return func(o *serverOptions) { o.addr = addr }
@@ -68,3 +117,74 @@ func WithServerPacker(packer ipacket.Packer) ServerOption {
 		o.packer = packer
 	}
 }
+
+// WithServerGoingAway 设置优雅关闭时下发给客户端的消息，使用服务器的打包器打包
+func WithServerGoingAway(message ipacket.Message) ServerOption {
+	return func(o *serverOptions) { o.goingAway = message }
+}
+
+// WithServerWriteQueueSize 设置写入队列大小，不可小于0，drop_oldest策略下须大于0
+func WithServerWriteQueueSize(size int) ServerOption {
+	return func(o *serverOptions) { o.writeQueueSize = size }
+}
+
+// WithServerOverflowPolicy 设置写入队列溢出策略，timeout为block策略的最长等待时间
+func WithServerOverflowPolicy(policy network.OverflowPolicy, timeout ...time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.overflowPolicy = policy
+		if len(timeout) > 0 {
+			o.overflowTimeout = timeout[0]
+		}
+	}
+}
+
+// WithServerOverflowHandler 设置写入队列溢出hook函数
+func WithServerOverflowHandler(handler network.OverflowHandler) ServerOption {
+	return func(o *serverOptions) { o.overflowHandler = handler }
+}
+
+// WithServerWriteBatchBytes 设置单次批量写入的最大字节数，为0时不合并写入
+func WithServerWriteBatchBytes(n int) ServerOption {
+	return func(o *serverOptions) { o.writeBatchBytes = n }
+}
+
+// WithServerWriteFlushLatency 设置批量写入等待后续消息的最长时间
+func WithServerWriteFlushLatency(latency time.Duration) ServerOption {
+	return func(o *serverOptions) { o.writeFlushLatency = latency }
+}
+
+// WithServerTLSConfig 设置TLS配置，需要校验客户端证书时设置ClientAuth与ClientCAs
+func WithServerTLSConfig(config *tls.Config) ServerOption {
+	return func(o *serverOptions) { o.tlsConfig = config }
+}
+
+// WithServerHandshakeTimeout 设置TLS握手超时时间
+func WithServerHandshakeTimeout(handshakeTimeout time.Duration) ServerOption {
+	return func(o *serverOptions) { o.handshakeTimeout = handshakeTimeout }
+}
+
+// WithServerReadLease 设置是否租借读取消息
+// 启用后打包器支持租借读取时消息直接引用读取器的内存块，接收消息hook函数返回后即归还，hook函数中不可持有消息引用
+func WithServerReadLease(enable bool) ServerOption {
+	return func(o *serverOptions) { o.readLease = enable }
+}
+
+// WithServerLogger 设置日志器
+func WithServerLogger(l logger.Logger) ServerOption {
+	return func(o *serverOptions) { o.logger = l }
+}
+
+// WithServerMetrics 设置指标采集器
+func WithServerMetrics(m metrics.Metrics) ServerOption {
+	return func(o *serverOptions) { o.metrics = m }
+}
+
+// WithServerInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithServerInboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithServerOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithServerOutboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
@@ -0,0 +1,223 @@
+package tcp_test
+
+import (
+	"context"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenAddr(t testing.TB) string {
+	port, err := inet.AssignRandPort("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	const total = 1000
+
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerGoingAway(&due.Message{Route: 99}),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	stopped := make(chan struct{})
+	server.OnStop(func() { close(stopped) })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan int32, total+1)
+	disconnected := make(chan struct{})
+
+	client := tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- message.(*due.Message).Route
+	})
+	client.OnDisconnect(func(conn network.Conn) { close(disconnected) })
+
+	if _, err := client.Dial(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := <-connected
+
+	msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < total; i++ {
+		if err = conn.Push(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err = server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("stop handler not called")
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client not disconnected after shutdown")
+	}
+
+	if n := len(received); n != total+1 {
+		t.Fatalf("received %d messages, want %d", n, total+1)
+	}
+
+	for i := 0; i < total; i++ {
+		<-received
+	}
+
+	if route := <-received; route != 99 {
+		t.Fatalf("last message route %d, want going away route 99", route)
+	}
+
+	if conn.State() != network.ConnClosed {
+		t.Fatal("server conn should be closed")
+	}
+}
+
+func TestServer_Metrics(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+	collector := metrics.NewPrometheus()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerMetrics(collector),
+	)
+
+	received := make(chan struct{}, 1)
+	server.OnReceive(func(conn network.Conn, msg []byte) { received <- struct{}{} })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer))
+
+	conn, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = conn.Push(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	labels := `{protocol="tcp",addr="` + addr + `"}`
+	text := string(collector.Expose())
+
+	for _, line := range []string{
+		"network_connections_active" + labels + " 1",
+		"network_connections_accepted_total" + labels + " 1",
+		"network_frames_in_total" + labels + " 1",
+		"network_bytes_in_total" + labels + " " + strconv.Itoa(len(msg)),
+		"network_handler_latency_seconds_count" + labels + " 1",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestServerConn_PushID(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan []byte, 1)
+
+	client := tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	conn := <-connected
+
+	msg, err := packer.PackMessage(&due.Message{Route: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.PushID(conn, conn.ID()+1, msg); !errors.Is(err, errs.ErrConnectionClosed) {
+		t.Fatalf("push to another conn id returned %v, want %v", err, errs.ErrConnectionClosed)
+	}
+
+	if err = network.PushID(conn, conn.ID(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
@@ -0,0 +1,202 @@
+package tcp_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/testcert"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_MutualTLS(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+	ca := testcert.NewCA(t)
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{ca.Certificate(t, "server")},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.Pool(),
+		}),
+	)
+
+	connected := make(chan network.Conn, 2)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	received := make(chan []byte, 1)
+	server.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := tcp.NewClient(
+		tcp.WithClientDialAddr(addr),
+		tcp.WithClientPacker(packer),
+		tcp.WithClientTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{ca.Certificate(t, "client")},
+			RootCAs:      ca.Pool(),
+		}),
+	)
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	var conn network.Conn
+	select {
+	case conn = <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+
+	for name, c := range map[string]network.Conn{"client": conn, "server": cc} {
+		cert, err := c.PeerCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cert == nil || cert.Subject.CommonName != name {
+			t.Fatalf("peer certificate = %v, want common name %q", cert, name)
+		}
+	}
+
+	data, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.Send(data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(message.(*due.Message).Buffer) != "hello" {
+			t.Fatalf("received %q, want %q", message.(*due.Message).Buffer, "hello")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	// 未提供客户端证书的连接被拒绝
+	untrusted := tcp.NewClient(
+		tcp.WithClientDialAddr(addr),
+		tcp.WithClientPacker(packer),
+		tcp.WithClientTLSConfig(&tls.Config{RootCAs: x509.NewCertPool(), InsecureSkipVerify: true}),
+	)
+
+	if uc, err := untrusted.Dial(); err == nil {
+		defer uc.Close()
+	}
+
+	select {
+	case <-connected:
+		t.Fatal("connection without client certificate should be rejected")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestServer_TLSConcurrentWrites(t *testing.T) {
+	const (
+		workers = 16
+		total   = 500
+	)
+
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+	ca := testcert.NewCA(t)
+
+	server := tcp.NewServer(
+		tcp.WithServerListenAddr(addr),
+		tcp.WithServerPacker(packer),
+		tcp.WithServerTLSConfig(&tls.Config{Certificates: []tls.Certificate{ca.Certificate(t, "server")}}),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	payload := bytes.Repeat([]byte("x"), 4096)
+	received := make(chan error, workers*total)
+
+	client := tcp.NewClient(
+		tcp.WithClientDialAddr(addr),
+		tcp.WithClientPacker(packer),
+		tcp.WithClientTLSConfig(&tls.Config{RootCAs: ca.Pool(), ServerName: "localhost"}),
+	)
+	client.OnReceive(func(conn network.Conn, msg []byte) {
+		message, err := packer.UnpackMessage(msg)
+		if err == nil && !bytes.Equal(message.(*due.Message).Buffer, payload) {
+			err = errors.New("received corrupted payload")
+		}
+		received <- err
+	})
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	conn := <-connected
+
+	// 同步发送的多段缓冲区与写入协程的批量写入并发进行
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < total; j++ {
+				buf, err := packer.PackBuffer(&due.Message{Route: 1, Buffer: payload})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if i%2 == 0 {
+					err = conn.SendBuffer(buf)
+				} else {
+					err = conn.PushBuffer(buf)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers*total; i++ {
+		select {
+		case err := <-received:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d messages, want %d", i, workers*total)
+		}
+	}
+}
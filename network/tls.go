@@ -0,0 +1,113 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// PeerCertificate 获取TLS连接的对端证书，未启用TLS、未完成握手或对端未提供证书时返回nil
+func PeerCertificate(conn net.Conn) *x509.Certificate {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	state := tlsConn.ConnectionState()
+	if !state.HandshakeComplete || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return state.PeerCertificates[0]
+}
+
+// CertReloader 证书热加载器，证书或秘钥文件修改后在下次握手时重新加载
+// 重新加载失败时继续使用之前的证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader 创建证书热加载器
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate 用于tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// GetClientCertificate 用于tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate()
+}
+
+// Certificate 获取证书，文件修改时重新加载
+func (r *CertReloader) Certificate() (*tls.Certificate, error) {
+	modTime, err := r.latestModTime()
+
+	r.mu.RLock()
+	cert, changed := r.cert, err == nil && !modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+
+	if !changed {
+		return cert, nil
+	}
+
+	if reloaded, err := r.reload(); err == nil {
+		return reloaded, nil
+	}
+
+	return cert, nil
+}
+
+// 重新加载证书
+func (r *CertReloader) reload() (*tls.Certificate, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+
+	return &cert, nil
+}
+
+// 获取证书与秘钥文件的最近修改时间
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,74 @@
+package network_test
+
+import (
+	"crypto/x509"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/testcert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertReloader(t *testing.T) {
+	ca := testcert.NewCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	write := func(certPEM, keyPEM []byte, modTime time.Time) {
+		for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	commonName := func(r *network.CertReloader) string {
+		cert, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return leaf.Subject.CommonName
+	}
+
+	now := time.Now()
+
+	certPEM, keyPEM := ca.Issue(t, "first")
+	write(certPEM, keyPEM, now.Add(-time.Minute))
+
+	reloader, err := network.NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := commonName(reloader); name != "first" {
+		t.Fatalf("common name = %q, want %q", name, "first")
+	}
+
+	certPEM, keyPEM = ca.Issue(t, "second")
+	write(certPEM, keyPEM, now)
+
+	if name := commonName(reloader); name != "second" {
+		t.Fatalf("common name after reload = %q, want %q", name, "second")
+	}
+
+	// 文件内容不合法时继续使用之前的证书
+	write([]byte("invalid"), keyPEM, now.Add(time.Minute))
+
+	if name := commonName(reloader); name != "second" {
+		t.Fatalf("common name after invalid reload = %q, want %q", name, "second")
+	}
+
+	if _, err = network.NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Fatal("expected error for missing cert file")
+	}
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"github.com/cute-angelia/go-game-utils/buffer"
 	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/gorilla/websocket"
 	"sync/atomic"
@@ -8,6 +9,7 @@ import (
 
 type client struct {
 	opts              *clientOptions            // 配置
+	err               error                     // 配置错误，拨号时返回
 	id                int64                     // 连接ID
 	dialer            *websocket.Dialer         // 拨号器
 	connectHandler    network.ConnectHandler    // 连接打开hook函数
@@ -23,13 +25,41 @@ func NewClient(opts ...ClientOption) network.Client {
 		opt(o)
 	}
 
-	return &client{opts: o, dialer: &websocket.Dialer{
-		HandshakeTimeout: o.handshakeTimeout,
+	return newClient(o)
+}
+
+// NewClientE 创建客户端，配置不合法时返回*network.ConfigError
+func NewClientE(opts ...ClientOption) (network.Client, error) {
+	o := defaultClientOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c := newClient(o)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c, nil
+}
+
+func newClient(o *clientOptions) *client {
+	return &client{opts: o, err: o.validate(), dialer: &websocket.Dialer{
+		HandshakeTimeout:  o.handshakeTimeout,
+		TLSClientConfig:   o.tlsConfig,
+		Subprotocols:      o.subprotocols,
+		ReadBufferSize:    o.readBufferSize,
+		WriteBufferSize:   o.writeBufferSize,
+		EnableCompression: o.compression,
 	}}
 }
 
 // Dial 拨号连接
 func (c *client) Dial(addr ...string) (network.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
 	var url string
 
 	if len(addr) > 0 && addr[0] != "" {
@@ -43,6 +73,11 @@ func (c *client) Dial(addr ...string) (network.Conn, error) {
 		return nil, err
 	}
 
+	if err = configure(conn, c.opts.readLimit, c.opts.compressionLevel); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
 	return newClientConn(atomic.AddInt64(&c.id, 1), conn, c), nil
 }
 
@@ -65,3 +100,53 @@ func (c *client) OnDisconnect(handler network.DisconnectHandler) {
 func (c *client) OnReceive(handler network.ReceiveHandler) {
 	c.receiveHandler = handler
 }
+
+// 处理接收到的消息，依次经过入站拦截器
+func (c *client) receive(conn network.Conn, msg []byte) {
+	if len(c.opts.inbound) == 0 {
+		if c.receiveHandler != nil {
+			c.receiveHandler(conn, msg)
+		}
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	if err := network.Intercept(c.opts.inbound, frame, c.invokeReceive); err != nil {
+		c.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (c *client) invokeReceive(frame *network.Frame) error {
+	if c.receiveHandler != nil {
+		c.receiveHandler(frame.Conn(), frame.Data())
+	}
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (c *client) send(conn network.Conn, msg []byte, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, c.opts.packer)
+
+	return network.Intercept(c.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (c *client) sendBuffer(conn network.Conn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(c.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return c.send(conn, msg, write)
+}
@@ -1,12 +1,14 @@
 package ws
 
 import (
+	"crypto/x509"
 	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
 	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"github.com/cute-angelia/go-game-utils/utils/inet"
-	"log"
-
 	"github.com/gorilla/websocket"
 	"net"
 	"sync"
@@ -24,6 +26,7 @@ type clientConn struct {
 	chLowWrite        chan chWrite    // 低级队列
 	chHighWrite       chan chWrite    // 优先队列
 	lastHeartbeatTime int64           // 上次心跳时间
+	network.Attrs                     // 连接属性
 	done              chan struct{}   // 写入完成信号
 	close             chan struct{}   // 关闭信号
 }
@@ -77,31 +80,58 @@ func (c *clientConn) Unbind() {
 // Send 发送消息（异步）
 // 由于gorilla/websocket库不支持一个连接得并发读写，因而使用Send方法会导致使用写锁操作
 // 建议使用Push方法替代Send
-func (c *clientConn) Send(msg []byte) (err error) {
-	c.rw.RLock()
-	defer c.rw.RUnlock()
-
-	if err = c.checkState(); err != nil {
-		return
-	}
+func (c *clientConn) Send(msg []byte) error {
+	return c.client.send(c, msg, c.send)
+}
 
-	c.chHighWrite <- chWrite{typ: dataPacket, msg: msg}
+// 发送消息（异步）
+func (c *clientConn) send(msg []byte) error {
+	return c.asyncWrite(c.chHighWrite, chWrite{typ: dataPacket, msg: msg})
+}
 
-	return
+// 发送缓冲区（异步）
+func (c *clientConn) sendBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chHighWrite, chWrite{typ: dataPacket, buf: buf})
 }
 
 // Push 发送消息（异步）
-func (c *clientConn) Push(msg []byte) (err error) {
+func (c *clientConn) Push(msg []byte) error {
+	return c.client.send(c, msg, c.push)
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *clientConn) SendBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *clientConn) PushBuffer(buf buffer.Buffer) error {
+	return c.client.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *clientConn) push(msg []byte) error {
+	return c.asyncWrite(c.chLowWrite, chWrite{typ: dataPacket, msg: msg})
+}
+
+// 发送缓冲区（异步）
+func (c *clientConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chLowWrite, chWrite{typ: dataPacket, buf: buf})
+}
+
+// 异步写入队列
+func (c *clientConn) asyncWrite(ch chan chWrite, w chWrite) error {
 	c.rw.RLock()
 	defer c.rw.RUnlock()
 
-	if err = c.checkState(); err != nil {
-		return
+	if err := c.checkState(); err != nil {
+		w.release()
+		return err
 	}
 
-	c.chLowWrite <- chWrite{typ: dataPacket, msg: msg}
+	ch <- w
 
-	return
+	return nil
 }
 
 // State 获取连接状态
@@ -139,7 +169,7 @@ func (c *clientConn) LocalAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.LocalAddr(), nil
@@ -166,19 +196,48 @@ func (c *clientConn) RemoteAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.RemoteAddr(), nil
 }
 
+// 获取协商的子协议
+func (c *clientConn) subprotocol() string {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	if c.conn == nil {
+		return ""
+	}
+
+	return c.conn.Subprotocol()
+}
+
+// PeerCertificate 获取对端TLS证书
+func (c *clientConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return network.PeerCertificate(conn.NetConn()), nil
+}
+
 // 检测连接状态
 func (c *clientConn) checkState() error {
 	switch network.ConnState(atomic.LoadInt32(&c.state)) {
 	case network.ConnHanged:
-		return errors.New("ErrConnectionHanged")
+		return errs.ErrConnectionHanged
 	case network.ConnClosed:
-		return errors.New("ErrConnectionClosed")
+		return errs.ErrConnectionClosed
 	default:
 		return nil
 	}
@@ -187,7 +246,7 @@ func (c *clientConn) checkState() error {
 // 优雅关闭
 func (c *clientConn) graceClose() error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
-		return errors.New("ErrConnectionNotOpened")
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.RLock()
@@ -197,7 +256,7 @@ func (c *clientConn) graceClose() error {
 	<-c.done
 
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-		return errors.New("ErrConnectionNotHanged")
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.Lock()
@@ -222,7 +281,7 @@ func (c *clientConn) graceClose() error {
 func (c *clientConn) forceClose() error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
 		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-			return errors.New("ErrConnectionClosed")
+			return errs.ErrConnectionClosed
 		}
 	}
 
@@ -246,7 +305,11 @@ func (c *clientConn) forceClose() error {
 
 // 读取消息
 func (c *clientConn) read() {
+	c.rw.RLock()
 	conn := c.conn
+	c.rw.RUnlock()
+
+	frameType := c.client.opts.msgType.frameType()
 
 	for {
 		select {
@@ -257,14 +320,14 @@ func (c *clientConn) read() {
 			if err != nil {
 				if !errors.Is(err, net.ErrClosed) {
 					if _, ok := err.(*websocket.CloseError); !ok {
-						log.Printf("read message failed: %v", err)
+						c.log().Warn("read message failed", "error", err)
 					}
 				}
 				_ = c.forceClose()
 				return
 			}
 
-			if msgType != websocket.BinaryMessage {
+			if msgType != frameType {
 				continue
 			}
 
@@ -289,7 +352,7 @@ func (c *clientConn) read() {
 			// check heartbeat packet
 			isHeartbeat, err := c.client.opts.packer.CheckHeartbeat(msg)
 			if err != nil {
-				log.Printf("[%s] check heartbeat message error: %v", c.client.opts.packer.String(), err)
+				c.log().Warn("check heartbeat message error", "error", err)
 				continue
 			}
 
@@ -298,21 +361,18 @@ func (c *clientConn) read() {
 				continue
 			}
 
-			if c.client.receiveHandler != nil {
-				c.client.receiveHandler(c, msg)
-			}
+			c.client.receive(c, msg)
 		}
 	}
 }
 
 // 写入消息
 func (c *clientConn) write() {
-	var (
-		conn   = c.conn
-		ticker *time.Ticker
-	)
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
 
-	log.Println(c.client.opts.heartbeatInterval, "💓时间", c.client.opts.heartbeatInterval > 0)
+	var ticker *time.Ticker
 
 	if c.client.opts.heartbeatInterval > 0 {
 		ticker = time.NewTicker(c.client.opts.heartbeatInterval)
@@ -377,17 +437,17 @@ func (c *clientConn) doWrite(conn *websocket.Conn, r chWrite) bool {
 
 	if r.typ == heartbeatPacket {
 		if msg, err := c.client.opts.packer.PackHeartbeat(); err != nil {
-			log.Printf("pack heartbeat message error: %v", err)
+			c.log().Error("pack heartbeat message error", "error", err)
 			return true
 		} else {
 			r.msg = msg
 		}
 	}
 
-	if err := conn.WriteMessage(websocket.BinaryMessage, r.msg); err != nil {
+	if err := r.writeTo(conn, c.client.opts.msgType.frameType(), c.client.opts.compressionThreshold); err != nil {
 		if !errors.Is(err, net.ErrClosed) {
 			if _, ok := err.(*websocket.CloseError); !ok {
-				log.Printf("write message error: %v", err)
+				c.log().Warn("write message error", "error", err)
 			}
 		}
 	}
@@ -401,7 +461,7 @@ func (c *clientConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 	deadline := time.Now().Add(-2 * c.client.opts.heartbeatInterval).UnixNano()
 
 	if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
-		log.Printf("connection heartbeat timeout, cid: %d", c.id)
+		c.log().Info("connection heartbeat timeout")
 		_ = c.forceClose()
 		return false
 	} else {
@@ -410,16 +470,14 @@ func (c *clientConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 		}
 
 		if heartbeat, err := c.client.opts.packer.PackHeartbeat(); err != nil {
-			log.Printf("pack heartbeat message error: %v", err)
+			c.log().Error("pack heartbeat message error", "error", err)
 		} else {
 
-			if c.client.opts.packer.String() != "qx" {
-				log.Println("发送心跳", heartbeat)
-			}
+			c.log().Debug("send heartbeat message", "size", len(heartbeat))
 
 			// send heartbeat packet
-			if err := conn.WriteMessage(websocket.BinaryMessage, heartbeat); err != nil {
-				log.Printf("write heartbeat message error: %v", err)
+			if err := conn.WriteMessage(c.client.opts.msgType.frameType(), heartbeat); err != nil {
+				c.log().Warn("write heartbeat message error", "error", err)
 			}
 		}
 	}
@@ -431,3 +489,14 @@ func (c *clientConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 func (c *clientConn) isClosed() bool {
 	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
 }
+
+// 获取附带连接信息的日志器
+func (c *clientConn) log() logger.Logger {
+	l := c.client.opts.logger.With("cid", c.id, "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
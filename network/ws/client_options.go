@@ -1,6 +1,11 @@
 package ws
 
 import (
+	"compress/flate"
+	"crypto/tls"
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/packet"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"time"
@@ -10,6 +15,11 @@ const (
 	defaultClientDialUrl           = "ws://127.0.0.1:3553"
 	defaultClientHandshakeTimeout  = time.Second * 10
 	defaultClientHeartbeatInterval = time.Second * 10
+	defaultClientMessageType       = BinaryMessage
+	defaultClientReadBufferSize    = 4096
+	defaultClientWriteBufferSize   = 4096
+	defaultClientCompression       = false
+	defaultClientCompressionLevel  = flate.BestSpeed
 
 	defaultClientPackerName = "due"
 )
@@ -18,11 +28,23 @@ type ClientOption func(o *clientOptions)
 
 type clientOptions struct {
 	url               string        // 拨号地址
-	msgType           string        // 默认消息类型，text | binary
+	msgType           MessageType   // 消息帧类型，默认binary
 	handshakeTimeout  time.Duration // 握手超时时间
 	heartbeatInterval time.Duration // 心跳间隔时间，默认10s
+	tlsConfig         *tls.Config   // wss链接的TLS配置
+	subprotocols      []string      // 请求的子协议，按优先级排列
 
-	packer ipacket.Packer
+	readBufferSize       int   // 读缓冲区大小，默认4096
+	writeBufferSize      int   // 写缓冲区大小，默认4096
+	readLimit            int64 // 单条消息最大字节数，超过时关闭连接，默认为0表示不限制
+	compression          bool  // 是否协商启用消息压缩，默认关闭
+	compressionLevel     int   // 压缩级别，取值范围为-2~9，默认为1
+	compressionThreshold int   // 压缩阈值，小于该字节数的消息不压缩，默认为0表示全部压缩
+
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	packer   ipacket.Packer
 }
 
 func defaultClientOptions() *clientOptions {
@@ -30,11 +52,32 @@ func defaultClientOptions() *clientOptions {
 		url:               defaultClientDialUrl,
 		handshakeTimeout:  defaultClientHandshakeTimeout,
 		heartbeatInterval: defaultClientHeartbeatInterval,
+		msgType:           defaultClientMessageType,
+		readBufferSize:    defaultClientReadBufferSize,
+		writeBufferSize:   defaultClientWriteBufferSize,
+		compression:       defaultClientCompression,
+		compressionLevel:  defaultClientCompressionLevel,
 
+		logger: logger.Default(),
 		packer: packet.GetDefaultPacker(defaultClientPackerName),
 	}
 }
 
+// 校验配置，返回所有不合法的配置项
+func (o *clientOptions) validate() error {
+	var problems []string
+
+	if o.compressionLevel < flate.HuffmanOnly || o.compressionLevel > flate.BestCompression {
+		problems = append(problems, fmt.Sprintf("the compression level must be between %d and %d, and give %d", flate.HuffmanOnly, flate.BestCompression, o.compressionLevel))
+	}
+
+	if len(problems) > 0 {
+		return &network.ConfigError{Protocol: protocol, Problems: problems}
+	}
+
+	return nil
+}
+
 // WithClientDialUrl 设置拨号链接
 func WithClientDialUrl(url string) ClientOption {
 	return func(o *clientOptions) { o.url = url }
@@ -50,8 +93,68 @@ func WithClientHeartbeatInterval(heartbeatInterval time.Duration) ClientOption {
 	return func(o *clientOptions) { o.heartbeatInterval = heartbeatInterval }
 }
 
+// WithClientReadBufferSize 设置读缓冲区大小
+func WithClientReadBufferSize(size int) ClientOption {
+	return func(o *clientOptions) { o.readBufferSize = size }
+}
+
+// WithClientWriteBufferSize 设置写缓冲区大小
+func WithClientWriteBufferSize(size int) ClientOption {
+	return func(o *clientOptions) { o.writeBufferSize = size }
+}
+
+// WithClientReadLimit 设置单条消息最大字节数，读取超过该长度的消息时关闭连接
+func WithClientReadLimit(limit int64) ClientOption {
+	return func(o *clientOptions) { o.readLimit = limit }
+}
+
+// WithClientCompression 设置是否协商启用消息压缩（permessage-deflate）
+func WithClientCompression(enable bool) ClientOption {
+	return func(o *clientOptions) { o.compression = enable }
+}
+
+// WithClientCompressionLevel 设置压缩级别，取值范围为-2~9，参考compress/flate
+func WithClientCompressionLevel(level int) ClientOption {
+	return func(o *clientOptions) { o.compressionLevel = level }
+}
+
+// WithClientCompressionThreshold 设置压缩阈值，小于该字节数的消息不压缩
+func WithClientCompressionThreshold(threshold int) ClientOption {
+	return func(o *clientOptions) { o.compressionThreshold = threshold }
+}
+
+// WithClientMessageType 设置消息帧类型，仅收发该类型的消息帧，其他类型的消息帧将被忽略
+func WithClientMessageType(msgType MessageType) ClientOption {
+	return func(o *clientOptions) { o.msgType = msgType }
+}
+
 func WithClientPacker(packer ipacket.Packer) ClientOption {
 	return func(o *clientOptions) {
 		o.packer = packer
 	}
 }
+
+// WithClientTLSConfig 设置wss链接的TLS配置
+func WithClientTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) { o.tlsConfig = config }
+}
+
+// WithClientSubprotocols 设置请求的子协议，按优先级排列
+func WithClientSubprotocols(subprotocols ...string) ClientOption {
+	return func(o *clientOptions) { o.subprotocols = subprotocols }
+}
+
+// WithClientLogger 设置日志器
+func WithClientLogger(l logger.Logger) ClientOption {
+	return func(o *clientOptions) { o.logger = l }
+}
+
+// WithClientInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithClientInboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithClientOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithClientOutboundInterceptors(interceptors ...network.Interceptor) ClientOption {
+	return func(o *clientOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
@@ -1,7 +1,30 @@
 package ws
 
+import (
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/gorilla/websocket"
+)
+
 const protocol = "ws"
 
+// MessageType WS消息帧类型
+type MessageType string
+
+const (
+	BinaryMessage MessageType = "binary" // 二进制帧
+	TextMessage   MessageType = "text"   // 文本帧，适用于JSON等文本协议
+)
+
+// 对应的WS帧类型
+func (t MessageType) frameType() int {
+	if t == TextMessage {
+		return websocket.TextMessage
+	}
+
+	return websocket.BinaryMessage
+}
+
 const (
 	closeSig        int = iota // 关闭信号
 	dataPacket                 // 数据包
@@ -11,4 +34,78 @@ const (
 type chWrite struct {
 	typ int
 	msg []byte
+	buf buffer.Buffer
+}
+
+// 消息字节数
+func (w chWrite) len() int {
+	if w.buf != nil {
+		return w.buf.Len()
+	}
+
+	return len(w.msg)
+}
+
+// 消息字节，buf释放后不可再使用
+func (w chWrite) bytes() []byte {
+	if w.buf != nil {
+		return w.buf.Bytes()
+	}
+
+	return w.msg
+}
+
+// 释放缓冲区
+func (w chWrite) release() {
+	if w.buf != nil {
+		w.buf.Release()
+	}
+}
+
+// 写入连接，缓冲区的各节点组装为一个消息帧，写入完成后释放缓冲区
+// 小于压缩阈值的消息不压缩，仅在协商启用压缩时有效
+func (w chWrite) writeTo(conn *websocket.Conn, frameType int, compressionThreshold int) error {
+	defer w.release()
+
+	if compressionThreshold > 0 {
+		conn.EnableWriteCompression(w.len() >= compressionThreshold)
+	}
+
+	if w.buf == nil {
+		return conn.WriteMessage(frameType, w.msg)
+	}
+
+	writer, err := conn.NextWriter(frameType)
+	if err != nil {
+		return err
+	}
+
+	w.buf.Range(func(node *buffer.NocopyNode) bool {
+		_, err = writer.Write(node.Bytes())
+		return err == nil
+	})
+
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// 设置连接的读取上限与压缩级别
+func configure(conn *websocket.Conn, readLimit int64, compressionLevel int) error {
+	if readLimit > 0 {
+		conn.SetReadLimit(readLimit)
+	}
+
+	return conn.SetCompressionLevel(compressionLevel)
+}
+
+// Subprotocol 获取WS连接协商的子协议，非WS连接或未协商子协议时返回空
+func Subprotocol(conn network.Conn) string {
+	if c, ok := conn.(interface{ subprotocol() string }); ok {
+		return c.subprotocol()
+	}
+
+	return ""
 }
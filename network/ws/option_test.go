@@ -0,0 +1,173 @@
+package ws_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/ws"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/gorilla/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_ReadLimit(t *testing.T) {
+	addr := listenAddr(t)
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(due.NewPacker()),
+		ws.WithServerReadLimit(1024),
+	)
+
+	disconnected := make(chan struct{}, 1)
+	server.OnDisconnect(func(conn network.Conn) { disconnected <- struct{}{} })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err = conn.WriteMessage(websocket.BinaryMessage, make([]byte, 2048)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not close the connection with an oversized message")
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	if _, _, err = conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("expected close error %d, got %v", websocket.CloseMessageTooBig, err)
+	}
+}
+
+func TestServer_Compression(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker(due.WithBufferBytes(64 * 1024))
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(packer),
+		ws.WithServerCompressionLevel(9),
+		ws.WithServerCompressionThreshold(512),
+		ws.WithServerReadBufferSize(1024),
+		ws.WithServerWriteBufferSize(1024),
+	)
+
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		if err := conn.Push(msg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan []byte, 2)
+
+	client := ws.NewClient(
+		ws.WithClientDialUrl("ws://"+addr),
+		ws.WithClientPacker(packer),
+		ws.WithClientCompression(true),
+		ws.WithClientCompressionLevel(9),
+		ws.WithClientCompressionThreshold(512),
+		ws.WithClientReadLimit(32*1024),
+	)
+	client.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	// 阈值以下的消息不压缩，以上的消息压缩，两者均可正常收发
+	for _, size := range []int{16, 16 * 1024} {
+		data, err := packer.PackMessage(&due.Message{Route: 1, Buffer: bytes.Repeat([]byte("x"), size)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = cc.Push(data); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-received:
+			if !bytes.Equal(msg, data) {
+				t.Fatalf("received %d bytes, want %d bytes", len(msg), len(data))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %d bytes message", size)
+		}
+	}
+
+	// 协商启用压缩
+	dialer := websocket.Dialer{EnableCompression: true}
+
+	conn, resp, err := dialer.Dial("ws://"+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("compression not negotiated, extensions: %q", ext)
+	}
+
+	// 非法的压缩级别
+	if _, err = ws.NewClient(ws.WithClientDialUrl("ws://"+addr), ws.WithClientCompressionLevel(10)).Dial(); err == nil {
+		t.Fatal("expected error for invalid compression level")
+	}
+}
+
+func TestServer_InvalidWriteQueueSize(t *testing.T) {
+	var configErr *network.ConfigError
+	if _, err := ws.NewServerE(ws.WithServerListenAddr(""), ws.WithServerWriteQueueSize(-1)); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+
+	if _, err := ws.NewServerE(ws.WithServerListenAddr(""), ws.WithServerWriteQueueSize(0), ws.WithServerOverflowPolicy(network.OverflowDropOldest)); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error for unbuffered drop_oldest queue, got %v", err)
+	}
+}
+
+func TestInvalidCompressionLevel(t *testing.T) {
+	var configErr *network.ConfigError
+	for _, level := range []int{-3, 10} {
+		if _, err := ws.NewServerE(ws.WithServerListenAddr(""), ws.WithServerCompressionLevel(level)); !errors.As(err, &configErr) {
+			t.Fatalf("expected server config error for compression level %d, got %v", level, err)
+		}
+
+		if _, err := ws.NewClientE(ws.WithClientCompressionLevel(level)); !errors.As(err, &configErr) {
+			t.Fatalf("expected client config error for compression level %d, got %v", level, err)
+		}
+
+		if _, err := ws.NewClient(ws.WithClientCompressionLevel(level)).Dial(); !errors.As(err, &configErr) {
+			t.Fatalf("expected dial config error for compression level %d, got %v", level, err)
+		}
+	}
+
+	for _, level := range []int{-2, 9} {
+		if _, err := ws.NewServerE(ws.WithServerListenAddr(""), ws.WithServerCompressionLevel(level)); err != nil {
+			t.Fatalf("compression level %d should be valid, got %v", level, err)
+		}
+
+		if _, err := ws.NewClientE(ws.WithClientCompressionLevel(level)); err != nil {
+			t.Fatalf("compression level %d should be valid, got %v", level, err)
+		}
+	}
+}
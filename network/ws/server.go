@@ -1,45 +1,98 @@
 package ws
 
 import (
+	"context"
+	"crypto/tls"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/metrics"
 	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"github.com/gorilla/websocket"
-	"log"
 	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type UpgradeHandler func(w http.ResponseWriter, r *http.Request) (allowed bool)
 
+// UpgradeAttrsHandler HTTP协议升级hook函数，返回的属性在连接建立后、连接打开hook函数调用前附加到连接上
+type UpgradeAttrsHandler func(w http.ResponseWriter, r *http.Request) (attrs map[string]interface{}, allowed bool)
+
 type Server interface {
 	network.Server
 	// OnUpgrade 监听HTTP请求升级
 	OnUpgrade(handler UpgradeHandler)
+	// OnUpgradeAttrs 监听HTTP请求升级，可返回附加到连接上的属性，与OnUpgrade互相覆盖
+	OnUpgradeAttrs(handler UpgradeAttrsHandler)
+	// Handler 获取将HTTP请求升级为WS连接的处理器，可挂载到任意路由，未指定打包器时使用默认打包器
+	Handler(packer ...ipacket.Packer) http.Handler
 }
 
 type server struct {
 	opts              *serverOptions            // 配置
-	listener          net.Listener              // 监听器
+	listener          net.Listener              // 监听器，未设置监听地址时为nil
+	httpServer        *http.Server              // HTTP服务器，未设置监听地址时为nil
+	upgrader          websocket.Upgrader        // 协议升级器
+	serving           int32                     // 是否接受新连接
 	connMgr           *serverConnMgr            // 连接管理器
 	startHandler      network.StartHandler      // 服务器启动hook函数
 	stopHandler       network.CloseHandler      // 服务器关闭hook函数
 	connectHandler    network.ConnectHandler    // 连接打开hook函数
 	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
 	receiveHandler    network.ReceiveHandler    // 接收消息hook函数
-	upgradeHandler    UpgradeHandler            // HTTP协议升级成WS协议hook函数
+	upgradeHandler    UpgradeAttrsHandler       // HTTP协议升级成WS协议hook函数
+	trustedProxies    []netip.Prefix            // 可信代理
+	err               error                     // 配置错误，启动时返回
+	labels            []string                  // 指标标签
+	unregister        []func()                  // 仪表盘注销函数
 }
 
 var _ Server = &server{}
 
+// NewServer 创建服务器，配置不合法时启动服务器返回*network.ConfigError
 func NewServer(opts ...ServerOption) Server {
 	o := defaultServerOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	return newServer(o)
+}
+
+// NewServerE 创建服务器，配置不合法时返回*network.ConfigError
+func NewServerE(opts ...ServerOption) (Server, error) {
+	o := defaultServerOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := newServer(o)
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s, nil
+}
+
+func newServer(o *serverOptions) *server {
 	s := &server{}
 	s.opts = o
+	s.err = o.validate()
+	s.labels = []string{"protocol", protocol, "addr", o.addr}
 	s.connMgr = newConnMgr(s)
+	s.trustedProxies = parseTrustedProxies(o.trustedProxies)
+	s.upgrader = websocket.Upgrader{
+		Subprotocols:      o.subprotocols,
+		HandshakeTimeout:  o.handshakeTimeout,
+		ReadBufferSize:    o.readBufferSize,
+		WriteBufferSize:   o.writeBufferSize,
+		EnableCompression: o.compression,
+		CheckOrigin:       o.checkOrigin,
+	}
 
 	return s
 }
@@ -56,32 +109,91 @@ func (s *server) Protocol() string {
 
 // Start 启动服务器
 func (s *server) Start() error {
+	if s.err != nil {
+		return s.err
+	}
+
 	if err := s.init(); err != nil {
 		return err
 	}
 
+	s.register()
+
+	atomic.StoreInt32(&s.serving, 1)
+
 	if s.startHandler != nil {
 		s.startHandler()
 	}
 
-	icall.Go(s.serve)
+	if s.listener != nil {
+		icall.Go(s.serve)
+	}
 
 	return nil
 }
 
 // Stop 关闭服务器
 func (s *server) Stop() error {
-	if err := s.listener.Close(); err != nil {
+	if err := s.close(); err != nil {
 		return err
 	}
 
+	if s.httpServer != nil {
+		_ = s.httpServer.Close()
+	}
+
+	s.deregister()
+
 	s.connMgr.close()
 
+	if s.stopHandler != nil {
+		s.stopHandler()
+	}
+
 	return nil
 }
 
-// 初始化服务器
+// Shutdown 优雅关闭服务器
+func (s *server) Shutdown(ctx context.Context) error {
+	if err := s.close(); err != nil {
+		return err
+	}
+
+	s.deregister()
+
+	// 各打包器分别打包关闭消息
+	goingAway := make(map[ipacket.Packer][]byte)
+	if s.opts.goingAway != nil {
+		for _, packer := range s.packers() {
+			msg, err := packer.PackMessage(s.opts.goingAway)
+			if err != nil {
+				s.opts.logger.Error("pack going away message error", "error", err)
+			} else {
+				goingAway[packer] = msg
+			}
+		}
+	}
+
+	// 等待未完成协议升级的HTTP请求结束，已升级的连接由连接管理器关闭
+	if s.httpServer != nil {
+		_ = s.httpServer.Shutdown(ctx)
+	}
+
+	err := s.connMgr.shutdown(ctx, goingAway)
+
+	if s.stopHandler != nil {
+		s.stopHandler()
+	}
+
+	return err
+}
+
+// 初始化服务器，未设置监听地址时仅通过Handler接入连接
 func (s *server) init() error {
+	if s.opts.addr == "" {
+		return nil
+	}
+
 	addr, err := net.ResolveTCPAddr("tcp", s.opts.addr)
 	if err != nil {
 		return err
@@ -94,50 +206,113 @@ func (s *server) init() error {
 
 	s.listener = ln
 
+	mux := http.NewServeMux()
+	mux.Handle(s.opts.path, s.Handler())
+
+	for _, r := range s.opts.routes {
+		mux.Handle(r.path, s.Handler(r.packer))
+	}
+
+	// websocket不支持基于HTTP/2升级，TLS协商时仅使用HTTP/1.1
+	s.httpServer = &http.Server{
+		Handler:      mux,
+		TLSConfig:    s.opts.tlsConfig,
+		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)),
+	}
+
 	return nil
 }
 
-// 启动服务器
-func (s *server) serve() {
-	upgrader := websocket.Upgrader{
-		ReadBufferSize:    4096,
-		WriteBufferSize:   4096,
-		EnableCompression: true,
-		CheckOrigin:       s.opts.checkOrigin,
+// 停止接受新连接并关闭监听器
+func (s *server) close() error {
+	atomic.StoreInt32(&s.serving, 0)
+
+	if s.listener == nil {
+		return nil
 	}
 
-	http.HandleFunc(s.opts.path, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-			return
-		}
+	return s.listener.Close()
+}
+
+// 服务器使用的所有打包器
+func (s *server) packers() []ipacket.Packer {
+	packers := []ipacket.Packer{s.opts.packer}
+	for _, r := range s.opts.routes {
+		packers = append(packers, r.packer)
+	}
 
-		if s.upgradeHandler != nil && !s.upgradeHandler(w, r) {
+	return packers
+}
+
+// Handler 获取将HTTP请求升级为WS连接的处理器，可挂载到任意路由，未指定打包器时使用默认打包器
+func (s *server) Handler(packer ...ipacket.Packer) http.Handler {
+	p := s.opts.packer
+	if len(packer) > 0 && packer[0] != nil {
+		p = packer[0]
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.upgrade(w, r, p)
+	})
+}
+
+// 将HTTP请求升级为WS连接
+func (s *server) upgrade(w http.ResponseWriter, r *http.Request, packer ipacket.Packer) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if atomic.LoadInt32(&s.serving) == 0 {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	var attrs map[string]interface{}
+
+	if s.upgradeHandler != nil {
+		var allowed bool
+		if attrs, allowed = s.upgradeHandler(w, r); !allowed {
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 			return
 		}
+	}
 
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Printf("websocket upgrade error: %v", err)
-			return
-		}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.opts.logger.Warn("websocket upgrade error", "error", err)
+		return
+	}
 
-		if err = s.connMgr.allocate(conn); err != nil {
-			log.Printf("connection allocate error: %v", err)
-			_ = conn.Close()
-		}
-	})
+	if err = configure(conn, s.opts.readLimit, s.opts.compressionLevel); err != nil {
+		s.opts.logger.Warn("websocket configure error", "error", err)
+		_ = conn.Close()
+		return
+	}
+
+	hs := handshake{
+		packer:   packer,
+		remoteIP: clientIP(r, s.trustedProxies),
+		attrs:    attrs,
+	}
 
+	if err = s.connMgr.allocate(conn, hs); err != nil {
+		s.opts.logger.Warn("connection allocate error", "error", err)
+		_ = conn.Close()
+	}
+}
+
+// 启动服务器，使用私有路由以免多个服务器相互冲突
+func (s *server) serve() {
 	var err error
-	if s.opts.certFile != "" && s.opts.keyFile != "" {
-		err = http.ServeTLS(s.listener, nil, s.opts.certFile, s.opts.keyFile)
+	if s.opts.tlsConfig != nil || (s.opts.certFile != "" && s.opts.keyFile != "") {
+		err = s.httpServer.ServeTLS(s.listener, s.opts.certFile, s.opts.keyFile)
 	} else {
-		err = http.Serve(s.listener, nil)
+		err = s.httpServer.Serve(s.listener)
 	}
 
 	if err != nil {
-		log.Printf("websocket server shutdown, err: %v", err)
+		s.opts.logger.Info("websocket server shutdown", "error", err)
 	}
 }
 
@@ -153,6 +328,18 @@ func (s *server) OnStop(handler network.CloseHandler) {
 
 // OnUpgrade 监听HTTP请求升级
 func (s *server) OnUpgrade(handler UpgradeHandler) {
+	if handler == nil {
+		s.upgradeHandler = nil
+		return
+	}
+
+	s.upgradeHandler = func(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+		return nil, handler(w, r)
+	}
+}
+
+// OnUpgradeAttrs 监听HTTP请求升级，可返回附加到连接上的属性
+func (s *server) OnUpgradeAttrs(handler UpgradeAttrsHandler) {
 	s.upgradeHandler = handler
 }
 
@@ -170,3 +357,179 @@ func (s *server) OnDisconnect(handler network.DisconnectHandler) {
 func (s *server) OnReceive(handler network.ReceiveHandler) {
 	s.receiveHandler = handler
 }
+
+// 处理接收到的消息，依次经过入站拦截器
+func (s *server) receive(conn *serverConn, msg []byte) {
+	if len(s.opts.inbound) == 0 {
+		s.handle(conn, msg)
+		return
+	}
+
+	frame := network.NewFrame(conn, msg, conn.packer)
+
+	if err := network.Intercept(s.opts.inbound, frame, s.invokeReceive); err != nil {
+		s.opts.logger.Warn("inbound interceptor error", "cid", conn.ID(), "error", err)
+	}
+}
+
+// 入站拦截器链的末端，调用接收消息hook函数
+func (s *server) invokeReceive(frame *network.Frame) error {
+	s.handle(frame.Conn(), frame.Data())
+
+	return nil
+}
+
+// 发送消息，依次经过出站拦截器
+func (s *server) send(conn *serverConn, msg []byte, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return write(msg)
+	}
+
+	frame := network.NewFrame(conn, msg, conn.packer)
+
+	return network.Intercept(s.opts.outbound, frame, func(frame *network.Frame) error {
+		return write(frame.Data())
+	})
+}
+
+// 调用接收消息hook函数并统计耗时
+func (s *server) handle(conn network.Conn, msg []byte) {
+	if s.receiveHandler == nil {
+		return
+	}
+
+	start := time.Now()
+	s.receiveHandler(conn, msg)
+	s.opts.metrics.Observe(metrics.HandlerLatency, time.Since(start).Seconds(), s.labels...)
+}
+
+// 注册仪表盘
+func (s *server) register() {
+	s.unregister = append(s.unregister,
+		s.opts.metrics.Gauge(metrics.ConnectionsActive, s.connMgr.active, s.labels...),
+		s.opts.metrics.Gauge(metrics.WriteQueueDepth, s.connMgr.queued, s.labels...),
+	)
+}
+
+// 注销仪表盘
+func (s *server) deregister() {
+	for _, unregister := range s.unregister {
+		unregister()
+	}
+
+	s.unregister = nil
+}
+
+// 统计读取的帧
+func (s *server) countIn(n int) {
+	s.opts.metrics.Add(metrics.FramesIn, 1, s.labels...)
+	s.opts.metrics.Add(metrics.BytesIn, float64(n), s.labels...)
+}
+
+// 统计写入的帧
+func (s *server) countOut(n int) {
+	s.opts.metrics.Add(metrics.FramesOut, 1, s.labels...)
+	s.opts.metrics.Add(metrics.BytesOut, float64(n), s.labels...)
+}
+
+// 统计写入队列溢出并调用溢出hook函数
+func (s *server) overflow(conn network.Conn, dropped []chWrite) {
+	policy := string(s.opts.overflowPolicy)
+	labels := append(append(make([]string, 0, len(s.labels)+2), s.labels...), "policy", policy)
+
+	s.opts.metrics.Add(metrics.WriteQueueOverflows, float64(len(dropped)), labels...)
+
+	for _, w := range dropped {
+		if s.opts.overflowHandler != nil {
+			s.opts.overflowHandler(conn, w.bytes(), s.opts.overflowPolicy)
+		}
+
+		w.release()
+	}
+}
+
+// 发送缓冲区，配置了出站拦截器时拷贝为字节切片后经过出站拦截器发送
+func (s *server) sendBuffer(conn *serverConn, buf buffer.Buffer, writeBuffer func(buf buffer.Buffer) error, write func(msg []byte) error) error {
+	if len(s.opts.outbound) == 0 {
+		return writeBuffer(buf)
+	}
+
+	msg := network.CopyBuffer(buf)
+	buf.Release()
+
+	return s.send(conn, msg, write)
+}
+
+// 解析可信代理，忽略不合法的配置
+func parseTrustedProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+
+	for _, proxy := range proxies {
+		if prefix, ok := parseTrustedProxy(proxy); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// 解析可信代理的IP或CIDR
+func parseTrustedProxy(proxy string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(proxy); err == nil {
+		return prefix.Masked(), true
+	}
+
+	if addr, err := netip.ParseAddr(proxy); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), true
+	}
+
+	return netip.Prefix{}, false
+}
+
+// 获取经可信代理转发的客户端IP，直连的对端不是可信代理或请求未携带代理头部时返回空
+// 从右向左遍历X-Forwarded-For，返回第一个不是可信代理的地址，未携带X-Forwarded-For时使用X-Real-IP
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	if len(trustedProxies) == 0 {
+		return ""
+	}
+
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !isTrusted(peer.Addr(), trustedProxies) {
+		return ""
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return ""
+		}
+
+		if !isTrusted(addr, trustedProxies) || i == 0 {
+			return addr.Unmap().String()
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return ""
+}
+
+// 是否为可信代理
+func isTrusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
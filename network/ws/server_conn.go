@@ -1,12 +1,19 @@
 package ws
 
 import (
+	"context"
+	"crypto/x509"
 	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
 	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/writeq"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"github.com/cute-angelia/go-game-utils/utils/icall"
 	"github.com/cute-angelia/go-game-utils/utils/inet"
 	"github.com/gorilla/websocket"
-	"log"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -20,18 +27,21 @@ type serverConn struct {
 	state             int32           // 连接状态
 	conn              *websocket.Conn // WS源连接
 	connMgr           *serverConnMgr  // 连接管理
+	packer            ipacket.Packer  // 打包器，取决于连接接入的路径
+	remoteIP          string          // 经可信代理转发的客户端IP
 	chLowWrite        chan chWrite    // 低级队列
 	chHighWrite       chan chWrite    // 优先队列
 	done              chan struct{}   // 写入完成信号
 	close             chan struct{}   // 关闭信号
 	lastHeartbeatTime int64           // 上次心跳时间
+	network.Attrs                     // 连接属性
 }
 
 var _ network.Conn = &serverConn{}
 
 // ID 获取连接ID
 func (c *serverConn) ID() int64 {
-	return c.id
+	return atomic.LoadInt64(&c.id)
 }
 
 // UID 获取用户ID
@@ -50,31 +60,54 @@ func (c *serverConn) Unbind() {
 }
 
 // Send 发送消息（同步）
-func (c *serverConn) Send(msg []byte) (err error) {
-	c.rw.RLock()
-	defer c.rw.RUnlock()
-
-	if err = c.checkState(); err != nil {
-		return
-	}
+func (c *serverConn) Send(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.send)
+}
 
-	c.chHighWrite <- chWrite{typ: dataPacket, msg: msg}
+// 发送消息（同步）
+func (c *serverConn) send(msg []byte) error {
+	return c.asyncWrite(c.chHighWrite, chWrite{typ: dataPacket, msg: msg})
+}
 
-	return
+// 发送缓冲区（同步）
+func (c *serverConn) sendBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chHighWrite, chWrite{typ: dataPacket, buf: buf})
 }
 
 // Push 发送消息（异步）
-func (c *serverConn) Push(msg []byte) (err error) {
-	c.rw.RLock()
-	defer c.rw.RUnlock()
+func (c *serverConn) Push(msg []byte) error {
+	return c.connMgr.server.send(c, msg, c.push)
+}
 
-	if err = c.checkState(); err != nil {
-		return
+// PushID 发送消息（异步），连接已被复用为其他连接ID时返回errs.ErrConnectionClosed
+func (c *serverConn) PushID(cid int64, msg []byte) error {
+	if c.ID() != cid {
+		return errs.ErrConnectionClosed
 	}
 
-	c.chLowWrite <- chWrite{typ: dataPacket, msg: msg}
+	return c.connMgr.server.send(c, msg, func(msg []byte) error {
+		return c.asyncWriteID(cid, c.chLowWrite, chWrite{typ: dataPacket, msg: msg})
+	})
+}
+
+// SendBuffer 发送消息（同步），写入完成后释放buf
+func (c *serverConn) SendBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.sendBuffer, c.send)
+}
+
+// PushBuffer 发送消息（异步），写入完成后释放buf
+func (c *serverConn) PushBuffer(buf buffer.Buffer) error {
+	return c.connMgr.server.sendBuffer(c, buf, c.pushBuffer, c.push)
+}
+
+// 发送消息（异步）
+func (c *serverConn) push(msg []byte) error {
+	return c.asyncWrite(c.chLowWrite, chWrite{typ: dataPacket, msg: msg})
+}
 
-	return
+// 发送缓冲区（异步）
+func (c *serverConn) pushBuffer(buf buffer.Buffer) error {
+	return c.asyncWrite(c.chLowWrite, chWrite{typ: dataPacket, buf: buf})
 }
 
 // State 获取连接状态
@@ -112,14 +145,22 @@ func (c *serverConn) LocalAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.LocalAddr(), nil
 }
 
-// RemoteIP 获取远端IP
+// RemoteIP 获取远端IP，配置了可信代理且请求经由可信代理转发时返回代理头部中的客户端IP
 func (c *serverConn) RemoteIP() (string, error) {
+	if c.remoteIP != "" {
+		if err := c.checkState(); err != nil {
+			return "", err
+		}
+
+		return c.remoteIP, nil
+	}
+
 	addr, err := c.RemoteAddr()
 	if err != nil {
 		return "", err
@@ -139,18 +180,52 @@ func (c *serverConn) RemoteAddr() (net.Addr, error) {
 	c.rw.RUnlock()
 
 	if conn == nil {
-		return nil, errors.New("ErrConnectionClosed")
+		return nil, errs.ErrConnectionClosed
 	}
 
 	return conn.RemoteAddr(), nil
 }
 
+// 获取协商的子协议
+func (c *serverConn) subprotocol() string {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+
+	if c.conn == nil {
+		return ""
+	}
+
+	return c.conn.Subprotocol()
+}
+
+// PeerCertificate 获取对端TLS证书
+func (c *serverConn) PeerCertificate() (*x509.Certificate, error) {
+	if err := c.checkState(); err != nil {
+		return nil, err
+	}
+
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	if conn == nil {
+		return nil, errs.ErrConnectionClosed
+	}
+
+	return network.PeerCertificate(conn.NetConn()), nil
+}
+
 // 初始化连接
-func (c *serverConn) init(cm *serverConnMgr, id int64, conn *websocket.Conn) {
-	c.id = id
+func (c *serverConn) init(cm *serverConnMgr, id int64, conn *websocket.Conn, hs handshake) {
+	// 持有写锁更新连接ID，按连接ID推送时持有读锁校验，连接被复用后不会收到推送
+	c.rw.Lock()
+	atomic.StoreInt64(&c.id, id)
+	c.rw.Unlock()
 	c.conn = conn
 	c.connMgr = cm
-	c.chLowWrite = make(chan chWrite, 4096)
+	c.packer = hs.packer
+	c.remoteIP = hs.remoteIP
+	c.chLowWrite = make(chan chWrite, cm.server.opts.writeQueueSize)
 	c.chHighWrite = make(chan chWrite, 1024)
 	c.done = make(chan struct{})
 	c.close = make(chan struct{})
@@ -158,6 +233,10 @@ func (c *serverConn) init(cm *serverConnMgr, id int64, conn *websocket.Conn) {
 	atomic.StoreInt64(&c.uid, 0)
 	atomic.StoreInt32(&c.state, int32(network.ConnOpened))
 
+	for key, value := range hs.attrs {
+		c.Set(key, value)
+	}
+
 	icall.Go(c.read)
 
 	icall.Go(c.write)
@@ -171,9 +250,9 @@ func (c *serverConn) init(cm *serverConnMgr, id int64, conn *websocket.Conn) {
 func (c *serverConn) checkState() error {
 	switch network.ConnState(atomic.LoadInt32(&c.state)) {
 	case network.ConnHanged:
-		return errors.New("ErrConnectionHanged")
+		return errs.ErrConnectionHanged
 	case network.ConnClosed:
-		return errors.New("ErrConnectionClosed")
+		return errs.ErrConnectionClosed
 	default:
 		return nil
 	}
@@ -182,17 +261,21 @@ func (c *serverConn) checkState() error {
 // 优雅关闭
 func (c *serverConn) graceClose(isNeedRecycle bool) error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnHanged)) {
-		return errors.New("ErrConnectionNotOpened")
+		return &errs.StateError{Err: errs.ErrConnectionNotOpened, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.RLock()
+	if c.isClosed() {
+		c.rw.RUnlock()
+		return errs.ErrConnectionClosed
+	}
 	c.chLowWrite <- chWrite{typ: closeSig}
 	c.rw.RUnlock()
 
 	<-c.done
 
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-		return errors.New("ErrConnectionNotHanged")
+		return &errs.StateError{Err: errs.ErrConnectionNotHanged, State: atomic.LoadInt32(&c.state)}
 	}
 
 	c.rw.Lock()
@@ -206,22 +289,59 @@ func (c *serverConn) graceClose(isNeedRecycle bool) error {
 
 	err := conn.Close()
 
+	if c.connMgr.server.disconnectHandler != nil {
+		c.connMgr.server.disconnectHandler(c)
+	}
+
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
 	if isNeedRecycle {
 		c.connMgr.recycle(conn)
 	}
 
-	if c.connMgr.server.disconnectHandler != nil {
-		c.connMgr.server.disconnectHandler(c)
+	return err
+}
+
+// 优雅关闭，先下发关闭消息并排空写入队列，ctx到期后强制关闭
+func (c *serverConn) shutdown(ctx context.Context, goingAway []byte) {
+	if goingAway != nil {
+		c.rw.RLock()
+		if c.checkState() == nil {
+			select {
+			case c.chLowWrite <- chWrite{typ: dataPacket, msg: goingAway}:
+			default:
+			}
+		}
+		c.rw.RUnlock()
 	}
 
-	return err
+	done := make(chan struct{})
+
+	icall.Go(func() {
+		_ = c.graceClose(true)
+		close(done)
+	})
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.rw.RLock()
+		conn := c.conn
+		c.rw.RUnlock()
+
+		// 中断阻塞中的写入
+		if conn != nil {
+			_ = conn.UnderlyingConn().SetDeadline(time.Now())
+		}
+
+		_ = c.forceClose(true)
+	}
 }
 
 // 强制关闭
 func (c *serverConn) forceClose(isNeedRecycle bool) error {
 	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
 		if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnHanged), int32(network.ConnClosed)) {
-			return errors.New("ErrConnectionClosed")
+			return errs.ErrConnectionClosed
 		}
 	}
 
@@ -236,20 +356,25 @@ func (c *serverConn) forceClose(isNeedRecycle bool) error {
 
 	err := conn.Close()
 
-	if isNeedRecycle {
-		c.connMgr.recycle(conn)
-	}
-
 	if c.connMgr.server.disconnectHandler != nil {
 		c.connMgr.server.disconnectHandler(c)
 	}
 
+	// 断开hook执行完毕后再回收，保证hook内仍可读取连接属性
+	if isNeedRecycle {
+		c.connMgr.recycle(conn)
+	}
+
 	return err
 }
 
 // 读取消息
 func (c *serverConn) read() {
+	c.rw.RLock()
 	conn := c.conn
+	c.rw.RUnlock()
+
+	frameType := c.connMgr.server.opts.msgType.frameType()
 
 	for {
 		select {
@@ -260,17 +385,19 @@ func (c *serverConn) read() {
 			if err != nil {
 				if !errors.Is(err, net.ErrClosed) {
 					if _, ok := err.(*websocket.CloseError); !ok {
-						log.Printf("read message failed: %d %v", c.id, err)
+						c.log().Warn("read message failed", "error", err)
 					}
 				}
 				_ = c.forceClose(true)
 				return
 			}
 
-			if msgType != websocket.BinaryMessage {
+			if msgType != frameType {
 				continue
 			}
 
+			c.connMgr.server.countIn(len(msg))
+
 			if c.connMgr.server.opts.heartbeatInterval > 0 {
 				atomic.StoreInt64(&c.lastHeartbeatTime, time.Now().UnixNano())
 			}
@@ -290,9 +417,9 @@ func (c *serverConn) read() {
 			}
 
 			// check heartbeat packet
-			isHeartbeat, err := c.connMgr.server.opts.packer.CheckHeartbeat(msg)
+			isHeartbeat, err := c.packer.CheckHeartbeat(msg)
 			if err != nil {
-				log.Printf("check heartbeat message error: %v", err)
+				c.log().Warn("check heartbeat message error", "error", err)
 				continue
 			}
 
@@ -307,9 +434,7 @@ func (c *serverConn) read() {
 				continue
 			}
 
-			if c.connMgr.server.receiveHandler != nil {
-				c.connMgr.server.receiveHandler(c, msg)
-			}
+			c.connMgr.server.receive(c, msg)
 		}
 	}
 }
@@ -317,10 +442,11 @@ func (c *serverConn) read() {
 // 写入消息
 // 由于gorilla/websocket库并发写入的限制，同时为了保证心跳能够优先下发到客户端，故而实现一个优先队列
 func (c *serverConn) write() {
-	var (
-		conn   = c.conn
-		ticker *time.Ticker
-	)
+	c.rw.RLock()
+	conn := c.conn
+	c.rw.RUnlock()
+
+	var ticker *time.Ticker
 
 	if c.connMgr.server.opts.heartbeatInterval > 0 {
 		ticker = time.NewTicker(c.connMgr.server.opts.heartbeatInterval)
@@ -374,7 +500,9 @@ func (c *serverConn) write() {
 func (c *serverConn) doWrite(conn *websocket.Conn, r chWrite) bool {
 	if r.typ == closeSig {
 		c.rw.RLock()
-		c.done <- struct{}{}
+		if !c.isClosed() {
+			c.done <- struct{}{}
+		}
 		c.rw.RUnlock()
 		return false
 	}
@@ -384,20 +512,24 @@ func (c *serverConn) doWrite(conn *websocket.Conn, r chWrite) bool {
 	}
 
 	if r.typ == heartbeatPacket {
-		if msg, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
-			log.Printf("pack heartbeat message error: %v", err)
+		if msg, err := c.packer.PackHeartbeat(); err != nil {
+			c.log().Error("pack heartbeat message error", "error", err)
 			return true
 		} else {
 			r.msg = msg
 		}
 	}
 
-	if err := conn.WriteMessage(websocket.BinaryMessage, r.msg); err != nil {
+	size := r.len()
+
+	if err := r.writeTo(conn, c.connMgr.server.opts.msgType.frameType(), c.connMgr.server.opts.compressionThreshold); err != nil {
 		if !errors.Is(err, net.ErrClosed) {
 			if _, ok := err.(*websocket.CloseError); !ok {
-				log.Printf("write message error: %v", err)
+				c.log().Warn("write message error", "error", err)
 			}
 		}
+	} else {
+		c.connMgr.server.countOut(size)
 	}
 
 	return true
@@ -407,7 +539,8 @@ func (c *serverConn) doWrite(conn *websocket.Conn, r chWrite) bool {
 func (c *serverConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 	deadline := time.Now().Add(-2 * c.connMgr.server.opts.heartbeatInterval).UnixNano()
 	if atomic.LoadInt64(&c.lastHeartbeatTime) < deadline {
-		log.Printf("connection heartbeat timeout, cid: %d", c.id)
+		c.log().Info("connection heartbeat timeout")
+		c.connMgr.server.opts.metrics.Add(metrics.HeartbeatTimeouts, 1, c.connMgr.server.labels...)
 		_ = c.forceClose(true)
 		return false
 	} else {
@@ -416,12 +549,14 @@ func (c *serverConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 				return false
 			}
 
-			if heartbeat, err := c.connMgr.server.opts.packer.PackHeartbeat(); err != nil {
-				log.Printf("pack heartbeat message error: %v", err)
+			if heartbeat, err := c.packer.PackHeartbeat(); err != nil {
+				c.log().Error("pack heartbeat message error", "error", err)
 			} else {
 				// send heartbeat packet
-				if err := conn.WriteMessage(websocket.BinaryMessage, heartbeat); err != nil {
-					log.Printf("write heartbeat message error: %v", err)
+				if err := conn.WriteMessage(c.connMgr.server.opts.msgType.frameType(), heartbeat); err != nil {
+					c.log().Warn("write heartbeat message error", "error", err)
+				} else {
+					c.connMgr.server.countOut(len(heartbeat))
 				}
 			}
 		}
@@ -430,7 +565,62 @@ func (c *serverConn) doHandleHeartbeat(conn *websocket.Conn) bool {
 	return true
 }
 
+// 异步写入队列，写入队列已满时按溢出策略处理
+func (c *serverConn) asyncWrite(ch chan chWrite, w chWrite) error {
+	return c.asyncWriteID(c.ID(), ch, w)
+}
+
+// 异步写入连接ID为cid的连接的队列，连接已被复用时返回errs.ErrConnectionClosed
+func (c *serverConn) asyncWriteID(cid int64, ch chan chWrite, w chWrite) error {
+	c.rw.RLock()
+	if atomic.LoadInt64(&c.id) != cid {
+		c.rw.RUnlock()
+		w.release()
+		return errs.ErrConnectionClosed
+	}
+	if err := c.checkState(); err != nil {
+		c.rw.RUnlock()
+		w.release()
+		return err
+	}
+	dropped, err := c.enqueue(ch, w)
+	c.rw.RUnlock()
+
+	return c.overflow(dropped, err)
+}
+
+// 写入队列，队列已满时按溢出策略处理，返回被丢弃的消息；调用方需持有读锁
+func (c *serverConn) enqueue(ch chan chWrite, w chWrite) (dropped []chWrite, err error) {
+	opts := c.connMgr.server.opts
+
+	return writeq.Enqueue(ch, w, opts.overflowPolicy, opts.overflowTimeout, func(r chWrite) bool { return r.typ != dataPacket })
+}
+
+// 处理写入队列溢出，disconnect策略下断开连接
+func (c *serverConn) overflow(dropped []chWrite, err error) error {
+	if len(dropped) > 0 {
+		c.connMgr.server.overflow(c, dropped)
+	}
+
+	if err != nil && c.connMgr.server.opts.overflowPolicy == network.OverflowDisconnect {
+		_ = c.forceClose(true)
+	}
+
+	return err
+}
+
 // 是否已关闭
 func (c *serverConn) isClosed() bool {
 	return network.ConnState(atomic.LoadInt32(&c.state)) == network.ConnClosed
 }
+
+// 获取附带连接信息的日志器
+func (c *serverConn) log() logger.Logger {
+	l := c.connMgr.server.opts.logger.With("cid", c.ID(), "uid", c.UID())
+
+	if addr, err := c.RemoteAddr(); err == nil {
+		l = l.With("remote", addr.String())
+	}
+
+	return l
+}
@@ -1,6 +1,12 @@
 package ws
 
 import (
+	"compress/flate"
+	"crypto/tls"
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/network"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"net/http"
 	"time"
@@ -14,6 +20,13 @@ const (
 	defaultServerHandshakeTimeout   = time.Second * 10
 	defaultServerHeartbeatInterval  = time.Second * 10
 	defaultServerHeartbeatMechanism = "resp"
+	defaultServerWriteQueueSize     = 4096
+	defaultServerOverflowPolicy     = network.OverflowBlock
+	defaultServerMessageType        = BinaryMessage
+	defaultServerReadBufferSize     = 4096
+	defaultServerWriteBufferSize    = 4096
+	defaultServerCompression        = true
+	defaultServerCompressionLevel   = flate.BestSpeed
 
 	defaultServerKeyFile  = ""
 	defaultServerCertFile = ""
@@ -30,18 +43,44 @@ type ServerOption func(o *serverOptions)
 
 type CheckOriginFunc func(r *http.Request) bool
 
+type route struct {
+	path   string
+	packer ipacket.Packer
+}
+
 type serverOptions struct {
-	addr               string             // 监听地址
-	maxConnNum         int                // 最大连接数
-	certFile           string             // 证书文件
-	keyFile            string             // 秘钥文件
-	path               string             // 路径，默认为"/"
-	checkOrigin        CheckOriginFunc    // 跨域检测
-	handshakeTimeout   time.Duration      // 握手超时时间，默认10s
-	heartbeatInterval  time.Duration      // 心跳间隔时间，默认10s
-	heartbeatMechanism HeartbeatMechanism // 心跳机制，默认resp
+	addr               string                  // 监听地址
+	maxConnNum         int                     // 最大连接数
+	certFile           string                  // 证书文件
+	keyFile            string                  // 秘钥文件
+	tlsConfig          *tls.Config             // TLS配置，为nil且未设置证书文件时不启用TLS
+	path               string                  // 路径，默认为"/"
+	msgType            MessageType             // 消息帧类型，默认binary
+	routes             []route                 // 使用其他打包器的路径
+	subprotocols       []string                // 支持的子协议，按优先级排列
+	trustedProxies     []string                // 可信代理的IP或CIDR
+	checkOrigin        CheckOriginFunc         // 跨域检测
+	handshakeTimeout   time.Duration           // 握手超时时间，默认10s
+	heartbeatInterval  time.Duration           // 心跳间隔时间，默认10s
+	heartbeatMechanism HeartbeatMechanism      // 心跳机制，默认resp
+	goingAway          ipacket.Message         // 优雅关闭时下发给客户端的消息
+	writeQueueSize     int                     // 写入队列大小，默认4096
+	overflowPolicy     network.OverflowPolicy  // 写入队列溢出策略，默认block
+	overflowTimeout    time.Duration           // block策略的最长等待时间，默认为0表示一直等待
+	overflowHandler    network.OverflowHandler // 写入队列溢出hook函数
 
-	packer ipacket.Packer
+	readBufferSize       int   // 读缓冲区大小，默认4096
+	writeBufferSize      int   // 写缓冲区大小，默认4096
+	readLimit            int64 // 单条消息最大字节数，超过时关闭连接，默认为0表示不限制
+	compression          bool  // 是否协商启用消息压缩，默认开启
+	compressionLevel     int   // 压缩级别，取值范围为-2~9，默认为1
+	compressionThreshold int   // 压缩阈值，小于该字节数的消息不压缩，默认为0表示全部压缩
+
+	inbound  []network.Interceptor // 入站拦截器
+	outbound []network.Interceptor // 出站拦截器
+	logger   logger.Logger         // 日志器，默认使用全局日志器
+	metrics  metrics.Metrics       // 指标采集器，默认使用全局采集器
+	packer   ipacket.Packer
 }
 
 func defaultServerOptions() *serverOptions {
@@ -65,16 +104,63 @@ func defaultServerOptions() *serverOptions {
 		addr:               defaultServerAddr,
 		maxConnNum:         defaultServerMaxConnNum,
 		path:               defaultServerPath,
+		msgType:            defaultServerMessageType,
+		readBufferSize:     defaultServerReadBufferSize,
+		writeBufferSize:    defaultServerWriteBufferSize,
+		compression:        defaultServerCompression,
+		compressionLevel:   defaultServerCompressionLevel,
 		checkOrigin:        checkOrigin,
 		keyFile:            defaultServerKeyFile,
 		certFile:           defaultServerCertFile,
 		handshakeTimeout:   defaultServerHandshakeTimeout,
 		heartbeatInterval:  defaultServerHeartbeatInterval,
 		heartbeatMechanism: HeartbeatMechanism(defaultServerHeartbeatMechanism),
+		writeQueueSize:     defaultServerWriteQueueSize,
+		overflowPolicy:     defaultServerOverflowPolicy,
+		logger:             logger.Default(),
+		metrics:            metrics.Default(),
+	}
+}
+
+// 校验配置，返回所有不合法的配置项
+func (o *serverOptions) validate() error {
+	var problems []string
+
+	if o.writeQueueSize < 0 {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than or equal to 0, and give %d", o.writeQueueSize))
+	}
+
+	// 无缓冲队列无法丢弃最旧的消息
+	if o.writeQueueSize == 0 && o.overflowPolicy == network.OverflowDropOldest {
+		problems = append(problems, fmt.Sprintf("the write queue size must be greater than 0 when the overflow policy is %s", o.overflowPolicy))
+	}
+
+	if o.compressionLevel < flate.HuffmanOnly || o.compressionLevel > flate.BestCompression {
+		problems = append(problems, fmt.Sprintf("the compression level must be between %d and %d, and give %d", flate.HuffmanOnly, flate.BestCompression, o.compressionLevel))
+	}
+
+	paths := map[string]struct{}{o.path: {}}
+	for _, r := range o.routes {
+		if _, ok := paths[r.path]; ok {
+			problems = append(problems, fmt.Sprintf("the route path must be unique, and give duplicate %q", r.path))
+		}
+		paths[r.path] = struct{}{}
 	}
+
+	for _, proxy := range o.trustedProxies {
+		if _, ok := parseTrustedProxy(proxy); !ok {
+			problems = append(problems, fmt.Sprintf("the trusted proxy must be an IP or CIDR, and give %q", proxy))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &network.ConfigError{Protocol: protocol, Problems: problems}
+	}
+
+	return nil
 }
 
-// WithServerListenAddr 设置监听地址
+// WithServerListenAddr 设置监听地址，设置为空时不监听端口，仅通过Handler挂载到已有的HTTP服务上
 func WithServerListenAddr(addr string) ServerOption {
 	return func(o *serverOptions) { o.addr = addr }
 }
@@ -89,11 +175,66 @@ func WithServerPath(path string) ServerOption {
 	return func(o *serverOptions) { o.path = path }
 }
 
+// WithServerReadBufferSize 设置读缓冲区大小
+func WithServerReadBufferSize(size int) ServerOption {
+	return func(o *serverOptions) { o.readBufferSize = size }
+}
+
+// WithServerWriteBufferSize 设置写缓冲区大小
+func WithServerWriteBufferSize(size int) ServerOption {
+	return func(o *serverOptions) { o.writeBufferSize = size }
+}
+
+// WithServerReadLimit 设置单条消息最大字节数，读取超过该长度的消息时关闭连接
+func WithServerReadLimit(limit int64) ServerOption {
+	return func(o *serverOptions) { o.readLimit = limit }
+}
+
+// WithServerCompression 设置是否协商启用消息压缩（permessage-deflate）
+func WithServerCompression(enable bool) ServerOption {
+	return func(o *serverOptions) { o.compression = enable }
+}
+
+// WithServerCompressionLevel 设置压缩级别，取值范围为-2~9，参考compress/flate
+func WithServerCompressionLevel(level int) ServerOption {
+	return func(o *serverOptions) { o.compressionLevel = level }
+}
+
+// WithServerCompressionThreshold 设置压缩阈值，小于该字节数的消息不压缩
+func WithServerCompressionThreshold(threshold int) ServerOption {
+	return func(o *serverOptions) { o.compressionThreshold = threshold }
+}
+
+// WithServerMessageType 设置消息帧类型，仅收发该类型的消息帧，其他类型的消息帧将被忽略
+func WithServerMessageType(msgType MessageType) ServerOption {
+	return func(o *serverOptions) { o.msgType = msgType }
+}
+
+// WithServerRoute 添加路径，该路径上接入的连接使用指定的打包器，与默认路径共用同一端口
+func WithServerRoute(path string, packer ipacket.Packer) ServerOption {
+	return func(o *serverOptions) { o.routes = append(o.routes, route{path: path, packer: packer}) }
+}
+
 // WithServerCredentials 设置证书和秘钥
 func WithServerCredentials(certFile, keyFile string) ServerOption {
 	return func(o *serverOptions) { o.keyFile, o.certFile = keyFile, certFile }
 }
 
+// WithServerTLSConfig 设置TLS配置，可与证书文件同时使用，需要校验客户端证书时设置ClientAuth与ClientCAs
+func WithServerTLSConfig(config *tls.Config) ServerOption {
+	return func(o *serverOptions) { o.tlsConfig = config }
+}
+
+// WithServerSubprotocols 设置支持的子协议，按优先级排列，握手时选择列表中第一个客户端请求的子协议
+func WithServerSubprotocols(subprotocols ...string) ServerOption {
+	return func(o *serverOptions) { o.subprotocols = subprotocols }
+}
+
+// WithServerTrustedProxies 设置可信代理的IP或CIDR，经可信代理转发的连接从X-Forwarded-For或X-Real-IP中获取客户端IP
+func WithServerTrustedProxies(proxies ...string) ServerOption {
+	return func(o *serverOptions) { o.trustedProxies = proxies }
+}
+
 // WithServerCheckOrigin 设置Websocket跨域检测函数
 func WithServerCheckOrigin(checkOrigin CheckOriginFunc) ServerOption {
 	return func(o *serverOptions) { o.checkOrigin = checkOrigin }
@@ -118,3 +259,48 @@ func WithServerPacker(packer ipacket.Packer) ServerOption {
 		o.packer = packer
 	}
 }
+
+// WithServerGoingAway 设置优雅关闭时下发给客户端的消息，使用服务器的打包器打包
+func WithServerGoingAway(message ipacket.Message) ServerOption {
+	return func(o *serverOptions) { o.goingAway = message }
+}
+
+// WithServerWriteQueueSize 设置写入队列大小，不可小于0，drop_oldest策略下须大于0
+func WithServerWriteQueueSize(size int) ServerOption {
+	return func(o *serverOptions) { o.writeQueueSize = size }
+}
+
+// WithServerOverflowPolicy 设置写入队列溢出策略，timeout为block策略的最长等待时间
+func WithServerOverflowPolicy(policy network.OverflowPolicy, timeout ...time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.overflowPolicy = policy
+		if len(timeout) > 0 {
+			o.overflowTimeout = timeout[0]
+		}
+	}
+}
+
+// WithServerOverflowHandler 设置写入队列溢出hook函数
+func WithServerOverflowHandler(handler network.OverflowHandler) ServerOption {
+	return func(o *serverOptions) { o.overflowHandler = handler }
+}
+
+// WithServerLogger 设置日志器
+func WithServerLogger(l logger.Logger) ServerOption {
+	return func(o *serverOptions) { o.logger = l }
+}
+
+// WithServerMetrics 设置指标采集器
+func WithServerMetrics(m metrics.Metrics) ServerOption {
+	return func(o *serverOptions) { o.metrics = m }
+}
+
+// WithServerInboundInterceptors 添加入站拦截器，按添加顺序环绕接收消息hook函数
+func WithServerInboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.inbound = append(o.inbound, interceptors...) }
+}
+
+// WithServerOutboundInterceptors 添加出站拦截器，按添加顺序环绕Send与Push
+func WithServerOutboundInterceptors(interceptors ...network.Interceptor) ServerOption {
+	return func(o *serverOptions) { o.outbound = append(o.outbound, interceptors...) }
+}
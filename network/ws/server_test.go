@@ -0,0 +1,376 @@
+package ws_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/ws"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/envelope"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"github.com/gorilla/websocket"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenAddr(t testing.TB) string {
+	port, err := inet.AssignRandPort("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+func TestServerConn_PushBuffer(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(packer),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	received := make(chan []byte, 1)
+
+	client := ws.NewClient(ws.WithClientDialUrl("ws://"+addr), ws.WithClientPacker(packer))
+	client.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	conn := <-connected
+
+	message := &due.Message{Route: 1, Buffer: bytes.Repeat([]byte("x"), 1024)}
+
+	buf, err := packer.PackBuffer(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = conn.PushBuffer(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓冲区的多个节点应组装为一个消息帧
+	select {
+	case msg := <-received:
+		want, err := packer.PackMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(msg, want) {
+			t.Fatalf("received %d bytes, want %d bytes", len(msg), len(want))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestServer_Handler(t *testing.T) {
+	packer := due.NewPacker()
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(""),
+		ws.WithServerPacker(packer),
+	)
+
+	connected := make(chan network.Conn, 1)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	// 与已有的HTTP接口共用端口
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) })
+	mux.Handle("/ws", server.Handler())
+
+	hs := httptest.NewServer(mux)
+	defer hs.Close()
+
+	url := "ws" + strings.TrimPrefix(hs.URL, "http") + "/ws"
+
+	// 启动前拒绝升级
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dial before start: err = %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+
+	resp, err := http.Get(hs.URL + "/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != "ok" {
+		t.Fatalf("admin response = %q, want %q", body, "ok")
+	}
+
+	if err = server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭后拒绝升级
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dial after stop: err = %v, want status %d", err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestServer_Routes(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+	v2 := due.NewPacker(due.WithByteOrder(binary.LittleEndian), due.WithHeartbeatTime(true))
+
+	// 同一进程内的多个服务器使用各自的路由，相同路径互不冲突
+	for i := 0; i < 2; i++ {
+		other := ws.NewServer(ws.WithServerListenAddr(listenAddr(t)), ws.WithServerPacker(packer))
+		if err := other.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer other.Stop()
+	}
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(packer),
+		ws.WithServerRoute("/v2", v2),
+	)
+
+	received := make(chan []byte, 1)
+	server.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// 默认路径使用默认打包器
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		if !bytes.Equal(msg, data) {
+			t.Fatalf("received %v, want %v", msg, data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	// 其他路径使用对应的打包器识别心跳，默认打包器无法识别小端序的心跳包
+	conn2, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/v2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	heartbeat, err := v2.PackHeartbeat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = conn2.WriteMessage(websocket.BinaryMessage, heartbeat); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn2.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, msg, err := conn2.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isHeartbeat, err := v2.CheckHeartbeat(msg); err != nil || !isHeartbeat {
+		t.Fatalf("response %v is not a heartbeat: %v", msg, err)
+	}
+
+	select {
+	case msg := <-received:
+		t.Fatalf("heartbeat should not be delivered, received %v", msg)
+	default:
+	}
+}
+
+func TestServer_DuplicateRoutes(t *testing.T) {
+	packer := due.NewPacker()
+
+	for name, opts := range map[string][]ws.ServerOption{
+		"DefaultPath": {ws.WithServerPath("/ws"), ws.WithServerRoute("/ws", packer)},
+		"Route":       {ws.WithServerRoute("/v2", packer), ws.WithServerRoute("/v2", packer)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts = append(opts, ws.WithServerListenAddr(listenAddr(t)))
+
+			var configErr *network.ConfigError
+			if _, err := ws.NewServerE(opts...); !errors.As(err, &configErr) {
+				t.Fatalf("expected config error, got %v", err)
+			}
+
+			if err := ws.NewServer(opts...).Start(); !errors.As(err, &configErr) {
+				t.Fatalf("expected start to return config error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestServer_StopClosesHTTPConns(t *testing.T) {
+	addr := listenAddr(t)
+
+	server := ws.NewServer(ws.WithServerListenAddr(addr), ws.WithServerPacker(due.NewPacker()))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 请求头未发送完毕，连接停留在HTTP服务器中
+	if _, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err = server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected http conn closed after stop, got %v", err)
+	}
+}
+
+func TestServer_TextMessage(t *testing.T) {
+	addr := listenAddr(t)
+	packer := envelope.NewPacker()
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(packer),
+		ws.WithServerMessageType(ws.TextMessage),
+	)
+
+	server.OnReceive(func(conn network.Conn, msg []byte) {
+		if err := conn.Push(msg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 文本模式下忽略二进制帧
+	if err = conn.WriteMessage(websocket.BinaryMessage, []byte(`{"route":1,"data":"binary"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"route":1,"seq":1,"data":"text"}`)
+	if err = conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	msgType, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msgType != websocket.TextMessage || !bytes.Equal(msg, data) {
+		t.Fatalf("received frame type %d with %s, want text frame with %s", msgType, msg, data)
+	}
+
+	// 客户端使用文本模式收发
+	received := make(chan []byte, 1)
+
+	client := ws.NewClient(
+		ws.WithClientDialUrl("ws://"+addr),
+		ws.WithClientPacker(packer),
+		ws.WithClientMessageType(ws.TextMessage),
+	)
+	client.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	if data, err = packer.PackMessage(envelope.NewMessage(2, 3, []byte(`{"name":"fuxi"}`))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.Push(data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := message.(*envelope.Message); m.Route != 2 || m.Seq != 3 || string(m.Data) != `{"name":"fuxi"}` {
+			t.Fatalf("received %+v", m)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
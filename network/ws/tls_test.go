@@ -0,0 +1,112 @@
+package ws_test
+
+import (
+	"crypto/tls"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/internal/testcert"
+	"github.com/cute-angelia/go-game-utils/network/ws"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"testing"
+	"time"
+)
+
+func TestServer_MutualTLS(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+	ca := testcert.NewCA(t)
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPath("/tls"),
+		ws.WithServerPacker(packer),
+		ws.WithServerTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{ca.Certificate(t, "server")},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.Pool(),
+		}),
+	)
+
+	connected := make(chan network.Conn, 2)
+	server.OnConnect(func(conn network.Conn) { connected <- conn })
+
+	received := make(chan []byte, 1)
+	server.OnReceive(func(conn network.Conn, msg []byte) { received <- msg })
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := ws.NewClient(
+		ws.WithClientDialUrl("wss://"+addr+"/tls"),
+		ws.WithClientPacker(packer),
+		ws.WithClientTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{ca.Certificate(t, "client")},
+			RootCAs:      ca.Pool(),
+		}),
+	)
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	var conn network.Conn
+	select {
+	case conn = <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+
+	for name, c := range map[string]network.Conn{"client": conn, "server": cc} {
+		cert, err := c.PeerCertificate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cert == nil || cert.Subject.CommonName != name {
+			t.Fatalf("peer certificate = %v, want common name %q", cert, name)
+		}
+	}
+
+	data, err := packer.PackMessage(&due.Message{Route: 1, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cc.Send(data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		message, err := packer.UnpackMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(message.(*due.Message).Buffer) != "hello" {
+			t.Fatalf("received %q, want %q", message.(*due.Message).Buffer, "hello")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	// 未提供客户端证书的连接无法完成握手
+	untrusted := ws.NewClient(
+		ws.WithClientDialUrl("wss://"+addr+"/tls"),
+		ws.WithClientPacker(packer),
+		ws.WithClientTLSConfig(&tls.Config{RootCAs: ca.Pool()}),
+	)
+
+	if uc, err := untrusted.Dial(); err == nil {
+		uc.Close()
+		t.Fatal("dial without client certificate should fail")
+	}
+
+	select {
+	case <-connected:
+		t.Fatal("connection without client certificate should be rejected")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,178 @@
+package ws_test
+
+import (
+	"errors"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/network/ws"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_UpgradeAttrs(t *testing.T) {
+	addr := listenAddr(t)
+	packer := due.NewPacker()
+
+	server := ws.NewServer(
+		ws.WithServerListenAddr(addr),
+		ws.WithServerPacker(packer),
+		ws.WithServerSubprotocols("game.v2", "game.v1"),
+	)
+
+	server.OnUpgradeAttrs(func(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			return nil, false
+		}
+
+		return map[string]interface{}{"token": token}, true
+	})
+
+	type result struct {
+		token       interface{}
+		subprotocol string
+	}
+
+	connected := make(chan result, 1)
+	server.OnConnect(func(conn network.Conn) {
+		token, _ := conn.Get("token")
+		connected <- result{token: token, subprotocol: ws.Subprotocol(conn)}
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// 升级hook函数拒绝的请求
+	if _, resp, err := websocket.DefaultDialer.Dial("ws://"+addr, nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("dial without token: err = %v, want status %d", err, http.StatusForbidden)
+	}
+
+	client := ws.NewClient(
+		ws.WithClientDialUrl("ws://"+addr+"/?token=abc"),
+		ws.WithClientPacker(packer),
+		ws.WithClientSubprotocols("game.v1", "game.v2"),
+	)
+
+	cc, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	// 属性在连接打开hook函数调用前已附加到连接上
+	select {
+	case r := <-connected:
+		if r.token != "abc" {
+			t.Fatalf("token attr = %v, want %q", r.token, "abc")
+		}
+
+		if r.subprotocol != "game.v2" {
+			t.Fatalf("server subprotocol = %q, want %q", r.subprotocol, "game.v2")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for connection")
+	}
+
+	if subprotocol := ws.Subprotocol(cc); subprotocol != "game.v2" {
+		t.Fatalf("client subprotocol = %q, want %q", subprotocol, "game.v2")
+	}
+}
+
+func TestServer_TrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		header  http.Header
+		want    string
+	}{
+		{
+			name:   "Untrusted",
+			header: http.Header{"X-Forwarded-For": {"203.0.113.9"}},
+			want:   "127.0.0.1",
+		},
+		{
+			name:    "ForwardedFor",
+			proxies: []string{"127.0.0.1", "10.0.0.0/8"},
+			header:  http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.9", "10.0.0.2"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "RealIP",
+			proxies: []string{"127.0.0.0/8"},
+			header:  http.Header{"X-Real-Ip": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "NoHeader",
+			proxies: []string{"127.0.0.0/8"},
+			want:    "127.0.0.1",
+		},
+		{
+			name:    "InvalidHop",
+			proxies: []string{"127.0.0.0/8"},
+			header:  http.Header{"X-Forwarded-For": {"unknown"}},
+			want:    "127.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := listenAddr(t)
+
+			server := ws.NewServer(
+				ws.WithServerListenAddr(addr),
+				ws.WithServerPacker(due.NewPacker()),
+				ws.WithServerTrustedProxies(tt.proxies...),
+			)
+
+			remoteIP := make(chan string, 1)
+			server.OnConnect(func(conn network.Conn) {
+				ip, err := conn.RemoteIP()
+				if err != nil {
+					t.Error(err)
+				}
+				remoteIP <- ip
+			})
+
+			if err := server.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer server.Stop()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			select {
+			case ip := <-remoteIP:
+				if ip != tt.want {
+					t.Fatalf("remote ip = %q, want %q", ip, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout waiting for connection")
+			}
+		})
+	}
+}
+
+func TestServer_InvalidTrustedProxies(t *testing.T) {
+	opts := []ws.ServerOption{
+		ws.WithServerListenAddr(""),
+		ws.WithServerTrustedProxies("10.0.0.0/8", "proxy.local", "300.0.0.1"),
+	}
+
+	var configErr *network.ConfigError
+	if _, err := ws.NewServerE(opts...); !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Fatalf("expected config error with 2 problems, got %v", err)
+	}
+
+	if err := ws.NewServer(opts...).Start(); !errors.As(err, &configErr) {
+		t.Fatalf("expected start to return config error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package due
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"testing"
+)
+
+// 种子语料位于testdata/fuzz
+
+func FuzzPacker_ReadMessage(f *testing.F) {
+	packer := NewPacker(WithMaxFrameBytes(256))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reader := range []interface{}{bytes.NewReader(data), buffer.NewConnReader(bytes.NewReader(data), 16)} {
+			for i := 0; i <= len(data); i++ {
+				frame, err := packer.ReadMessage(reader)
+				if err != nil {
+					break
+				}
+
+				if len(frame) != 0 && (len(frame) < minFrameBytes || len(frame) > packer.reader.MaxFrameBytes()) {
+					t.Fatalf("frame length %d out of range [%d, %d]", len(frame), minFrameBytes, packer.reader.MaxFrameBytes())
+				}
+			}
+		}
+	})
+}
+
+func FuzzPacker_UnpackMessage(f *testing.F) {
+	packer := NewPacker()
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := packer.UnpackMessage(data)
+		if err != nil {
+			return
+		}
+
+		message := msg.(*Message)
+
+		if len(message.Buffer) > packer.opts.bufferBytes {
+			return
+		}
+
+		// 解包成功的消息重新打包后内容不变
+		repacked, err := packer.PackMessage(message)
+		if err != nil {
+			t.Fatalf("pack unpacked message: %v", err)
+		}
+
+		msg, err = packer.UnpackMessage(repacked)
+		if err != nil {
+			t.Fatalf("unpack repacked message: %v", err)
+		}
+
+		if again := msg.(*Message); again.Seq != message.Seq || again.Route != message.Route || !bytes.Equal(again.Buffer, message.Buffer) {
+			t.Fatalf("repacked message %+v, want %+v", again, message)
+		}
+	})
+}
+
+func FuzzPacker_CheckHeartbeat(f *testing.F) {
+	packer := NewPacker(WithHeartbeatTime(true))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		isHeartbeat, err := packer.CheckHeartbeat(data)
+		if err != nil {
+			return
+		}
+
+		if want := data[defaultSizeBytes]&heartbeatBit == heartbeatBit; isHeartbeat != want {
+			t.Fatalf("heartbeat = %v, want %v", isHeartbeat, want)
+		}
+	})
+}
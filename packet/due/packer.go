@@ -3,11 +3,12 @@ package due
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/internal/frame"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
-	"io"
-	"log"
 	"sync"
 	"time"
 )
@@ -17,47 +18,65 @@ const (
 	heartbeatBit = 1 << 7 // 心跳标识
 )
 
-type NocopyReader interface {
-	// Next returns a slice containing the next n bytes from the buffer,
-	// advancing the buffer as if the bytes had been returned by Read.
-	Next(n int) (p []byte, err error)
+// NocopyReader 无拷贝读取器
+type NocopyReader = buffer.NocopyReader
 
-	// Peek returns the next n bytes without advancing the reader.
-	Peek(n int) (buf []byte, err error)
-
-	// Release the memory space occupied by all read slices.
-	Release() (err error)
+var (
+	_ ipacket.Packer      = &Packer{}
+	_ ipacket.LeaseReader = &Packer{}
+)
 
-	Slice(n int) (r NocopyReader, err error)
-}
+// 数据帧最小字节数，即长度字段与固定头部的字节数
+const minFrameBytes = defaultSizeBytes + defaultHeaderBytes
 
 type Packer struct {
-	opts             *options
-	once             sync.Once
-	heartbeat        []byte
-	readerSizePool   sync.Pool
-	readerBufferPool sync.Pool
+	opts      *options
+	once      sync.Once
+	heartbeat []byte
+	reader    *frame.Reader
 }
 
+// NewPacker 创建打包器，配置不合法时退出进程
 func NewPacker(opts ...Option) *Packer {
 	o := defaultOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
-	if o.routeBytes != 1 && o.routeBytes != 2 && o.routeBytes != 4 {
-		log.Fatalf("the number of route bytes must be 1、2、4, and give %d", o.routeBytes)
+	p, err := newPacker(o)
+	if err != nil {
+		logger.Fatal(o.logger, "create packer failed", "error", err)
 	}
 
-	if o.seqBytes != 0 && o.seqBytes != 1 && o.seqBytes != 2 && o.seqBytes != 4 {
-		log.Fatalf("the number of seq bytes must be 0、1、2、4, and give %d", o.seqBytes)
+	return p
+}
+
+// NewPackerE 创建打包器，配置不合法时返回*ipacket.ConfigError
+func NewPackerE(opts ...Option) (*Packer, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	if o.bufferBytes < 0 {
-		log.Fatalf("the number of buffer bytes must be greater than or equal to 0, and give %d", o.bufferBytes)
+	return newPacker(o)
+}
+
+func newPacker(o *options) (*Packer, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
 	}
 
 	p := &Packer{opts: o}
+	p.reader = frame.NewReader(frame.Config{
+		Name:          Name,
+		SizeBytes:     defaultSizeBytes,
+		SizeOffset:    defaultSizeBytes,
+		MinFrameBytes: minFrameBytes,
+		MaxFrameBytes: o.frameLimit(),
+		PoolBytes:     defaultSizeBytes + defaultHeaderBytes + o.routeBytes + o.seqBytes + o.bufferBytes,
+		ByteOrder:     o.byteOrder,
+		Metrics:       o.metrics,
+	})
 
 	if !o.heartbeatTime {
 		buf := &bytes.Buffer{}
@@ -71,113 +90,45 @@ func NewPacker(opts ...Option) *Packer {
 		p.heartbeat = buf.Bytes()
 	}
 
-	p.readerSizePool = sync.Pool{New: func() any { return make([]byte, defaultSizeBytes) }}
-
-	p.readerBufferPool = sync.Pool{New: func() any {
-		return make([]byte, defaultSizeBytes+defaultHeaderBytes+o.routeBytes+o.seqBytes+o.bufferBytes)
-	}}
-
-	return p
+	return p, nil
 }
 
-// ReadMessage 读取消息
+// ReadMessage 读取消息，返回的数据归调用方所有，数据帧长度不合法时返回*errs.SizeError
 func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
-	switch r := reader.(type) {
-	case NocopyReader:
-		return p.nocopyReadMessage(r)
-	case io.Reader:
-		return p.copyReadMessage(r)
-	default:
-		return nil, errors.New("ErrInvalidReader")
-	}
+	return p.reader.ReadMessage(reader)
 }
 
-// 无拷贝读取消息
-func (p *Packer) nocopyReadMessage(reader NocopyReader) ([]byte, error) {
-	buf, err := reader.Peek(defaultSizeBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	var size uint32
-
-	if p.opts.byteOrder == binary.BigEndian {
-		size = binary.BigEndian.Uint32(buf)
-	} else {
-		size = binary.LittleEndian.Uint32(buf)
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
-
-	n := int(defaultSizeBytes + size)
-
-	r, err := reader.Slice(n)
-	if err != nil {
-		return nil, err
-	}
-
-	buf, err = r.Next(n)
-	if err != nil {
-		return nil, err
-	}
-
-	if err = reader.Release(); err != nil {
-		return nil, err
-	}
-
-	return buf, nil
+// ReadLease 读取消息，无拷贝读取时数据引用读取器的内存块，拷贝读取时数据位于池化内存中，使用完毕后须调用Lease.Release归还
+func (p *Packer) ReadLease(reader interface{}) (*ipacket.Lease, error) {
+	return p.reader.ReadLease(reader)
 }
 
-// 拷贝读取消息
-func (p *Packer) copyReadMessage(reader io.Reader) ([]byte, error) {
-	buf := make([]byte, defaultSizeBytes)
-
-	_, err := io.ReadFull(reader, buf)
-	if err != nil {
-		return nil, err
-	}
-
-	var size uint32
-
-	if p.opts.byteOrder == binary.BigEndian {
-		size = binary.BigEndian.Uint32(buf)
-	} else {
-		size = binary.LittleEndian.Uint32(buf)
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
-
-	data := make([]byte, defaultSizeBytes+size)
-	copy(data[:defaultSizeBytes], buf)
-
-	_, err = io.ReadFull(reader, data[defaultSizeBytes:])
+// PackMessage 打包消息
+func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+	data, err := p.packMessage(messageIn)
 	if err != nil {
-		return nil, err
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "pack")
 	}
 
-	return data, nil
+	return data, err
 }
 
-// PackMessage 打包消息
-func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+// 打包消息
+func (p *Packer) packMessage(messageIn ipacket.Message) ([]byte, error) {
 	message := messageIn.(*Message)
 
-	if message.Route > int32(1<<(8*p.opts.routeBytes-1)-1) || message.Route < int32(-1<<(8*p.opts.routeBytes-1)) {
-		return nil, errors.New("ErrRouteOverflow")
+	if min, max := int32(-1<<(8*p.opts.routeBytes-1)), int32(1<<(8*p.opts.routeBytes-1)-1); message.Route > max || message.Route < min {
+		return nil, &errs.RangeError{Err: errs.ErrRouteOverflow, Value: int64(message.Route), Min: int64(min), Max: int64(max)}
 	}
 
 	if p.opts.seqBytes > 0 {
-		if message.Seq > int32(1<<(8*p.opts.seqBytes-1)-1) || message.Seq < int32(-1<<(8*p.opts.seqBytes-1)) {
-			return nil, errors.New("ErrSeqOverflow")
+		if min, max := int32(-1<<(8*p.opts.seqBytes-1)), int32(1<<(8*p.opts.seqBytes-1)-1); message.Seq > max || message.Seq < min {
+			return nil, &errs.RangeError{Err: errs.ErrSeqOverflow, Value: int64(message.Seq), Min: int64(min), Max: int64(max)}
 		}
 	}
 
 	if len(message.Buffer) > p.opts.bufferBytes {
-		return nil, errors.New("ErrMessageTooLarge")
+		return nil, &errs.SizeError{Err: errs.ErrMessageTooLarge, Size: len(message.Buffer), Limit: p.opts.bufferBytes}
 	}
 
 	var (
@@ -231,18 +182,28 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 
 // PackBuffer 打包消息
 func (p *Packer) PackBuffer(message *Message) (buffer.Buffer, error) {
-	if message.Route > int32(1<<(8*p.opts.routeBytes-1)-1) || message.Route < int32(-1<<(8*p.opts.routeBytes-1)) {
-		return nil, errors.New("ErrRouteOverflow")
+	buf, err := p.packBuffer(message)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "pack")
+	}
+
+	return buf, err
+}
+
+// 打包消息
+func (p *Packer) packBuffer(message *Message) (buffer.Buffer, error) {
+	if min, max := int32(-1<<(8*p.opts.routeBytes-1)), int32(1<<(8*p.opts.routeBytes-1)-1); message.Route > max || message.Route < min {
+		return nil, &errs.RangeError{Err: errs.ErrRouteOverflow, Value: int64(message.Route), Min: int64(min), Max: int64(max)}
 	}
 
 	if p.opts.seqBytes > 0 {
-		if message.Seq > int32(1<<(8*p.opts.seqBytes-1)-1) || message.Seq < int32(-1<<(8*p.opts.seqBytes-1)) {
-			return nil, errors.New("ErrSeqOverflow")
+		if min, max := int32(-1<<(8*p.opts.seqBytes-1)), int32(1<<(8*p.opts.seqBytes-1)-1); message.Seq > max || message.Seq < min {
+			return nil, &errs.RangeError{Err: errs.ErrSeqOverflow, Value: int64(message.Seq), Min: int64(min), Max: int64(max)}
 		}
 	}
 
 	if len(message.Buffer) > p.opts.bufferBytes {
-		return nil, errors.New("ErrMessageTooLarge")
+		return nil, &errs.SizeError{Err: errs.ErrMessageTooLarge, Size: len(message.Buffer), Limit: p.opts.bufferBytes}
 	}
 
 	var (
@@ -279,6 +240,16 @@ func (p *Packer) PackBuffer(message *Message) (buffer.Buffer, error) {
 
 // UnpackMessage 解包消息
 func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
+	message, err := p.unpackMessage(data)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "unpack")
+	}
+
+	return message, err
+}
+
+// 解包消息
+func (p *Packer) unpackMessage(data []byte) (ipacket.Message, error) {
 	var (
 		ln     = defaultSizeBytes + defaultHeaderBytes + p.opts.routeBytes + p.opts.seqBytes
 		reader = bytes.NewReader(data)
@@ -287,7 +258,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	)
 
 	if len(data)-ln < 0 {
-		return nil, errors.New("ErrInvalidMessage")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: ln}
 	}
 
 	err := binary.Read(reader, p.opts.byteOrder, &size)
@@ -296,7 +267,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	}
 
 	if uint64(len(data))-defaultSizeBytes != uint64(size) {
-		return nil, errors.New("ErrInvalidMessage")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: int(size) + defaultSizeBytes}
 	}
 
 	err = binary.Read(reader, p.opts.byteOrder, &header)
@@ -305,7 +276,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	}
 
 	if header&dataBit != dataBit {
-		return nil, errors.New("ErrInvalidMessage")
+		return nil, errs.ErrInvalidMessage
 	}
 
 	message := &Message{}
@@ -397,7 +368,7 @@ func (p *Packer) PackHeartbeat() ([]byte, error) {
 // CheckHeartbeat 检测心跳包
 func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
 	if len(data) < defaultSizeBytes+defaultHeaderBytes {
-		return false, errors.New("ErrInvalidMessage")
+		return false, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: defaultSizeBytes + defaultHeaderBytes}
 	}
 
 	var (
@@ -412,7 +383,7 @@ func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
 	}
 
 	if uint64(len(data))-defaultSizeBytes != uint64(size) {
-		return false, errors.New("ErrInvalidMessage")
+		return false, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: int(size) + defaultSizeBytes}
 	}
 
 	err = binary.Read(reader, p.opts.byteOrder, &header)
@@ -423,16 +394,39 @@ func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
 	return header&heartbeatBit == heartbeatBit, nil
 }
 
+// MarshalData 编码消息负载，未设置编码器时仅支持[]byte
+func (p *Packer) MarshalData(v interface{}) ([]byte, error) {
+	if p.opts.codeC != nil {
+		return p.opts.codeC.Marshal(v)
+	}
+
+	switch b := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return b, nil
+	default:
+		return nil, errs.ErrInvalidData
+	}
+}
+
 // UnmarshalData Data
 func (p *Packer) UnmarshalData(data []byte, v interface{}) error {
 	if p.opts.codeC != nil {
 		return p.opts.codeC.Unmarshal(data, v)
 	} else {
-		v = data
+		if b, ok := v.(*[]byte); ok {
+			*b = data
+		}
 		return nil
 	}
 }
 
+// SeqBytes 序列号字节数
+func (p *Packer) SeqBytes() int {
+	return p.opts.seqBytes
+}
+
 func (p *Packer) String() string {
 	return Name
 }
@@ -1,6 +1,13 @@
 package due
 
 import (
+	"bytes"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"strings"
 	"testing"
 )
 
@@ -20,11 +27,13 @@ func TestDefaultPacker_PackMessage(t *testing.T) {
 
 	t.Log(data)
 
-	message, err := packer.UnpackMessage(data)
+	msg, err := packer.UnpackMessage(data)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	message := msg.(*Message)
+
 	t.Logf("seq: %d", message.Seq)
 	t.Logf("route: %d", message.Route)
 	t.Logf("buffer: %s", string(message.Buffer))
@@ -45,3 +54,97 @@ func TestPackHeartbeat(t *testing.T) {
 
 	t.Log(isHeartbeat)
 }
+
+func TestNewPackerE(t *testing.T) {
+	_, err := NewPackerE(WithRouteBytes(3), WithSeqBytes(3), WithBufferBytes(-1), WithCodeC("jsno"))
+
+	var cfgErr *ipacket.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *ipacket.ConfigError, got %v", err)
+	}
+
+	if len(cfgErr.Problems) != 4 {
+		t.Fatalf("expected 4 problems, got %d: %v", len(cfgErr.Problems), cfgErr)
+	}
+
+	if _, err = NewPackerE(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPacker_TypedErrors(t *testing.T) {
+	_, err := packer.PackMessage(&Message{Route: 1 << 20})
+
+	var rangeErr *errs.RangeError
+	if !errors.Is(err, errs.ErrRouteOverflow) || !errors.As(err, &rangeErr) || rangeErr.Value != 1<<20 {
+		t.Fatalf("expected route overflow range error, got %v", err)
+	}
+
+	_, err = packer.PackMessage(&Message{Route: 1, Buffer: make([]byte, defaultBufferBytes+1)})
+
+	var sizeErr *errs.SizeError
+	if !errors.Is(err, errs.ErrMessageTooLarge) || !errors.As(err, &sizeErr) || sizeErr.Size != defaultBufferBytes+1 {
+		t.Fatalf("expected message too large size error, got %v", err)
+	}
+
+	_, err = packer.UnpackMessage([]byte{0, 0})
+	if !errors.Is(err, errs.ErrInvalidMessage) {
+		t.Fatalf("expected invalid message error, got %v", err)
+	}
+}
+
+func TestPacker_Metrics(t *testing.T) {
+	collector := metrics.NewPrometheus()
+	packer := NewPacker(WithRouteBytes(1), WithMetrics(collector))
+
+	if _, err := packer.PackMessage(&Message{Route: 1 << 10}); err == nil {
+		t.Fatal("expected route overflow error")
+	}
+
+	if _, err := packer.UnpackMessage([]byte{0}); err == nil {
+		t.Fatal("expected invalid message error")
+	}
+
+	text := string(collector.Expose())
+
+	for _, line := range []string{
+		`packet_errors_total{packer="due",op="pack"} 1`,
+		`packet_errors_total{packer="due",op="unpack"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestPacker_FrameLimits(t *testing.T) {
+	packer := NewPacker(WithMaxFrameBytes(64))
+
+	for name, reader := range map[string]func(data []byte) interface{}{
+		"Copy":   func(data []byte) interface{} { return bytes.NewReader(data) },
+		"Nocopy": func(data []byte) interface{} { return buffer.NewConnReader(bytes.NewReader(data)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := packer.ReadMessage(reader([]byte{0xff, 0xff, 0xff, 0xff}))
+
+			var sizeErr *errs.SizeError
+			if !errors.Is(err, errs.ErrFrameTooLarge) || !errors.As(err, &sizeErr) || sizeErr.Limit != 64 {
+				t.Fatalf("expected frame too large size error, got %v", err)
+			}
+
+			data, err := packer.PackMessage(&Message{Route: 1, Buffer: make([]byte, 40)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = packer.ReadMessage(reader(data)); err != nil {
+				t.Fatalf("read frame within limit: %v", err)
+			}
+		})
+	}
+
+	var configErr *ipacket.ConfigError
+	if _, err := NewPackerE(WithMaxFrameBytes(minFrameBytes - 1)); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package envelope
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"testing"
+)
+
+func FuzzPacker_UnpackMessage(f *testing.F) {
+	packer := NewPacker(WithMaxFrameBytes(1024))
+
+	for _, seed := range []string{
+		`{"route":1,"seq":2,"data":{"name":"fuxi"}}`,
+		`{"route":3}`,
+		`{"route":-1,"data":[1,2,3]}`,
+		`{"heartbeat":1700000000000}`,
+		`{"route":1,"data": { "a" : 1 } }`,
+		`[]`,
+		`null`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := packer.UnpackMessage(data)
+		if err != nil {
+			return
+		}
+
+		message := msg.(*Message)
+
+		// 解包成功的消息重新打包后路由、序列号与负载不变
+		repacked, err := packer.PackMessage(message)
+		if err != nil {
+			return
+		}
+
+		msg, err = packer.UnpackMessage(repacked)
+		if err != nil {
+			t.Fatalf("unpack repacked message %s: %v", repacked, err)
+		}
+
+		again := msg.(*Message)
+		if again.Route != message.Route || again.Seq != message.Seq || !equalJSON(again.Data, message.Data) {
+			t.Fatalf("repacked message %+v, want %+v", again, message)
+		}
+	})
+}
+
+func FuzzPacker_CheckHeartbeat(f *testing.F) {
+	packer := NewPacker()
+
+	heartbeat, err := packer.PackHeartbeat()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(heartbeat)
+	f.Add([]byte(`{"route":1,"data":"hello"}`))
+	f.Add([]byte(`{"heartbeat":"x"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		isHeartbeat, err := packer.CheckHeartbeat(data)
+		if err != nil || !isHeartbeat {
+			return
+		}
+
+		// 心跳包不可作为数据消息解包
+		if _, err = packer.UnpackMessage(data); err == nil {
+			t.Fatalf("heartbeat %s unpacked as data message", data)
+		}
+	})
+}
+
+// 比较去除空白后的JSON文本
+func equalJSON(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+
+	if stdjson.Compact(&ca, a) != nil || stdjson.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,19 @@
+package envelope
+
+// Format: {"route":1,"seq":1,"data":{...}}
+type Message struct {
+	Route int32  // 路由ID
+	Seq   int32  // 序列号，为0时不编码
+	Data  []byte // 消息内容，须为合法的JSON
+}
+
+func (that *Message) Name() string {
+	return Name
+}
+func (that *Message) GetData() []byte {
+	return that.Data
+}
+
+func NewMessage(route, seq int32, data []byte) *Message {
+	return &Message{Route: route, Seq: seq, Data: data}
+}
@@ -0,0 +1,74 @@
+package envelope
+
+import (
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+)
+
+const Name = "envelope"
+
+// heartbeat packet
+// {"heartbeat":1700000000000}
+
+// data packet
+// {"route":1,"seq":1,"data":{...}}
+
+const (
+	defaultMaxFrameBytes = 64 * 1024
+)
+
+type options struct {
+	// 数据帧最大字节数，打包或解包超过该长度的JSON文本时返回错误
+	// 默认为64KB
+	maxFrameBytes int
+
+	// 日志器
+	// 默认使用全局日志器
+	logger logger.Logger
+
+	// 指标采集器
+	// 默认使用全局采集器
+	metrics metrics.Metrics
+}
+
+type Option func(o *options)
+
+// 校验配置，返回所有不合法的配置项
+func (o *options) validate() error {
+	var problems []string
+
+	if o.maxFrameBytes <= 0 {
+		problems = append(problems, fmt.Sprintf("the number of max frame bytes must be greater than 0, and give %d", o.maxFrameBytes))
+	}
+
+	if len(problems) > 0 {
+		return &ipacket.ConfigError{Packer: Name, Problems: problems}
+	}
+
+	return nil
+}
+
+func defaultOptions() *options {
+	return &options{
+		maxFrameBytes: defaultMaxFrameBytes,
+		logger:        logger.Default(),
+		metrics:       metrics.Default(),
+	}
+}
+
+// WithMaxFrameBytes 设置数据帧最大字节数
+func WithMaxFrameBytes(maxFrameBytes int) Option {
+	return func(o *options) { o.maxFrameBytes = maxFrameBytes }
+}
+
+// WithLogger 设置日志器
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithMetrics 设置指标采集器
+func WithMetrics(m metrics.Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
@@ -0,0 +1,174 @@
+// Package envelope JSON信封打包器，消息以JSON文本表示，依赖传输层（如websocket文本帧）划分消息边界
+package envelope
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"github.com/cute-angelia/go-game-utils/encoding/json"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"time"
+)
+
+// 消息的JSON结构
+type frame struct {
+	Route     int32              `json:"route"`
+	Seq       int32              `json:"seq,omitempty"`
+	Data      stdjson.RawMessage `json:"data,omitempty"`
+	Heartbeat int64              `json:"heartbeat,omitempty"`
+}
+
+// 心跳的JSON结构
+type heartbeatFrame struct {
+	Heartbeat int64 `json:"heartbeat"`
+}
+
+// 心跳字段
+var heartbeatKey = []byte(`"heartbeat"`)
+
+type Packer struct {
+	opts *options
+}
+
+var _ ipacket.Packer = &Packer{}
+
+// NewPacker 创建打包器，配置不合法时退出进程
+func NewPacker(opts ...Option) *Packer {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	p, err := newPacker(o)
+	if err != nil {
+		logger.Fatal(o.logger, "create packer failed", "error", err)
+	}
+
+	return p
+}
+
+// NewPackerE 创建打包器，配置不合法时返回*ipacket.ConfigError
+func NewPackerE(opts ...Option) (*Packer, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return newPacker(o)
+}
+
+func newPacker(o *options) (*Packer, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Packer{opts: o}, nil
+}
+
+// ReadMessage 读取消息
+// JSON消息没有长度字段，无法从字节流中读取，仅适用于自带消息边界的传输
+func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
+	return nil, errs.ErrInvalidReader
+}
+
+// PackMessage 打包消息
+func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+	data, err := p.packMessage(messageIn)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "pack")
+	}
+
+	return data, err
+}
+
+// 打包消息
+func (p *Packer) packMessage(messageIn ipacket.Message) ([]byte, error) {
+	msg, ok := messageIn.(*Message)
+	if !ok {
+		return nil, errs.ErrInvalidMessage
+	}
+
+	data, err := json.Marshal(&frame{Route: msg.Route, Seq: msg.Seq, Data: msg.Data})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > p.opts.maxFrameBytes {
+		return nil, &errs.SizeError{Err: errs.ErrMessageTooLarge, Size: len(data), Limit: p.opts.maxFrameBytes}
+	}
+
+	return data, nil
+}
+
+// UnpackMessage 解包消息
+func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
+	message, err := p.unpackMessage(data)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "unpack")
+	}
+
+	return message, err
+}
+
+// 解包消息
+func (p *Packer) unpackMessage(data []byte) (ipacket.Message, error) {
+	if len(data) > p.opts.maxFrameBytes {
+		return nil, &errs.SizeError{Err: errs.ErrFrameTooLarge, Size: len(data), Limit: p.opts.maxFrameBytes}
+	}
+
+	f := &frame{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, errs.ErrInvalidMessage
+	}
+
+	if f.Heartbeat != 0 {
+		return nil, errs.ErrInvalidMessage
+	}
+
+	return &Message{Route: f.Route, Seq: f.Seq, Data: f.Data}, nil
+}
+
+// PackHeartbeat 打包心跳，携带毫秒级时间戳
+func (p *Packer) PackHeartbeat() ([]byte, error) {
+	return json.Marshal(&heartbeatFrame{Heartbeat: time.Now().UnixMilli()})
+}
+
+// CheckHeartbeat 检测心跳包
+// 仅解析包含heartbeat字段的消息，其余消息直接视为数据消息，格式不合法时由UnpackMessage返回错误，避免每条消息重复解析
+func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
+	if len(data) > p.opts.maxFrameBytes || !bytes.Contains(data, heartbeatKey) {
+		return false, nil
+	}
+
+	f := &heartbeatFrame{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return false, nil
+	}
+
+	return f.Heartbeat != 0, nil
+}
+
+// MarshalData 编码消息负载，[]byte视为已编码的JSON
+func (p *Packer) MarshalData(v interface{}) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalData 解码消息负载，*[]byte时返回原始JSON
+func (p *Packer) UnmarshalData(data []byte, v interface{}) error {
+	if b, ok := v.(*[]byte); ok {
+		*b = data
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (p *Packer) String() string {
+	return Name
+}
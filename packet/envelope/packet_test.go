@@ -0,0 +1,123 @@
+package envelope
+
+import (
+	"bytes"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"testing"
+)
+
+func TestPacker_PackMessage(t *testing.T) {
+	packer := NewPacker()
+
+	payload, err := packer.MarshalData(map[string]interface{}{"name": "fuxi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := packer.PackMessage(NewMessage(1, 2, payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"route":1,"seq":2,"data":{"name":"fuxi"}}`; string(data) != want {
+		t.Fatalf("packed %s, want %s", data, want)
+	}
+
+	message, err := packer.UnpackMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.(*Message)
+	if msg.Route != 1 || msg.Seq != 2 {
+		t.Fatalf("unpacked route %d seq %d, want route 1 seq 2", msg.Route, msg.Seq)
+	}
+
+	var v struct {
+		Name string `json:"name"`
+	}
+
+	if err = packer.UnmarshalData(msg.GetData(), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Name != "fuxi" {
+		t.Fatalf("unmarshal name %q, want %q", v.Name, "fuxi")
+	}
+
+	// 无负载与序列号时省略对应字段
+	if data, err = packer.PackMessage(NewMessage(3, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"route":3}`; string(data) != want {
+		t.Fatalf("packed %s, want %s", data, want)
+	}
+
+	if _, err = packer.PackMessage(NewMessage(1, 0, []byte("{invalid"))); err == nil {
+		t.Fatal("expected error for invalid json data")
+	}
+}
+
+func TestPacker_Heartbeat(t *testing.T) {
+	packer := NewPacker()
+
+	heartbeat, err := packer.PackHeartbeat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isHeartbeat, err := packer.CheckHeartbeat(heartbeat); err != nil || !isHeartbeat {
+		t.Fatalf("check heartbeat %s: %v, %v", heartbeat, isHeartbeat, err)
+	}
+
+	if _, err = packer.UnpackMessage(heartbeat); !errors.Is(err, errs.ErrInvalidMessage) {
+		t.Fatalf("expected invalid message error for heartbeat, got %v", err)
+	}
+
+	data, err := packer.PackMessage(NewMessage(1, 0, []byte(`"hello"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isHeartbeat, err := packer.CheckHeartbeat(data); err != nil || isHeartbeat {
+		t.Fatalf("check data message %s: %v, %v", data, isHeartbeat, err)
+	}
+
+	// 格式不合法的消息不视为心跳，由解包返回错误
+	for _, data := range [][]byte{[]byte("not json"), []byte(`{"heartbeat":`)} {
+		if isHeartbeat, err := packer.CheckHeartbeat(data); err != nil || isHeartbeat {
+			t.Fatalf("check malformed message %s: %v, %v", data, isHeartbeat, err)
+		}
+
+		if _, err = packer.UnpackMessage(data); !errors.Is(err, errs.ErrInvalidMessage) {
+			t.Fatalf("expected invalid message error for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestPacker_FrameLimits(t *testing.T) {
+	packer := NewPacker(WithMaxFrameBytes(64))
+
+	data := []byte(`{"route":1,"data":"` + string(bytes.Repeat([]byte("x"), 64)) + `"}`)
+
+	var sizeErr *errs.SizeError
+	if _, err := packer.UnpackMessage(data); !errors.Is(err, errs.ErrFrameTooLarge) || !errors.As(err, &sizeErr) || sizeErr.Limit != 64 {
+		t.Fatalf("expected frame too large size error, got %v", err)
+	}
+
+	if _, err := packer.PackMessage(NewMessage(1, 0, data)); !errors.Is(err, errs.ErrMessageTooLarge) {
+		t.Fatalf("expected message too large error, got %v", err)
+	}
+
+	if _, err := packer.ReadMessage(bytes.NewReader(data)); !errors.Is(err, errs.ErrInvalidReader) {
+		t.Fatalf("expected invalid reader error, got %v", err)
+	}
+
+	var configErr *ipacket.ConfigError
+	if _, err := NewPackerE(WithMaxFrameBytes(0)); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+}
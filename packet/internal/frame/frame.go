@@ -0,0 +1,253 @@
+// Package frame 按长度字段读取数据帧，供以长度字段开头的打包器共用
+package frame
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"io"
+	"sync"
+)
+
+// Config 数据帧配置
+type Config struct {
+	Name          string           // 打包器名称，用于统计读取错误
+	SizeBytes     int              // 长度字段字节数，取值为2或4
+	SizeOffset    int              // 数据帧字节数与长度字段值的差值，长度字段不包含自身时为SizeBytes，包含自身时为0
+	MinFrameBytes int              // 数据帧最小字节数，包含长度字段
+	MaxFrameBytes int              // 数据帧最大字节数，包含长度字段
+	PoolBytes     int              // 池化内存大小，超出该大小的数据帧单独分配
+	ByteOrder     binary.ByteOrder // 长度字段字节序
+	Metrics       metrics.Metrics  // 指标
+}
+
+// Reader 数据帧读取器
+type Reader struct {
+	cfg        Config
+	sizePool   sync.Pool
+	bufferPool sync.Pool
+}
+
+func NewReader(cfg Config) *Reader {
+	r := &Reader{cfg: cfg}
+	r.sizePool = sync.Pool{New: func() any { return make([]byte, cfg.SizeBytes) }}
+	r.bufferPool = sync.Pool{New: func() any { return make([]byte, cfg.PoolBytes) }}
+
+	return r
+}
+
+// MaxFrameBytes 数据帧最大字节数
+func (r *Reader) MaxFrameBytes() int {
+	return r.cfg.MaxFrameBytes
+}
+
+// ReadMessage 读取消息，返回的数据归调用方所有，数据帧长度不合法时返回*errs.SizeError
+func (r *Reader) ReadMessage(reader interface{}) ([]byte, error) {
+	data, err := r.readMessage(reader)
+	if err != nil {
+		r.countReadError(err)
+	}
+
+	return data, err
+}
+
+// ReadLease 读取消息，无拷贝读取时数据引用读取器的内存块，拷贝读取时数据位于池化内存中，使用完毕后须调用Lease.Release归还
+func (r *Reader) ReadLease(reader interface{}) (*ipacket.Lease, error) {
+	switch rd := reader.(type) {
+	case buffer.NocopyReader:
+		data, sr, err := r.nocopyReadFrame(rd)
+		if err != nil {
+			r.countReadError(err)
+			return nil, err
+		}
+
+		if sr == nil {
+			return ipacket.NewLease(data, nil), nil
+		}
+
+		return ipacket.NewLease(data, func() { _ = sr.Release() }), nil
+	case io.Reader:
+		lease, err := r.readFrame(rd, true)
+		if err != nil {
+			r.countReadError(err)
+		}
+
+		return lease, err
+	default:
+		return nil, errs.ErrInvalidReader
+	}
+}
+
+// 读取消息
+func (r *Reader) readMessage(reader interface{}) ([]byte, error) {
+	switch rd := reader.(type) {
+	case buffer.NocopyReader:
+		return r.nocopyReadMessage(rd)
+	case io.Reader:
+		return r.copyReadMessage(rd)
+	default:
+		return nil, errs.ErrInvalidReader
+	}
+}
+
+// 统计数据帧长度不合法的读取错误
+func (r *Reader) countReadError(err error) {
+	var sizeErr *errs.SizeError
+	if errors.As(err, &sizeErr) {
+		r.cfg.Metrics.Add(metrics.PackErrors, 1, "packer", r.cfg.Name, "op", "read")
+	}
+}
+
+// 解析长度字段，返回包含长度字段的数据帧字节数，长度字段为0时返回0
+func (r *Reader) frameSize(buf []byte) int {
+	var size int
+
+	if r.cfg.SizeBytes == 2 {
+		size = int(r.cfg.ByteOrder.Uint16(buf))
+	} else {
+		size = int(r.cfg.ByteOrder.Uint32(buf))
+	}
+
+	if size == 0 {
+		return 0
+	}
+
+	return size + r.cfg.SizeOffset
+}
+
+// 校验数据帧长度，n为包含长度字段的数据帧字节数
+func (r *Reader) checkFrame(n int) error {
+	if n < r.cfg.MinFrameBytes {
+		return &errs.SizeError{Err: errs.ErrFrameTooSmall, Size: n, Limit: r.cfg.MinFrameBytes}
+	}
+
+	if n > r.cfg.MaxFrameBytes {
+		return &errs.SizeError{Err: errs.ErrFrameTooLarge, Size: n, Limit: r.cfg.MaxFrameBytes}
+	}
+
+	return nil
+}
+
+// 无拷贝读取消息帧，返回的数据引用切片读取器持有的内存块，使用完毕后须释放切片读取器
+func (r *Reader) nocopyReadFrame(reader buffer.NocopyReader) ([]byte, buffer.NocopyReader, error) {
+	buf, err := reader.Peek(r.cfg.SizeBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := r.frameSize(buf)
+
+	if n == 0 {
+		// 跳过空消息的长度字段，避免重复读取同一个长度字段
+		if _, err = reader.Next(r.cfg.SizeBytes); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, nil, reader.Release()
+	}
+
+	if err = r.checkFrame(n); err != nil {
+		return nil, nil, err
+	}
+
+	sr, err := reader.Slice(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf, err = sr.Next(n)
+	if err != nil {
+		_ = sr.Release()
+		return nil, nil, err
+	}
+
+	if err = reader.Release(); err != nil {
+		_ = sr.Release()
+		return nil, nil, err
+	}
+
+	return buf, sr, nil
+}
+
+// 无拷贝读取消息，数据拷贝后立即释放切片读取器，使内存块及时归还，返回的数据归调用方所有
+func (r *Reader) nocopyReadMessage(reader buffer.NocopyReader) ([]byte, error) {
+	data, sr, err := r.nocopyReadFrame(reader)
+	if err != nil || sr == nil {
+		return data, err
+	}
+
+	msg := make([]byte, len(data))
+	copy(msg, data)
+
+	return msg, sr.Release()
+}
+
+// 拷贝读取消息，返回的数据归调用方所有
+func (r *Reader) copyReadMessage(reader io.Reader) ([]byte, error) {
+	lease, err := r.readFrame(reader, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return lease.Bytes(), nil
+}
+
+// 读取完整的消息帧，pooled为true时使用池化内存
+func (r *Reader) readFrame(reader io.Reader, pooled bool) (*ipacket.Lease, error) {
+	buf := r.sizePool.Get().([]byte)
+	defer r.sizePool.Put(buf)
+
+	_, err := io.ReadFull(reader, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	n := r.frameSize(buf)
+
+	if n == 0 {
+		return ipacket.NewLease(nil, nil), nil
+	}
+
+	if err = r.checkFrame(n); err != nil {
+		return nil, err
+	}
+
+	var (
+		data    []byte
+		release func()
+	)
+
+	if pooled {
+		data = r.bufferPool.Get().([]byte)
+
+		// 超出池化内存大小的消息单独分配
+		if n > len(data) {
+			r.bufferPool.Put(data)
+			data = nil
+		} else {
+			release = func() {
+				clear(data[:n])
+				r.bufferPool.Put(data)
+			}
+		}
+	}
+
+	if data == nil {
+		data = make([]byte, n)
+	}
+
+	frame := data[:n:n]
+	copy(frame[:r.cfg.SizeBytes], buf)
+
+	if _, err = io.ReadFull(reader, frame[r.cfg.SizeBytes:]); err != nil {
+		if release != nil {
+			release()
+		}
+		return nil, err
+	}
+
+	return ipacket.NewLease(frame, release), nil
+}
@@ -0,0 +1,82 @@
+package frame_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/internal/frame"
+	"testing"
+)
+
+func TestReader_SizeOffset(t *testing.T) {
+	payload := []byte("hello")
+
+	for name, c := range map[string]struct {
+		sizeBytes  int
+		sizeOffset int
+	}{
+		"uint16-inclusive": {sizeBytes: 2},
+		"uint32-inclusive": {sizeBytes: 4},
+		"uint32-exclusive": {sizeBytes: 4, sizeOffset: 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := make([]byte, c.sizeBytes, c.sizeBytes+len(payload))
+			size := c.sizeBytes + len(payload) - c.sizeOffset
+			if c.sizeBytes == 2 {
+				binary.BigEndian.PutUint16(data, uint16(size))
+			} else {
+				binary.BigEndian.PutUint32(data, uint32(size))
+			}
+			data = append(data, payload...)
+
+			r := frame.NewReader(frame.Config{
+				Name:          "test",
+				SizeBytes:     c.sizeBytes,
+				SizeOffset:    c.sizeOffset,
+				MinFrameBytes: c.sizeBytes,
+				MaxFrameBytes: len(data),
+				PoolBytes:     len(data),
+				ByteOrder:     binary.BigEndian,
+				Metrics:       metrics.Default(),
+			})
+
+			msg, err := r.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(msg, data) {
+				t.Fatalf("copy read %v, want %v", msg, data)
+			}
+
+			lease, err := r.ReadLease(buffer.NewConnReader(bytes.NewReader(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(lease.Bytes(), data) {
+				t.Fatalf("nocopy read %v, want %v", lease.Bytes(), data)
+			}
+			lease.Release()
+
+			r = frame.NewReader(frame.Config{
+				Name:          "test",
+				SizeBytes:     c.sizeBytes,
+				SizeOffset:    c.sizeOffset,
+				MinFrameBytes: c.sizeBytes,
+				MaxFrameBytes: len(data) - 1,
+				PoolBytes:     len(data),
+				ByteOrder:     binary.BigEndian,
+				Metrics:       metrics.Default(),
+			})
+
+			var sizeErr *errs.SizeError
+			if _, err = r.ReadMessage(bytes.NewReader(data)); !errors.As(err, &sizeErr) || !errors.Is(err, errs.ErrFrameTooLarge) {
+				t.Fatalf("err = %v, want %v", err, errs.ErrFrameTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package packettest 打包器测试使用的公共工具
+package packettest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"sync"
+	"testing"
+)
+
+// PackFunc 打包第i个消息负载
+type PackFunc func(i int, payload []byte) ([]byte, error)
+
+// UnpackFunc 解包消息帧，返回消息负载
+type UnpackFunc func(frame []byte) ([]byte, error)
+
+// Stream 构造一个连接上的消息流，pack打包第i个消息负载，返回消息流与各消息负载
+func Stream(t testing.TB, conn, frames int, pack PackFunc) ([]byte, [][]byte) {
+	t.Helper()
+
+	var (
+		buf      bytes.Buffer
+		payloads [][]byte
+	)
+
+	for i := 0; i < frames; i++ {
+		payload := []byte(fmt.Sprintf("conn-%d-frame-%d-%s", conn, i, bytes.Repeat([]byte{byte('a' + i%26)}, i%64)))
+
+		data, err := pack(i, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf.Write(data)
+		payloads = append(payloads, payload)
+	}
+
+	return buf.Bytes(), payloads
+}
+
+// ReadNocopy 使用池化的连接读取器读取消息流中的frames个消息，读取器关闭后校验内存块全部归还
+// 内存块较小，使消息帧既有位于同一内存块内的，也有跨越内存块的
+func ReadNocopy(t testing.TB, data []byte, frames int, read func(reader interface{}) ([]byte, error)) [][]byte {
+	t.Helper()
+
+	before := buffer.BlocksInUse()
+
+	reader := buffer.NewConnReader(bytes.NewReader(data), 256)
+
+	messages := make([][]byte, 0, frames)
+	for i := 0; i < frames; i++ {
+		msg, err := read(reader)
+		if err != nil {
+			t.Fatalf("read frame %d: %v", i, err)
+		}
+		messages = append(messages, msg)
+	}
+
+	_ = reader.Close()
+
+	if inUse := buffer.BlocksInUse(); inUse != before {
+		t.Fatalf("%d blocks not returned to pool after reading %d frames", inUse-before, frames)
+	}
+
+	return messages
+}
+
+// ReadMessageConcurrent 多个连接并发读取各自的消息流，校验读取的消息归调用方所有，后续读取不会覆盖
+func ReadMessageConcurrent(t *testing.T, conns, frames int, pack PackFunc, read func(reader interface{}) ([]byte, error), unpack UnpackFunc) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+
+	for conn := 0; conn < conns; conn++ {
+		data, payloads := Stream(t, conn, frames, pack)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			reader := bytes.NewReader(data)
+
+			messages := make([][]byte, 0, len(payloads))
+			for range payloads {
+				msg, err := read(reader)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				messages = append(messages, msg)
+			}
+
+			for i, msg := range messages {
+				if err := verify(msg, payloads[i], unpack); err != nil {
+					t.Errorf("frame %d: %v", i, err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// ReadLeaseConcurrent 多个连接并发租借读取各自的消息流，校验租借的内存不可越界访问且归还后不可再访问
+func ReadLeaseConcurrent(t *testing.T, conns, frames int, pack PackFunc, read func(reader interface{}) (*ipacket.Lease, error), unpack UnpackFunc) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+
+	for conn := 0; conn < conns; conn++ {
+		data, payloads := Stream(t, conn, frames, pack)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			reader := bytes.NewReader(data)
+
+			for i := range payloads {
+				lease, err := read(reader)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				// 长度与容量一致，调用方无法越界访问池化内存
+				if len(lease.Bytes()) != cap(lease.Bytes()) {
+					t.Errorf("frame %d len %d, cap %d", i, len(lease.Bytes()), cap(lease.Bytes()))
+					return
+				}
+
+				if err = verify(lease.Bytes(), payloads[i], unpack); err != nil {
+					t.Errorf("frame %d: %v", i, err)
+					return
+				}
+
+				lease.Release()
+
+				if lease.Bytes() != nil {
+					t.Error("lease bytes should be nil after release")
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// ReadLeaseNocopy 使用池化的连接读取器租借读取消息流中的全部消息
+// 租借未归还时读取器关闭也不会回收其引用的内存块，全部归还后内存块回到池中
+func ReadLeaseNocopy(t testing.TB, data []byte, payloads [][]byte, read func(reader interface{}) (*ipacket.Lease, error), unpack UnpackFunc) {
+	t.Helper()
+
+	before := buffer.BlocksInUse()
+
+	reader := buffer.NewConnReader(bytes.NewReader(data), 256)
+
+	leases := make([]*ipacket.Lease, 0, len(payloads))
+	for i := range payloads {
+		lease, err := read(reader)
+		if err != nil {
+			t.Fatalf("read frame %d: %v", i, err)
+		}
+		leases = append(leases, lease)
+	}
+
+	_ = reader.Close()
+
+	if buffer.BlocksInUse() == before {
+		t.Fatal("leased frames should hold their blocks after reader closed")
+	}
+
+	for i, lease := range leases {
+		if err := verify(lease.Bytes(), payloads[i], unpack); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+
+		lease.Release()
+	}
+
+	if inUse := buffer.BlocksInUse(); inUse != before {
+		t.Fatalf("%d blocks not returned to pool after releasing %d leases", inUse-before, len(leases))
+	}
+}
+
+// 校验消息帧的负载
+func verify(frame, payload []byte, unpack UnpackFunc) error {
+	data, err := unpack(frame)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(data, payload) {
+		return fmt.Errorf("payload = %q, want %q", data, payload)
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package ipacket
+
+import (
+	"strings"
+)
+
+// ConfigError 打包器配置校验错误，列出所有不合法的配置项
+type ConfigError struct {
+	Packer   string   // 打包器名称
+	Problems []string // 不合法的配置项
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid " + e.Packer + " packer config: " + strings.Join(e.Problems, "; ")
+}
@@ -0,0 +1,34 @@
+package ipacket
+
+// LeaseReader 支持租借读取的打包器
+type LeaseReader interface {
+	// ReadLease 读取消息，返回的内存在Lease.Release前归调用方所有
+	ReadLease(reader interface{}) (*Lease, error)
+}
+
+// Lease 租借的消息内存，使用完毕后须调用Release归还，归还后不可再访问Bytes返回的数据
+// 非并发安全，应由持有者调用一次Release
+type Lease struct {
+	data    []byte
+	release func()
+}
+
+// NewLease 创建租借，release为nil时表示数据无需归还
+func NewLease(data []byte, release func()) *Lease {
+	return &Lease{data: data, release: release}
+}
+
+// Bytes 获取消息数据
+func (l *Lease) Bytes() []byte {
+	return l.data
+}
+
+// Release 归还内存，重复调用无副作用
+func (l *Lease) Release() {
+	l.data = nil
+
+	if release := l.release; release != nil {
+		l.release = nil
+		release()
+	}
+}
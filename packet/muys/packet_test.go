@@ -0,0 +1,57 @@
+package muys
+
+import (
+	"bytes"
+	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"testing"
+)
+
+func TestPacker_FrameLimits(t *testing.T) {
+	packer := NewPacker(WithMaxFrameBytes(64))
+
+	for name, reader := range map[string]func(data []byte) interface{}{
+		"Copy":   func(data []byte) interface{} { return bytes.NewReader(data) },
+		"Nocopy": func(data []byte) interface{} { return buffer.NewConnReader(bytes.NewReader(data)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := packer.ReadMessage(reader([]byte{0xff, 0xff}))
+
+			var sizeErr *errs.SizeError
+			if !errors.Is(err, errs.ErrFrameTooLarge) || !errors.As(err, &sizeErr) || sizeErr.Limit != 64 {
+				t.Fatalf("expected frame too large size error, got %v", err)
+			}
+
+			if _, err = packer.ReadMessage(reader([]byte{0, 1, 0})); !errors.Is(err, errs.ErrFrameTooSmall) {
+				t.Fatalf("expected frame too small size error, got %v", err)
+			}
+
+			data, err := packer.PackMessage(NewMessage(make([]byte, 40)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = packer.ReadMessage(reader(data)); err != nil {
+				t.Fatalf("read frame within limit: %v", err)
+			}
+		})
+	}
+
+	var configErr *ipacket.ConfigError
+	if _, err := NewPackerE(WithMaxFrameBytes(minFrameBytes - 1)); !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+}
+
+func TestNewPackerE_CodeC(t *testing.T) {
+	var configErr *ipacket.ConfigError
+	if _, err := NewPackerE(WithCodeC("jsno")); !errors.As(err, &configErr) || len(configErr.Problems) != 1 {
+		t.Fatalf("expected config error for unknown codec, got %v", err)
+	}
+
+	if _, err := NewPackerE(WithCodeC("json")); err != nil {
+		t.Fatal(err)
+	}
+}
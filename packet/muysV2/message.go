@@ -22,3 +22,8 @@ func NewMessage(msgType uint16, data []byte) *Message {
 		data:    data,
 	}
 }
+
+// GetMsgType 获取消息类型
+func (that *Message) GetMsgType() uint16 {
+	return that.msgType
+}
@@ -0,0 +1,46 @@
+package muysV2
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/packet/internal/packettest"
+	"testing"
+)
+
+func TestPacker_NocopyReleasesBlocks(t *testing.T) {
+	packer := NewPacker()
+
+	data, payloads := packettest.Stream(t, 0, 200, func(i int, payload []byte) ([]byte, error) {
+		return packer.PackMessage(NewMessage(1, payload))
+	})
+
+	// 读取器关闭后消息仍归调用方所有，内容不受内存块复用影响
+	frames := packettest.ReadNocopy(t, data, len(payloads), packer.ReadMessage)
+
+	for i, frame := range frames {
+		msg, err := packer.UnpackMessage(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(msg.GetData(), payloads[i]) {
+			t.Fatalf("frame %d = %q, want %q", i, msg.GetData(), payloads[i])
+		}
+	}
+}
+
+func TestPacker_LeaseReleasesBlocks(t *testing.T) {
+	packer := NewPacker()
+
+	data, payloads := packettest.Stream(t, 0, 200, func(i int, payload []byte) ([]byte, error) {
+		return packer.PackMessage(NewMessage(1, payload))
+	})
+
+	packettest.ReadLeaseNocopy(t, data, payloads, packer.ReadLease, func(frame []byte) ([]byte, error) {
+		msg, err := packer.UnpackMessage(frame)
+		if err != nil {
+			return nil, err
+		}
+
+		return msg.GetData(), nil
+	})
+}
@@ -3,161 +3,104 @@ package muysV2
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/internal/frame"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"google.golang.org/protobuf/proto"
-	"io"
-	"log"
 	"sync"
 	"time"
 )
 
-type Packer struct {
-	opts             *options
-	once             sync.Once
-	readerSizePool   sync.Pool
-	readerBufferPool sync.Pool
-}
-
-type NocopyReader interface {
-	// Next returns a slice containing the next n bytes from the buffer,
-	// advancing the buffer as if the bytes had been returned by Read.
-	Next(n int) (p []byte, err error)
-
-	// Peek returns the next n bytes without advancing the reader.
-	Peek(n int) (buf []byte, err error)
+var (
+	_ ipacket.Packer      = &Packer{}
+	_ ipacket.LeaseReader = &Packer{}
+)
 
-	// Release the memory space occupied by all read slices.
-	Release() (err error)
+// 数据帧最小字节数，即长度字段与固定头部的字节数
+const minFrameBytes = defaultSizeBytes + defaultTypeBytes
 
-	Slice(n int) (r NocopyReader, err error)
+type Packer struct {
+	opts   *options
+	once   sync.Once
+	reader *frame.Reader
 }
 
+// NocopyReader 无拷贝读取器
+type NocopyReader = buffer.NocopyReader
+
+// NewPacker 创建打包器，配置不合法时退出进程
 func NewPacker(opts ...Option) *Packer {
 	o := defaultOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
-	if o.bufferBytes < 0 {
-		log.Fatalf("the number of buffer bytes must be greater than or equal to 0, and give %d", o.bufferBytes)
+	p, err := newPacker(o)
+	if err != nil {
+		logger.Fatal(o.logger, "create packer failed", "error", err)
 	}
 
-	p := &Packer{opts: o}
-
-	p.readerSizePool = sync.Pool{New: func() any { return make([]byte, defaultSizeBytes) }}
-	p.readerBufferPool = sync.Pool{New: func() any {
-		return make([]byte, defaultSizeBytes+o.bufferBytes)
-	}}
-
 	return p
 }
 
-// ReadMessage 读取消息
-func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
-	switch r := reader.(type) {
-	case NocopyReader:
-		return p.nocopyReadMessage(r)
-	case io.Reader:
-		return p.copyReadMessage(r)
-	default:
-		return nil, errors.New("ErrInvalidReader")
-	}
-}
-
-// 无拷贝读取消息
-func (p *Packer) nocopyReadMessage(reader NocopyReader) ([]byte, error) {
-	buf, err := reader.Peek(defaultSizeBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	var size int32
-
-	if p.opts.byteOrder == binary.BigEndian {
-		size = int32(binary.BigEndian.Uint32(buf))
-	} else {
-		size = int32(binary.LittleEndian.Uint32(buf))
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
-
-	//n := int(defaultSizeBytes + size)
-	n := int(size)
-
-	r, err := reader.Slice(n)
-	if err != nil {
-		return nil, err
-	}
-
-	buf, err = r.Next(n)
-	if err != nil {
-		return nil, err
-	}
-
-	if err = reader.Release(); err != nil {
-		return nil, err
+// NewPackerE 创建打包器，配置不合法时返回*ipacket.ConfigError
+func NewPackerE(opts ...Option) (*Packer, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	return buf, nil
+	return newPacker(o)
 }
 
-// 拷贝读取消息
-func (p *Packer) copyReadMessage(reader io.Reader) ([]byte, error) {
-	// 从 pool 获取一个 buffer
-	buf := p.readerSizePool.Get().([]byte)
-	// 确保归还前清空 buffer 并归还到 pool
-	defer func() {
-		clear(buf)
-		p.readerSizePool.Put(buf)
-	}()
-
-	_, err := io.ReadFull(reader, buf)
-	if err != nil {
+func newPacker(o *options) (*Packer, error) {
+	if err := o.validate(); err != nil {
 		return nil, err
 	}
 
-	var size int32
-
-	if p.opts.byteOrder == binary.BigEndian {
-		size = int32(binary.BigEndian.Uint32(buf))
-	} else {
-		size = int32(binary.LittleEndian.Uint32(buf))
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
+	p := &Packer{opts: o}
+	p.reader = frame.NewReader(frame.Config{
+		Name:          Name,
+		SizeBytes:     defaultSizeBytes,
+		MinFrameBytes: minFrameBytes,
+		MaxFrameBytes: o.frameLimit(),
+		PoolBytes:     defaultSizeBytes + o.bufferBytes,
+		ByteOrder:     o.byteOrder,
+		Metrics:       o.metrics,
+	})
+
+	return p, nil
+}
 
-	// 组包
-	// 从 pool 获取一个 buffer
-	data := p.readerBufferPool.Get().([]byte)
-	// 确保归还前清空 buffer 并归还到 pool
-	defer func() {
-		clear(data)
-		p.readerBufferPool.Put(data)
-	}()
+// ReadMessage 读取消息，返回的数据归调用方所有，数据帧长度不合法时返回*errs.SizeError
+func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
+	return p.reader.ReadMessage(reader)
+}
 
-	// 第一个 size
-	smallSlice := data[:size]
-	copy(smallSlice[:defaultSizeBytes], buf)
+// ReadLease 读取消息，无拷贝读取时数据引用读取器的内存块，拷贝读取时数据位于池化内存中，使用完毕后须调用Lease.Release归还
+func (p *Packer) ReadLease(reader interface{}) (*ipacket.Lease, error) {
+	return p.reader.ReadLease(reader)
+}
 
-	_, err = io.ReadFull(reader, smallSlice[defaultSizeBytes:])
+// PackMessage 打包消息
+func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+	data, err := p.packMessage(messageIn)
 	if err != nil {
-		return nil, err
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "pack")
 	}
 
-	return data, nil
+	return data, err
 }
 
-// PackMessage 打包消息
-func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+// 打包消息
+func (p *Packer) packMessage(messageIn ipacket.Message) ([]byte, error) {
 	msg := messageIn.(*Message)
 
 	if len(msg.data) > p.opts.bufferBytes {
-		return nil, errors.New("ErrMessageTooLarge")
+		return nil, &errs.SizeError{Err: errs.ErrMessageTooLarge, Size: len(msg.data), Limit: p.opts.bufferBytes}
 	}
 
 	var (
@@ -165,8 +108,6 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 		buf  = &bytes.Buffer{}
 	)
 
-	//log.Println(size, "pack", len(msg.data))
-
 	buf.Grow(size)
 
 	// 4字节
@@ -192,6 +133,16 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 
 // UnpackMessage 解包消息
 func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
+	message, err := p.unpackMessage(data)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "unpack")
+	}
+
+	return message, err
+}
+
+// 解包消息
+func (p *Packer) unpackMessage(data []byte) (ipacket.Message, error) {
 	msg := new(Message)
 
 	var (
@@ -200,7 +151,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	)
 
 	if len(data)-ln < 0 {
-		return nil, errors.New("ErrInvalidMessage1")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: ln}
 	}
 
 	err := binary.Read(reader, p.opts.byteOrder, &msg.length)
@@ -214,7 +165,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	}
 
 	if uint64(len(data)) != uint64(msg.length) {
-		return nil, errors.New("ErrInvalidMessage2")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: int(msg.length)}
 	}
 
 	msg.data = data[ln:]
@@ -242,12 +193,14 @@ func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
 	return false, nil
 }
 
-// UnmarshalData Data
+// UnmarshalData 解码消息负载，未设置编码器时仅支持*[]byte
 func (p *Packer) UnmarshalData(data []byte, v interface{}) error {
 	if p.opts.codeC != nil {
 		return p.opts.codeC.Unmarshal(data, v)
 	} else {
-		v = data
+		if b, ok := v.(*[]byte); ok {
+			*b = data
+		}
 		return nil
 	}
 }
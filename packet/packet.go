@@ -2,6 +2,7 @@ package packet
 
 import (
 	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/envelope"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"github.com/cute-angelia/go-game-utils/packet/muys"
 	"github.com/cute-angelia/go-game-utils/packet/qx"
@@ -15,6 +16,8 @@ func GetDefaultPacker(packerName string) ipacket.Packer {
 		return muys.NewPacker()
 	case "qx":
 		return qx.NewPacker(qx.WithCodeC("proto"))
+	case "envelope":
+		return envelope.NewPacker()
 	}
 	return nil
 }
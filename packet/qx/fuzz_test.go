@@ -0,0 +1,71 @@
+package qx
+
+import (
+	"bytes"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"testing"
+)
+
+// 种子语料位于testdata/fuzz
+
+func FuzzPacker_ReadMessage(f *testing.F) {
+	packer := NewPacker(WithCodeC(""), WithMaxFrameBytes(256))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reader := range []interface{}{bytes.NewReader(data), buffer.NewConnReader(bytes.NewReader(data), 16)} {
+			for i := 0; i <= len(data); i++ {
+				frame, err := packer.ReadMessage(reader)
+				if err != nil {
+					break
+				}
+
+				if len(frame) != 0 && (len(frame) < minFrameBytes || len(frame) > packer.reader.MaxFrameBytes()) {
+					t.Fatalf("frame length %d out of range [%d, %d]", len(frame), minFrameBytes, packer.reader.MaxFrameBytes())
+				}
+			}
+		}
+	})
+}
+
+func FuzzPacker_UnpackMessage(f *testing.F) {
+	packer := NewPacker(WithCodeC(""))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := packer.UnpackMessage(data)
+		if err != nil {
+			return
+		}
+
+		message := msg.(*Message)
+
+		if len(message.GetData()) > packer.opts.bufferBytes {
+			return
+		}
+
+		// 解包成功的消息重新打包后内容不变
+		repacked, err := packer.PackMessage(message)
+		if err != nil {
+			t.Fatalf("pack unpacked message: %v", err)
+		}
+
+		msg, err = packer.UnpackMessage(repacked)
+		if err != nil {
+			t.Fatalf("unpack repacked message: %v", err)
+		}
+
+		if again := msg.(*Message); again.mainID != message.mainID || again.subID != message.subID || !bytes.Equal(again.GetData(), message.GetData()) {
+			t.Fatalf("repacked message %+v, want %+v", again, message)
+		}
+	})
+}
+
+func FuzzPacker_CheckHeartbeat(f *testing.F) {
+	packer := NewPacker(WithCodeC(""))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// 未实现心跳检测，任何数据都不是心跳包
+		if isHeartbeat, err := packer.CheckHeartbeat(data); err != nil || isHeartbeat {
+			t.Fatalf("check heartbeat = %v, %v, want false, nil", isHeartbeat, err)
+		}
+	})
+}
@@ -2,7 +2,11 @@ package qx
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/cute-angelia/go-game-utils/encoding"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
 	"strings"
 )
 
@@ -22,6 +26,7 @@ const (
 
 	defaultBufferBytes = 5000
 	defaultEndian      = LittleEndian
+	defaultCodeC       = "proto"
 )
 
 type options struct {
@@ -33,6 +38,10 @@ type options struct {
 	// 默认为5000字节
 	bufferBytes int
 
+	// 数据帧最大字节数，包含长度字段，读取超过该长度的数据帧时返回错误
+	// 默认为0，按消息字节数与头部字节数计算
+	maxFrameBytes int
+
 	// 大小端
 	endian string
 
@@ -41,17 +50,63 @@ type options struct {
 
 	// 编码器
 	codeC encoding.Codec
+
+	// 编解码器配置错误，创建打包器时返回
+	codeCErr error
+
+	// 日志器
+	// 默认使用全局日志器
+	logger logger.Logger
+
+	// 指标采集器
+	// 默认使用全局采集器
+	metrics metrics.Metrics
 }
 
 type Option func(o *options)
 
+// 校验配置，返回所有不合法的配置项
+func (o *options) validate() error {
+	var problems []string
+
+	if o.bufferBytes < 0 {
+		problems = append(problems, fmt.Sprintf("the number of buffer bytes must be greater than or equal to 0, and give %d", o.bufferBytes))
+	}
+
+	if o.maxFrameBytes != 0 && o.maxFrameBytes < minFrameBytes {
+		problems = append(problems, fmt.Sprintf("the number of max frame bytes must be 0 or greater than or equal to %d, and give %d", minFrameBytes, o.maxFrameBytes))
+	}
+
+	if o.codeCErr != nil {
+		problems = append(problems, o.codeCErr.Error())
+	}
+
+	if len(problems) > 0 {
+		return &ipacket.ConfigError{Packer: Name, Problems: problems}
+	}
+
+	return nil
+}
+
+// 数据帧最大字节数
+func (o *options) frameLimit() int {
+	if o.maxFrameBytes > 0 {
+		return o.maxFrameBytes
+	}
+
+	return minFrameBytes + o.bufferBytes
+}
+
 func defaultOptions() *options {
 	opts := &options{
 		byteOrder:   binary.BigEndian,
 		bufferBytes: defaultBufferBytes,
-		codeC:       encoding.Invoke("proto"),
+		logger:      logger.Default(),
+		metrics:     metrics.Default(),
 	}
 
+	opts.codeC, opts.codeCErr = encoding.InvokeE(defaultCodeC)
+
 	endian := defaultEndian
 	switch strings.ToLower(endian) {
 	case LittleEndian:
@@ -73,23 +128,22 @@ func WithBufferBytes(bufferBytes int) Option {
 	return func(o *options) { o.bufferBytes = bufferBytes }
 }
 
+// WithMaxFrameBytes 设置数据帧最大字节数，包含长度字段
+func WithMaxFrameBytes(maxFrameBytes int) Option {
+	return func(o *options) { o.maxFrameBytes = maxFrameBytes }
+}
+
 func WithIsClient(isClient bool) Option {
 	return func(o *options) { o.isClient = isClient }
 }
 
+// WithCodeC 设置消息负载的编解码器，为空时不编解码，编解码器未注册时创建打包器返回*ipacket.ConfigError
 func WithCodeC(codecName string) Option {
 	return func(o *options) {
-		switch codecName {
-		case "proto":
-			o.codeC = encoding.Invoke("proto")
-		case "json":
-			o.codeC = encoding.Invoke("json")
-		case "msgpack":
-			o.codeC = encoding.Invoke("msgpack")
-		case "xml":
-			o.codeC = encoding.Invoke("xml")
-		default:
-			o.codeC = nil
+		o.codeC, o.codeCErr = nil, nil
+
+		if codecName != "" {
+			o.codeC, o.codeCErr = encoding.InvokeE(codecName)
 		}
 	}
 }
@@ -107,3 +161,13 @@ func WithEndian(endian string) Option {
 		}
 	}
 }
+
+// WithLogger 设置日志器
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithMetrics 设置指标采集器
+func WithMetrics(m metrics.Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
@@ -3,174 +3,108 @@ package qx
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+	"github.com/cute-angelia/go-game-utils/buffer"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/metrics"
+	"github.com/cute-angelia/go-game-utils/packet/internal/frame"
 	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
-	"io"
-	"log"
 	"sync"
 	"time"
 )
 
 // 校验
-var _ ipacket.Packer = &Packer{}
+var (
+	_ ipacket.Packer      = &Packer{}
+	_ ipacket.LeaseReader = &Packer{}
+)
+
+// 数据帧最小字节数，即长度字段与固定头部的字节数
+const minFrameBytes = defaultSizeBytes + defaultMainIdBytes + defaultSubIdBytes
 
 type Packer struct {
-	opts             *options
-	once             sync.Once
-	readerSizePool   sync.Pool
-	readerBufferPool sync.Pool
+	opts   *options
+	once   sync.Once
+	reader *frame.Reader
 }
 
-type NocopyReader interface {
-	// Next returns a slice containing the next n bytes from the buffer,
-	// advancing the buffer as if the bytes had been returned by Read.
-	Next(n int) (p []byte, err error)
-
-	// Peek returns the next n bytes without advancing the reader.
-	Peek(n int) (buf []byte, err error)
-
-	// Release the memory space occupied by all read slices.
-	Release() (err error)
-
-	Slice(n int) (r NocopyReader, err error)
-}
+// NocopyReader 无拷贝读取器
+type NocopyReader = buffer.NocopyReader
 
+// NewPacker 创建打包器，配置不合法时退出进程
 func NewPacker(opts ...Option) *Packer {
 	o := defaultOptions()
 	for _, opt := range opts {
 		opt(o)
 	}
 
-	if o.bufferBytes < 0 {
-		log.Fatalf("the number of buffer bytes must be greater than or equal to 0, and give %d", o.bufferBytes)
+	p, err := newPacker(o)
+	if err != nil {
+		logger.Fatal(o.logger, "create packer failed", "error", err)
 	}
 
-	p := &Packer{opts: o}
-
-	p.readerSizePool = sync.Pool{New: func() any { return make([]byte, defaultSizeBytes) }}
-	p.readerBufferPool = sync.Pool{New: func() any {
-		return make([]byte, defaultSizeBytes+o.bufferBytes)
-	}}
-
 	return p
 }
 
-// ReadMessage 读取消息
-func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
-	switch r := reader.(type) {
-	case NocopyReader:
-		log.Println("NocopyReader")
-		return p.nocopyReadMessage(r)
-	case io.Reader:
-		log.Println("io.Reader")
-		return p.copyReadMessage(r)
-	default:
-		return nil, errors.New("ErrInvalidReader")
-	}
-}
-
-// 无拷贝读取消息
-func (p *Packer) nocopyReadMessage(reader NocopyReader) ([]byte, error) {
-	buf, err := reader.Peek(defaultSizeBytes)
-	if err != nil {
-		return nil, err
-	}
-
-	var size int32
-
-	if p.opts.byteOrder == binary.BigEndian {
-		size = int32(binary.BigEndian.Uint32(buf))
-	} else {
-		size = int32(binary.LittleEndian.Uint32(buf))
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
-
-	//n := int(defaultSizeBytes + size)
-	n := int(size)
-
-	r, err := reader.Slice(n)
-	if err != nil {
-		return nil, err
-	}
-
-	buf, err = r.Next(n)
-	if err != nil {
-		return nil, err
-	}
-
-	if err = reader.Release(); err != nil {
-		return nil, err
+// NewPackerE 创建打包器，配置不合法时返回*ipacket.ConfigError
+func NewPackerE(opts ...Option) (*Packer, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
 	}
 
-	return buf, nil
+	return newPacker(o)
 }
 
-// 拷贝读取消息
-func (p *Packer) copyReadMessage(reader io.Reader) ([]byte, error) {
-	// 从 pool 获取一个 size buffer = 4
-	sizeBuf := p.readerSizePool.Get().([]byte)
-	// 确保归还前清空 buffer 并归还到 pool
-	defer func() {
-		clear(sizeBuf)
-		p.readerSizePool.Put(sizeBuf)
-	}()
-
-	// ws 不走该协议
-	//if p.opts.isClient {
-	//	ln += defaultClientAppendLength
-	//}
-
-	// 读取头部
-	_, err := io.ReadFull(reader, sizeBuf)
-	if err != nil {
+func newPacker(o *options) (*Packer, error) {
+	if err := o.validate(); err != nil {
 		return nil, err
 	}
 
-	var size int32
-	if p.opts.byteOrder == binary.BigEndian {
-		size = int32(binary.BigEndian.Uint32(sizeBuf))
-	} else {
-		size = int32(binary.LittleEndian.Uint32(sizeBuf))
-	}
-
-	if size == 0 {
-		return nil, nil
-	}
+	p := &Packer{opts: o}
+	p.reader = frame.NewReader(frame.Config{
+		Name:          Name,
+		SizeBytes:     defaultSizeBytes,
+		MinFrameBytes: minFrameBytes,
+		MaxFrameBytes: o.frameLimit(),
+		PoolBytes:     defaultSizeBytes + o.bufferBytes,
+		ByteOrder:     o.byteOrder,
+		Metrics:       o.metrics,
+	})
+
+	return p, nil
+}
 
-	// 组包
-	// 从 pool 获取一个 buffer
-	data := p.readerBufferPool.Get().([]byte)
-	// 确保归还前清空 buffer 并归还到 pool
-	defer func() {
-		clear(data)
-		p.readerBufferPool.Put(data)
-	}()
+// ReadMessage 读取消息，返回的数据归调用方所有，数据帧长度不合法时返回*errs.SizeError
+func (p *Packer) ReadMessage(reader interface{}) ([]byte, error) {
+	return p.reader.ReadMessage(reader)
+}
 
-	// 第一个 size
-	smallSlice := data[:size]                    // 获取一个完整包长度
-	copy(smallSlice[:defaultSizeBytes], sizeBuf) // 把 sizebuf 放进去
+// ReadLease 读取消息，无拷贝读取时数据引用读取器的内存块，拷贝读取时数据位于池化内存中，使用完毕后须调用Lease.Release归还
+func (p *Packer) ReadLease(reader interface{}) (*ipacket.Lease, error) {
+	return p.reader.ReadLease(reader)
+}
 
-	_, err = io.ReadFull(reader, smallSlice[defaultSizeBytes:]) // 填充其他数据
+// PackMessage 打包消息
+func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+	data, err := p.packMessage(messageIn)
 	if err != nil {
-		return nil, err
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "pack")
 	}
 
-	return data, nil
+	return data, err
 }
 
-// PackMessage 打包消息
-func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
+// 打包消息
+func (p *Packer) packMessage(messageIn ipacket.Message) ([]byte, error) {
 	msg := messageIn.(*Message)
 	// encoding
 	var data []byte
 	if p.opts.codeC != nil {
 		dataM, errm := p.opts.codeC.Marshal(msg.data)
 		if errm != nil {
-			log.Println(errm)
 			return data, errm
 		} else {
 			data = dataM
@@ -180,7 +114,7 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 	}
 	// large
 	if len(data) > p.opts.bufferBytes {
-		return nil, errors.New("ErrMessageTooLarge")
+		return nil, &errs.SizeError{Err: errs.ErrMessageTooLarge, Size: len(data), Limit: p.opts.bufferBytes}
 	}
 
 	var (
@@ -189,11 +123,13 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 	)
 	if p.opts.isClient {
 		buf.Grow(size + defaultClientAppendLength)
-		head := Head{}
-		head.Length = int32(len(data)) // 数据长度
-		head.Mainid = msg.mainID
-		head.Subid = msg.subID
-		headData, _ := proto.Marshal(&head) // 这里固定是 proto marshal  !=  p.opts.codeC.Marshal()
+		// 按Head的proto格式编码，零值字段同样写入以保证头部固定为15字节
+		// 这里固定是 proto 格式  !=  p.opts.codeC.Marshal()
+		headData := make([]byte, 0, defaultSizeBytes+defaultMainIdBytes+defaultSubIdBytes+defaultClientAppendLength)
+		for i, v := range []int32{int32(len(data)), msg.mainID, msg.subID} {
+			headData = protowire.AppendTag(headData, protowire.Number(i+1), protowire.Fixed32Type)
+			headData = protowire.AppendFixed32(headData, uint32(v))
+		}
 
 		buf.Write(headData)
 		buf.Write(data)
@@ -227,6 +163,16 @@ func (p *Packer) PackMessage(messageIn ipacket.Message) ([]byte, error) {
 
 // UnpackMessage 解包消息
 func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
+	message, err := p.unpackMessage(data)
+	if err != nil {
+		p.opts.metrics.Add(metrics.PackErrors, 1, "packer", Name, "op", "unpack")
+	}
+
+	return message, err
+}
+
+// 解包消息
+func (p *Packer) unpackMessage(data []byte) (ipacket.Message, error) {
 	var (
 		ln     = defaultSizeBytes + defaultMainIdBytes + defaultSubIdBytes
 		reader = bytes.NewReader(data)
@@ -239,7 +185,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 		ln += defaultClientAppendLength
 
 		if len(data)-ln < 0 {
-			return nil, errors.New("ErrInvalidMessage1")
+			return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: ln}
 		}
 
 		head := Head{}
@@ -257,7 +203,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	}
 
 	if len(data)-ln < 0 {
-		return nil, errors.New("ErrInvalidMessage1")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: ln}
 	}
 
 	err := binary.Read(reader, p.opts.byteOrder, &size)
@@ -266,7 +212,7 @@ func (p *Packer) UnpackMessage(data []byte) (ipacket.Message, error) {
 	}
 
 	if uint64(len(data)) != uint64(size) {
-		return nil, errors.New("ErrInvalidMessage2")
+		return nil, &errs.SizeError{Err: errs.ErrInvalidMessage, Size: len(data), Limit: int(size)}
 	}
 
 	msg.length = int32(size)
@@ -302,16 +248,17 @@ func (p *Packer) CheckHeartbeat(data []byte) (bool, error) {
 	//msg, _ := p.UnpackMessage(data)
 	//hb := HeartBeat{}
 	//p.UnmarshalData(msg.GetData(), &hb)
-	//log.Println(hb)
 	return false, nil
 }
 
-// UnmarshalData Data
+// UnmarshalData 解码消息负载，未设置编码器时仅支持*[]byte
 func (p *Packer) UnmarshalData(data []byte, v interface{}) error {
 	if p.opts.codeC != nil {
 		return p.opts.codeC.Unmarshal(data, v)
 	} else {
-		v = data
+		if b, ok := v.(*[]byte); ok {
+			*b = data
+		}
 		return nil
 	}
 }
@@ -0,0 +1,255 @@
+package packet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/cute-angelia/go-game-utils/encoding"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/muys"
+	"github.com/cute-angelia/go-game-utils/packet/muysV2"
+	"github.com/cute-angelia/go-game-utils/packet/qx"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+var (
+	codecs  = []string{"", "proto", "json", "msgpack", "xml"}
+	endians = []string{"little", "big"}
+)
+
+type payload struct {
+	Code int32  `json:"code" xml:"code" msgpack:"code"`
+	Msg  string `json:"msg" xml:"msg" msgpack:"msg"`
+}
+
+// 生成编解码器对应的消息负载及其解码目标
+func newPayload(codec string, code int32, raw []byte) (in, out interface{}) {
+	switch codec {
+	case "":
+		return raw, new([]byte)
+	case "proto":
+		return &qx.TestData{Code: code, Msg: hex.EncodeToString(raw)}, new(qx.TestData)
+	default:
+		return &payload{Code: code, Msg: hex.EncodeToString(raw)}, new(payload)
+	}
+}
+
+// 编码消息负载
+func marshalPayload(codec string, in interface{}) ([]byte, error) {
+	if codec == "" {
+		return in.([]byte), nil
+	}
+
+	return encoding.Invoke(codec).Marshal(in)
+}
+
+// 比较解码后的消息负载
+func equalPayload(in, out interface{}) bool {
+	switch v := in.(type) {
+	case []byte:
+		return bytes.Equal(v, *out.(*[]byte))
+	case proto.Message:
+		return proto.Equal(v, out.(proto.Message))
+	default:
+		return reflect.DeepEqual(in, out)
+	}
+}
+
+// 截取低n字节并按符号位扩展，使取值落在n字节有符号整数范围内
+func fit(v int32, n int) int32 {
+	if n == 0 {
+		return 0
+	}
+
+	shift := 32 - 8*n
+
+	return v << shift >> shift
+}
+
+func byteOrder(endian string) binary.ByteOrder {
+	if endian == "big" {
+		return binary.BigEndian
+	}
+
+	return binary.LittleEndian
+}
+
+func check(t *testing.T, fn interface{}) {
+	t.Helper()
+
+	if err := quick.Check(fn, &quick.Config{MaxCount: 20}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDue_RoundTrip(t *testing.T) {
+	for _, endian := range endians {
+		for _, routeBytes := range []int{1, 2, 4} {
+			for _, seqBytes := range []int{0, 1, 2, 4} {
+				for _, heartbeatTime := range []bool{false, true} {
+					for _, codec := range codecs {
+						name := fmt.Sprintf("%s/route%d/seq%d/heartbeat%v/%s", endian, routeBytes, seqBytes, heartbeatTime, codec)
+
+						t.Run(name, func(t *testing.T) {
+							packer := due.NewPacker(
+								due.WithByteOrder(byteOrder(endian)),
+								due.WithRouteBytes(routeBytes),
+								due.WithSeqBytes(seqBytes),
+								due.WithHeartbeatTime(heartbeatTime),
+								due.WithCodeC(codec),
+							)
+
+							check(t, func(route, seq, code int32, raw []byte) bool {
+								in, out := newPayload(codec, code, raw)
+
+								buf, err := packer.MarshalData(in)
+								if err != nil {
+									t.Fatal(err)
+								}
+
+								message := &due.Message{Route: fit(route, routeBytes), Seq: fit(seq, seqBytes), Buffer: buf}
+
+								data, err := packer.PackMessage(message)
+								if err != nil {
+									t.Fatal(err)
+								}
+
+								msg, err := packer.UnpackMessage(data)
+								if err != nil {
+									t.Fatal(err)
+								}
+
+								unpacked := msg.(*due.Message)
+								if unpacked.Route != message.Route || unpacked.Seq != message.Seq {
+									return false
+								}
+
+								if err = packer.UnmarshalData(unpacked.Buffer, out); err != nil {
+									t.Fatal(err)
+								}
+
+								return equalPayload(in, out)
+							})
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestQx_RoundTrip(t *testing.T) {
+	for _, endian := range endians {
+		for _, isClient := range []bool{false, true} {
+			for _, codec := range codecs {
+				t.Run(fmt.Sprintf("%s/client%v/%s", endian, isClient, codec), func(t *testing.T) {
+					packer := qx.NewPacker(qx.WithEndian(endian), qx.WithIsClient(isClient), qx.WithCodeC(codec))
+
+					check(t, func(mainID, subID, code int32, raw []byte) bool {
+						in, out := newPayload(codec, code, raw)
+
+						data, err := packer.PackMessage(qx.NewMessage(mainID, subID, in))
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						msg, err := packer.UnpackMessage(data)
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						unpacked := msg.(*qx.Message)
+						if unpacked.GetMainID() != mainID || unpacked.GetSubID() != subID {
+							return false
+						}
+
+						if err = packer.UnmarshalData(unpacked.GetData(), out); err != nil {
+							t.Fatal(err)
+						}
+
+						return equalPayload(in, out)
+					})
+				})
+			}
+		}
+	}
+}
+
+func TestMuys_RoundTrip(t *testing.T) {
+	for _, endian := range endians {
+		for _, codec := range codecs {
+			t.Run(endian+"/"+codec, func(t *testing.T) {
+				packer := muys.NewPacker(muys.WithEndian(endian), muys.WithCodeC(codec))
+
+				check(t, func(code int32, raw []byte) bool {
+					in, out := newPayload(codec, code, raw)
+
+					buf, err := marshalPayload(codec, in)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					data, err := packer.PackMessage(muys.NewMessage(buf))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					msg, err := packer.UnpackMessage(data)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if err = packer.UnmarshalData(msg.GetData(), out); err != nil {
+						t.Fatal(err)
+					}
+
+					return equalPayload(in, out)
+				})
+			})
+		}
+	}
+}
+
+func TestMuysV2_RoundTrip(t *testing.T) {
+	for _, endian := range endians {
+		for _, codec := range codecs {
+			t.Run(endian+"/"+codec, func(t *testing.T) {
+				packer := muysV2.NewPacker(muysV2.WithEndian(endian), muysV2.WithCodeC(codec))
+
+				check(t, func(msgType uint16, code int32, raw []byte) bool {
+					in, out := newPayload(codec, code, raw)
+
+					buf, err := marshalPayload(codec, in)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					data, err := packer.PackMessage(muysV2.NewMessage(msgType, buf))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					msg, err := packer.UnpackMessage(data)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					unpacked := msg.(*muysV2.Message)
+					if unpacked.GetMsgType() != msgType {
+						return false
+					}
+
+					if err = packer.UnmarshalData(unpacked.GetData(), out); err != nil {
+						t.Fatal(err)
+					}
+
+					return equalPayload(in, out)
+				})
+			})
+		}
+	}
+}
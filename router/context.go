@@ -0,0 +1,97 @@
+package router
+
+import (
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/envelope"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+)
+
+type Context struct {
+	Conn    network.Conn    // 连接
+	Route   int32           // 路由
+	Message ipacket.Message // 解包后的消息
+	packer  ipacket.Packer  // 打包器
+	logger  logger.Logger   // 日志器
+}
+
+// Data 获取消息负载
+func (c *Context) Data() []byte {
+	return c.Message.GetData()
+}
+
+// Logger 获取附带连接与路由信息的日志器
+func (c *Context) Logger() logger.Logger {
+	return c.logger.With("cid", c.Conn.ID(), "uid", c.Conn.UID(), "route", c.Route)
+}
+
+// Packer 获取打包器
+func (c *Context) Packer() ipacket.Packer {
+	return c.packer
+}
+
+// Unmarshal 使用打包器的编解码器解析消息负载
+func (c *Context) Unmarshal(v interface{}) error {
+	return c.packer.UnmarshalData(c.Data(), v)
+}
+
+// Seq 获取请求序列号，仅due与envelope协议有效
+func (c *Context) Seq() int32 {
+	switch m := c.Message.(type) {
+	case *due.Message:
+		return m.Seq
+	case *envelope.Message:
+		return m.Seq
+	default:
+		return 0
+	}
+}
+
+// Reply 回复请求，自动回填请求的路由与序列号，仅due与envelope协议有效
+func (c *Context) Reply(v interface{}) error {
+	var (
+		msg []byte
+		err error
+	)
+
+	switch packer := c.packer.(type) {
+	case *due.Packer:
+		msg, err = c.replyDue(packer, v)
+	case *envelope.Packer:
+		msg, err = c.replyEnvelope(packer, v)
+	default:
+		return errs.ErrReplyNotSupported
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.Conn.Push(msg)
+}
+
+// 打包due协议的回复消息
+func (c *Context) replyDue(packer *due.Packer, v interface{}) ([]byte, error) {
+	data, err := packer.MarshalData(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return packer.PackMessage(&due.Message{
+		Seq:    c.Seq(),
+		Route:  c.Route,
+		Buffer: data,
+	})
+}
+
+// 打包envelope协议的回复消息
+func (c *Context) replyEnvelope(packer *envelope.Packer, v interface{}) ([]byte, error) {
+	data, err := packer.MarshalData(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return packer.PackMessage(envelope.NewMessage(c.Route, c.Seq(), data))
+}
@@ -0,0 +1,39 @@
+package router
+
+import (
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+)
+
+// RouteResolver 从解包后的消息中解析路由
+type RouteResolver func(message ipacket.Message) (route int32, ok bool)
+
+type Option func(o *options)
+
+type options struct {
+	resolver RouteResolver // 路由解析器，默认支持due、qx、muysV2
+	fallback Handler       // 未注册路由的处理函数
+	logger   logger.Logger // 日志器，默认使用全局日志器
+}
+
+func defaultOptions() *options {
+	return &options{
+		resolver: ResolveRoute,
+		logger:   logger.Default(),
+	}
+}
+
+// WithRouteResolver 设置路由解析器
+func WithRouteResolver(resolver RouteResolver) Option {
+	return func(o *options) { o.resolver = resolver }
+}
+
+// WithFallback 设置未注册路由的处理函数
+func WithFallback(handler Handler) Option {
+	return func(o *options) { o.fallback = handler }
+}
+
+// WithLogger 设置日志器
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
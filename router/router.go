@@ -0,0 +1,120 @@
+package router
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/envelope"
+	"github.com/cute-angelia/go-game-utils/packet/ipacket"
+	"github.com/cute-angelia/go-game-utils/packet/muysV2"
+	"github.com/cute-angelia/go-game-utils/packet/qx"
+	"sync"
+)
+
+type Handler func(ctx *Context)
+
+type Router struct {
+	opts     *options
+	packer   ipacket.Packer
+	rw       sync.RWMutex
+	handlers map[int32]Handler
+}
+
+// NewRouter 创建路由器，并接管服务器的消息接收
+// server为nil时仅创建路由器，可将Dispatch注册到客户端的OnReceive上
+func NewRouter(server network.Server, packer ipacket.Packer, opts ...Option) *Router {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	r := &Router{
+		opts:     o,
+		packer:   packer,
+		handlers: make(map[int32]Handler),
+	}
+
+	if server != nil {
+		server.OnReceive(r.Dispatch)
+	}
+
+	return r
+}
+
+// Handle 注册路由处理器，qx协议可使用qx.EncodeRoute组合mainID与subID
+func (r *Router) Handle(route int32, handler Handler) {
+	r.rw.Lock()
+	r.handlers[route] = handler
+	r.rw.Unlock()
+}
+
+// Remove 移除路由处理器
+func (r *Router) Remove(route int32) {
+	r.rw.Lock()
+	delete(r.handlers, route)
+	r.rw.Unlock()
+}
+
+// Dispatch 分发消息，可直接作为network.ReceiveHandler使用
+func (r *Router) Dispatch(conn network.Conn, msg []byte) {
+	message, err := r.packer.UnpackMessage(msg)
+	if err != nil {
+		r.opts.logger.Warn("unpack message error", "packer", r.packer.String(), "cid", conn.ID(), "error", err)
+		return
+	}
+
+	route, ok := r.opts.resolver(message)
+
+	ctx := &Context{
+		Conn:    conn,
+		Route:   route,
+		Message: message,
+		packer:  r.packer,
+		logger:  r.opts.logger,
+	}
+
+	var handler Handler
+	if ok {
+		r.rw.RLock()
+		handler = r.handlers[route]
+		r.rw.RUnlock()
+	}
+
+	if handler == nil {
+		if r.opts.fallback != nil {
+			r.opts.fallback(ctx)
+		}
+		return
+	}
+
+	handler(ctx)
+}
+
+// Typed 将带类型的处理函数转换为Handler，消息负载通过打包器的编解码器解析
+func Typed[T any](handler func(ctx *Context, req *T)) Handler {
+	return func(ctx *Context) {
+		req := new(T)
+
+		if err := ctx.Unmarshal(req); err != nil {
+			ctx.Logger().Warn("unmarshal data error", "packer", ctx.packer.String(), "error", err)
+			return
+		}
+
+		handler(ctx, req)
+	}
+}
+
+// ResolveRoute 默认的路由解析器
+func ResolveRoute(message ipacket.Message) (int32, bool) {
+	switch m := message.(type) {
+	case *due.Message:
+		return m.Route, true
+	case *qx.Message:
+		return qx.EncodeRoute(m.GetMainID(), m.GetSubID()), true
+	case *muysV2.Message:
+		return int32(m.GetMsgType()), true
+	case *envelope.Message:
+		return m.Route, true
+	default:
+		return 0, false
+	}
+}
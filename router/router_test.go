@@ -0,0 +1,117 @@
+package router_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/packet/envelope"
+	"github.com/cute-angelia/go-game-utils/packet/qx"
+	"github.com/cute-angelia/go-game-utils/router"
+	"testing"
+)
+
+type conn struct {
+	network.Conn
+}
+
+func TestRouter_Due(t *testing.T) {
+	packer := due.NewPacker()
+	r := router.NewRouter(nil, packer)
+
+	var got []byte
+	r.Handle(2, func(ctx *router.Context) {
+		got = ctx.Data()
+	})
+
+	data, err := packer.PackMessage(&due.Message{Seq: 1, Route: 2, Buffer: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Dispatch(&conn{}, data)
+
+	if string(got) != "hello" {
+		t.Fatalf("unexpected data: %q", got)
+	}
+}
+
+func TestRouter_QxTyped(t *testing.T) {
+	packer := qx.NewPacker(qx.WithCodeC("proto"))
+	r := router.NewRouter(nil, packer)
+
+	var got *qx.TestData
+	r.Handle(qx.EncodeRoute(311, 2), router.Typed(func(ctx *router.Context, req *qx.TestData) {
+		got = req
+	}))
+
+	data, err := packer.PackMessage(qx.NewMessage(311, 2, &qx.TestData{Code: 1, Name: "test"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Dispatch(&conn{}, data)
+
+	if got == nil || got.GetCode() != 1 || got.GetName() != "test" {
+		t.Fatalf("unexpected request: %v", got)
+	}
+}
+
+func TestRouter_Fallback(t *testing.T) {
+	var route int32
+
+	packer := due.NewPacker()
+	r := router.NewRouter(nil, packer, router.WithFallback(func(ctx *router.Context) {
+		route = ctx.Route
+	}))
+
+	data, err := packer.PackMessage(&due.Message{Route: 9, Buffer: []byte("unknown")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Dispatch(&conn{}, data)
+
+	if route != 9 {
+		t.Fatalf("fallback not called, route: %d", route)
+	}
+}
+
+type pushConn struct {
+	network.Conn
+	pushed [][]byte
+}
+
+func (c *pushConn) Push(msg []byte) error {
+	c.pushed = append(c.pushed, msg)
+	return nil
+}
+
+func TestRouter_EnvelopeReply(t *testing.T) {
+	packer := envelope.NewPacker()
+	r := router.NewRouter(nil, packer)
+
+	type request struct {
+		Name string `json:"name"`
+	}
+
+	r.Handle(5, router.Typed(func(ctx *router.Context, req *request) {
+		if err := ctx.Reply(map[string]string{"hello": req.Name}); err != nil {
+			t.Error(err)
+		}
+	}))
+
+	data, err := packer.PackMessage(envelope.NewMessage(5, 7, []byte(`{"name":"fuxi"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &pushConn{}
+	r.Dispatch(c, data)
+
+	if len(c.pushed) != 1 {
+		t.Fatalf("pushed %d messages, want 1", len(c.pushed))
+	}
+
+	if want := `{"route":5,"seq":7,"data":{"hello":"fuxi"}}`; string(c.pushed[0]) != want {
+		t.Fatalf("reply %s, want %s", c.pushed[0], want)
+	}
+}
@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"github.com/cute-angelia/go-game-utils/logger"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"sync"
+)
+
+type Client struct {
+	client            network.Client            // 网络客户端
+	packer            *due.Packer               // 打包器
+	conns             sync.Map                  // 连接ID -> *Conn
+	connectHandler    network.ConnectHandler    // 连接打开hook函数
+	disconnectHandler network.DisconnectHandler // 连接关闭hook函数
+	receiveHandler    network.ReceiveHandler    // 接收非响应消息hook函数
+}
+
+// NewClient 创建RPC客户端，接管网络客户端的连接、断开与接收hook
+func NewClient(client network.Client, packer *due.Packer) *Client {
+	c := &Client{client: client, packer: packer}
+
+	client.OnConnect(c.handleConnect)
+	client.OnDisconnect(c.handleDisconnect)
+	client.OnReceive(c.handleReceive)
+
+	return c
+}
+
+// Dial 拨号连接
+func (c *Client) Dial(addr ...string) (*Conn, error) {
+	conn, err := c.client.Dial(addr...)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := c.conns.Load(conn.ID()); ok {
+		return v.(*Conn), nil
+	}
+
+	// 连接在拨号返回前已断开
+	cc := newConn(c, conn)
+	cc.fail()
+
+	return cc, nil
+}
+
+// OnConnect 监听连接打开
+func (c *Client) OnConnect(handler network.ConnectHandler) {
+	c.connectHandler = handler
+}
+
+// OnDisconnect 监听连接断开
+func (c *Client) OnDisconnect(handler network.DisconnectHandler) {
+	c.disconnectHandler = handler
+}
+
+// OnReceive 监听接收非响应消息
+func (c *Client) OnReceive(handler network.ReceiveHandler) {
+	c.receiveHandler = handler
+}
+
+// 处理连接打开
+func (c *Client) handleConnect(conn network.Conn) {
+	c.conns.Store(conn.ID(), newConn(c, conn))
+
+	if c.connectHandler != nil {
+		c.connectHandler(conn)
+	}
+}
+
+// 处理连接断开
+func (c *Client) handleDisconnect(conn network.Conn) {
+	if v, ok := c.conns.LoadAndDelete(conn.ID()); ok {
+		v.(*Conn).fail()
+	}
+
+	if c.disconnectHandler != nil {
+		c.disconnectHandler(conn)
+	}
+}
+
+// 处理接收消息
+func (c *Client) handleReceive(conn network.Conn, msg []byte) {
+	if v, ok := c.conns.Load(conn.ID()); ok {
+		message, err := c.packer.UnpackMessage(msg)
+		if err != nil {
+			logger.Default().Warn("unpack message error", "packer", c.packer.String(), "cid", conn.ID(), "error", err)
+			return
+		}
+
+		if v.(*Conn).resolve(message.(*due.Message)) {
+			return
+		}
+	}
+
+	if c.receiveHandler != nil {
+		c.receiveHandler(conn, msg)
+	}
+}
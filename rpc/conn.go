@@ -0,0 +1,145 @@
+package rpc
+
+import (
+	"context"
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"sync"
+)
+
+type Conn struct {
+	network.Conn
+	client  *Client                     // RPC客户端
+	mu      sync.Mutex                  // 锁
+	seq     int32                       // 上次分配的序列号
+	pending map[int32]chan *due.Message // 等待响应的请求
+	done    chan struct{}               // 连接关闭信号
+	closed  bool                        // 是否已关闭
+}
+
+func newConn(client *Client, conn network.Conn) *Conn {
+	return &Conn{
+		Conn:    conn,
+		client:  client,
+		pending: make(map[int32]chan *due.Message),
+		done:    make(chan struct{}),
+	}
+}
+
+// Call 发起请求并等待序列号匹配的响应
+// resp为nil时忽略响应内容；ctx超时或连接关闭时立即返回
+func (c *Conn) Call(ctx context.Context, route int32, req, resp interface{}) error {
+	data, err := c.client.packer.MarshalData(req)
+	if err != nil {
+		return err
+	}
+
+	seq, ch, err := c.register()
+	if err != nil {
+		return err
+	}
+	defer c.unregister(seq)
+
+	msg, err := c.client.packer.PackMessage(&due.Message{
+		Seq:    seq,
+		Route:  route,
+		Buffer: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = c.Push(msg); err != nil {
+		return err
+	}
+
+	select {
+	case reply := <-ch:
+		if resp == nil {
+			return nil
+		}
+		return c.client.packer.UnmarshalData(reply.Buffer, resp)
+	case <-c.done:
+		return errs.ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 分配序列号并登记请求，序列号0保留给非请求消息
+func (c *Conn) register() (int32, chan *due.Message, error) {
+	seqBytes := c.client.packer.SeqBytes()
+	if seqBytes == 0 {
+		return 0, nil, errs.ErrSeqDisabled
+	}
+
+	max := int32(1<<(8*seqBytes-1) - 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, nil, errs.ErrConnectionClosed
+	}
+
+	if len(c.pending) >= int(max) {
+		return 0, nil, errs.ErrTooManyPendingCalls
+	}
+
+	for {
+		if c.seq >= max {
+			c.seq = 0
+		}
+		c.seq++
+
+		if _, ok := c.pending[c.seq]; !ok {
+			break
+		}
+	}
+
+	ch := make(chan *due.Message, 1)
+	c.pending[c.seq] = ch
+
+	return c.seq, ch, nil
+}
+
+// 注销请求
+func (c *Conn) unregister(seq int32) {
+	c.mu.Lock()
+	delete(c.pending, seq)
+	c.mu.Unlock()
+}
+
+// 投递响应，返回是否为等待中的请求的响应
+func (c *Conn) resolve(message *due.Message) bool {
+	if message.Seq == 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[message.Seq]
+	if ok {
+		delete(c.pending, message.Seq)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		ch <- message
+	}
+
+	return ok
+}
+
+// 连接关闭，使所有等待中的请求失败
+func (c *Conn) fail() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	close(c.done)
+}
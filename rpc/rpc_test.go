@@ -0,0 +1,78 @@
+package rpc_test
+
+import (
+	"context"
+	"github.com/cute-angelia/go-game-utils/network/tcp"
+	"github.com/cute-angelia/go-game-utils/packet/due"
+	"github.com/cute-angelia/go-game-utils/router"
+	"github.com/cute-angelia/go-game-utils/rpc"
+	"github.com/cute-angelia/go-game-utils/utils/inet"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConn_Call(t *testing.T) {
+	port, err := inet.AssignRandPort("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	packer := due.NewPacker()
+
+	server := tcp.NewServer(tcp.WithServerListenAddr(addr), tcp.WithServerPacker(packer))
+	r := router.NewRouter(server, packer)
+	r.Handle(1, func(ctx *router.Context) {
+		_ = ctx.Reply(append([]byte("echo "), ctx.Data()...))
+	})
+
+	if err = server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := rpc.NewClient(tcp.NewClient(tcp.WithClientDialAddr(addr), tcp.WithClientPacker(packer)), packer)
+
+	conn, err := client.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var resp []byte
+	if err = conn.Call(ctx, 1, []byte("hello"), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(resp) != "echo hello" {
+		t.Fatalf("unexpected response: %q", resp)
+	}
+
+	// 未注册的路由不会响应，等待超时
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err = conn.Call(ctx, 2, []byte("hello"), nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// 连接关闭后等待中的请求立即失败
+	errCh := make(chan error, 1)
+	go func() { errCh <- conn.Call(context.Background(), 2, []byte("hello"), nil) }()
+
+	time.Sleep(20 * time.Millisecond)
+	_ = conn.Close(true)
+
+	select {
+	case err = <-errCh:
+		if err == nil {
+			t.Fatal("expected error after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending call not failed after close")
+	}
+}
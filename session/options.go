@@ -0,0 +1,16 @@
+package session
+
+type Option func(o *options)
+
+type options struct {
+	kick bool // 同一用户重复绑定时是否踢掉旧连接，默认false
+}
+
+func defaultOptions() *options {
+	return &options{}
+}
+
+// WithKick 设置同一用户重复绑定时是否踢掉旧连接
+func WithKick(kick bool) Option {
+	return func(o *options) { o.kick = kick }
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"github.com/cute-angelia/go-game-utils/errs"
+	"github.com/cute-angelia/go-game-utils/network"
+	"sync"
+)
+
+type session struct {
+	cid  int64        // 添加时的连接ID
+	conn network.Conn // 连接
+	uid  int64        // 绑定的用户ID
+}
+
+// 连接关闭后会被复用为其他连接ID，连接ID不一致时会话已失效
+func (s *session) valid() bool {
+	return s.conn.ID() == s.cid
+}
+
+// 连接已失效或被绕过管理器绑定为其他用户时，用户索引已失效
+func (s *session) bound(uid int64) bool {
+	return s.valid() && s.conn.UID() == uid
+}
+
+// Manager 会话管理器，维护连接ID与用户ID到连接的索引
+// 需在服务器的OnConnect与OnDisconnect中分别调用Add与Remove
+// 用户绑定与解绑须通过Manager.Bind与Manager.Unbind进行，直接调用连接的Bind与Unbind会使用户索引失效
+type Manager struct {
+	opts  *options
+	rw    sync.RWMutex
+	conns map[int64]*session // 连接ID -> 会话
+	users map[int64]*session // 用户ID -> 会话
+}
+
+func NewManager(opts ...Option) *Manager {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Manager{
+		opts:  o,
+		conns: make(map[int64]*session),
+		users: make(map[int64]*session),
+	}
+}
+
+// Add 添加连接
+func (m *Manager) Add(conn network.Conn) {
+	m.rw.Lock()
+	cid := conn.ID()
+	m.conns[cid] = &session{cid: cid, conn: conn}
+	m.rw.Unlock()
+}
+
+// Remove 移除连接，同时解除其用户绑定
+func (m *Manager) Remove(conn network.Conn) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	s, ok := m.conns[conn.ID()]
+	if !ok {
+		return
+	}
+
+	delete(m.conns, conn.ID())
+
+	if s.uid != 0 && m.users[s.uid] == s {
+		delete(m.users, s.uid)
+	}
+}
+
+// Bind 绑定用户ID到连接，替代直接调用连接的Bind
+// 用户已绑定到其他连接时，旧连接被解绑；开启踢线时旧连接同时被关闭
+func (m *Manager) Bind(cid, uid int64) error {
+	if uid == 0 {
+		return errs.ErrInvalidUID
+	}
+
+	m.rw.Lock()
+
+	s, ok := m.conns[cid]
+	if !ok {
+		m.rw.Unlock()
+		return errs.ErrConnectionNotFound
+	}
+
+	if s.uid != 0 && s.uid != uid && m.users[s.uid] == s {
+		delete(m.users, s.uid)
+	}
+
+	old, ok := m.users[uid]
+	if ok && old == s {
+		old = nil
+	} else if ok {
+		old.uid = 0
+		old.conn.Unbind()
+	}
+
+	s.uid = uid
+	s.conn.Bind(uid)
+	m.users[uid] = s
+
+	m.rw.Unlock()
+
+	if old != nil && m.opts.kick {
+		return old.conn.Close()
+	}
+
+	return nil
+}
+
+// Unbind 解绑用户，替代直接调用连接的Unbind
+func (m *Manager) Unbind(uid int64) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+
+	s, ok := m.users[uid]
+	if !ok {
+		return
+	}
+
+	delete(m.users, uid)
+	s.uid = 0
+	s.conn.Unbind()
+}
+
+// Conn 根据连接ID获取连接
+func (m *Manager) Conn(cid int64) (network.Conn, bool) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+
+	if s, ok := m.conns[cid]; ok && s.valid() {
+		return s.conn, true
+	}
+
+	return nil, false
+}
+
+// User 根据用户ID获取连接
+func (m *Manager) User(uid int64) (network.Conn, bool) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+
+	if s, ok := m.users[uid]; ok && s.bound(uid) {
+		return s.conn, true
+	}
+
+	return nil, false
+}
+
+// Count 连接数
+func (m *Manager) Count() int {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+
+	return len(m.conns)
+}
+
+// Push 推送消息给用户
+func (m *Manager) Push(uid int64, msg []byte) error {
+	m.rw.RLock()
+	s, ok := m.users[uid]
+	m.rw.RUnlock()
+
+	if !ok || !s.bound(uid) {
+		return errs.ErrUserNotFound
+	}
+
+	return network.PushID(s.conn, s.cid, msg)
+}
+
+// Multicast 推送消息给多个用户，返回推送成功的数量
+func (m *Manager) Multicast(uids []int64, msg []byte) int {
+	sessions := make([]*session, 0, len(uids))
+
+	m.rw.RLock()
+	for _, uid := range uids {
+		if s, ok := m.users[uid]; ok && s.bound(uid) {
+			sessions = append(sessions, s)
+		}
+	}
+	m.rw.RUnlock()
+
+	return push(sessions, msg)
+}
+
+// Broadcast 推送消息给所有连接，返回推送成功的数量
+func (m *Manager) Broadcast(msg []byte) int {
+	m.rw.RLock()
+	sessions := make([]*session, 0, len(m.conns))
+	for _, s := range m.conns {
+		sessions = append(sessions, s)
+	}
+	m.rw.RUnlock()
+
+	return push(sessions, msg)
+}
+
+// 在锁外推送消息，避免慢连接阻塞索引操作
+// 按添加时的连接ID推送，跳过已被复用为其他连接的会话
+func push(sessions []*session, msg []byte) int {
+	n := 0
+	for _, s := range sessions {
+		if err := network.PushID(s.conn, s.cid, msg); err == nil {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,144 @@
+package session_test
+
+import (
+	"github.com/cute-angelia/go-game-utils/network"
+	"github.com/cute-angelia/go-game-utils/session"
+	"testing"
+)
+
+type conn struct {
+	network.Conn
+	id     int64
+	uid    int64
+	closed bool
+	pushed [][]byte
+}
+
+func (c *conn) ID() int64             { return c.id }
+func (c *conn) UID() int64            { return c.uid }
+func (c *conn) Bind(uid int64)        { c.uid = uid }
+func (c *conn) Unbind()               { c.uid = 0 }
+func (c *conn) Push(msg []byte) error { c.pushed = append(c.pushed, msg); return nil }
+func (c *conn) Close(_ ...bool) error { c.closed = true; return nil }
+
+func TestManager_Bind(t *testing.T) {
+	m := session.NewManager(session.WithKick(true))
+
+	c1, c2 := &conn{id: 1}, &conn{id: 2}
+	m.Add(c1)
+	m.Add(c2)
+
+	if err := m.Bind(1, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn, ok := m.User(100); !ok || conn != c1 {
+		t.Fatal("user 100 should be bound to conn 1")
+	}
+
+	if err := m.Bind(2, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn, ok := m.User(100); !ok || conn != c2 {
+		t.Fatal("user 100 should be bound to conn 2")
+	}
+
+	if !c1.closed || c1.uid != 0 {
+		t.Fatal("previous conn should be unbound and kicked")
+	}
+
+	m.Remove(c1)
+
+	if _, ok := m.User(100); !ok {
+		t.Fatal("removing kicked conn should keep the new binding")
+	}
+
+	m.Remove(c2)
+
+	if _, ok := m.User(100); ok {
+		t.Fatal("user 100 should be removed with its conn")
+	}
+}
+
+func TestManager_Push(t *testing.T) {
+	m := session.NewManager()
+
+	c1, c2, c3 := &conn{id: 1}, &conn{id: 2}, &conn{id: 3}
+	m.Add(c1)
+	m.Add(c2)
+	m.Add(c3)
+	_ = m.Bind(1, 100)
+	_ = m.Bind(2, 200)
+
+	if err := m.Push(100, []byte("push")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Push(300, []byte("push")); err == nil {
+		t.Fatal("push to unknown user should fail")
+	}
+
+	if n := m.Multicast([]int64{100, 200, 300}, []byte("multicast")); n != 2 {
+		t.Fatalf("multicast pushed %d, want 2", n)
+	}
+
+	if n := m.Broadcast([]byte("broadcast")); n != 3 {
+		t.Fatalf("broadcast pushed %d, want 3", n)
+	}
+
+	if len(c1.pushed) != 3 || len(c2.pushed) != 2 || len(c3.pushed) != 1 {
+		t.Fatalf("unexpected push count: %d %d %d", len(c1.pushed), len(c2.pushed), len(c3.pushed))
+	}
+}
+
+func TestManager_RecycledConn(t *testing.T) {
+	m := session.NewManager()
+
+	c1, c2 := &conn{id: 1}, &conn{id: 2}
+	m.Add(c1)
+	m.Add(c2)
+	_ = m.Bind(1, 100)
+
+	// 连接关闭后被复用为新的连接ID
+	c1.id, c1.uid = 3, 0
+
+	if _, ok := m.Conn(1); ok {
+		t.Fatal("recycled conn should not be returned")
+	}
+
+	if _, ok := m.User(100); ok {
+		t.Fatal("recycled conn should not be returned for its old user")
+	}
+
+	if err := m.Push(100, []byte("push")); err == nil {
+		t.Fatal("push to recycled conn should fail")
+	}
+
+	if n := m.Broadcast([]byte("broadcast")); n != 1 {
+		t.Fatalf("broadcast should skip recycled conn, pushed %d", n)
+	}
+
+	if len(c1.pushed) != 0 {
+		t.Fatal("recycled conn should not receive any message")
+	}
+}
+
+func TestManager_DirectBind(t *testing.T) {
+	m := session.NewManager()
+
+	c := &conn{id: 1}
+	m.Add(c)
+	_ = m.Bind(1, 100)
+
+	// 绕过管理器直接绑定其他用户
+	c.Bind(200)
+
+	if _, ok := m.User(100); ok {
+		t.Fatal("conn bound directly to another user should not be returned")
+	}
+
+	if n := m.Multicast([]int64{100, 200}, []byte("multicast")); n != 0 {
+		t.Fatalf("multicast should skip stale user index, pushed %d", n)
+	}
+}